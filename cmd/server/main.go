@@ -12,6 +12,8 @@ import (
 	"os/signal"
 	"syscall"
 
+	"github.com/farouqzaib/fast-search/internal/analyzer"
+	"github.com/farouqzaib/fast-search/internal/index"
 	"github.com/farouqzaib/fast-search/internal/server"
 	"github.com/farouqzaib/fast-search/internal/storage"
 	"github.com/hashicorp/raft"
@@ -19,10 +21,13 @@ import (
 )
 
 var (
-	joinAddr string
-	raftAddr string
-	httpAddr string
-	nodeId   string
+	joinAddr       string
+	raftAddr       string
+	httpAddr       string
+	nodeId         string
+	analyzerName   string
+	analyzerConfig string
+	schemaPath     string
 )
 
 func main() {
@@ -30,15 +35,48 @@ func main() {
 	flag.StringVar(&joinAddr, "joinAddr", "", "HTTP API service address of primary node to join")
 	flag.StringVar(&nodeId, "nodeId", "", "unique identifier for node")
 	flag.StringVar(&raftAddr, "raftAddr", "", "raft address for node")
+	flag.StringVar(&analyzerName, "analyzer", analyzer.DefaultAnalyzer, "analyzer documents and queries go through")
+	flag.StringVar(&analyzerConfig, "analyzerConfig", "", "JSON file defining custom analyzers")
+	flag.StringVar(&schemaPath, "schema", "", "JSON file declaring the fields of documents")
 	flag.Parse()
 
 	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
 	slog.SetDefault(logger)
 
+	if analyzerConfig != "" {
+		f, err := os.Open(analyzerConfig)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		err = analyzer.LoadConfig(f)
+		f.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// the schema may name analyzers the config defines
+	var schema *index.Schema
+	if schemaPath != "" {
+		f, err := os.Open(schemaPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		schema, err = index.ParseSchema(f)
+		f.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	config := storage.Config{}
 	config.Raft.LocalID = raft.ServerID(nodeId)
 	config.Addr = raftAddr
 	config.RaftDir = "internal/storage/raft"
+	config.Analyzer = analyzerName
+	config.Schema = schema
 
 	if joinAddr == "" {
 		config.Raft.Bootstrap = true
@@ -95,6 +133,7 @@ func main() {
 
 	<-signalCh
 	slog.Info("shutdown: flushing memtables to disk")
-	indexStorage.DB.FlushMemtables()
+	indexStorage.Flush()
+	indexStorage.Close()
 
 }
@@ -1,51 +1,253 @@
 package analyzer
 
 import (
+	"fmt"
 	"strings"
 	"unicode"
-
-	snowballeng "github.com/kljensen/snowball/english"
 )
 
 // Credit: https://artem.krylysov.com/blog/2020/07/28/lets-build-a-full-text-search-engine/
 
-func Analyze(text string) []string {
-	tokens := tokenize(text)
-	tokens = lowercaseFilter(tokens)
-	tokens = stopwordFilter(tokens)
-	tokens = stemmerFilter(tokens)
+// Token is a term along with where it came from. PositionIncrement is the
+// distance from the previous token's position, so a filter that removes a
+// token leaves a gap rather than shifting the positions after it. Start and
+// End are byte offsets into the analyzed text. Variant marks another form of
+// the token before it, such as its folded spelling, at the same position;
+// a query term matches through either form. Keyword marks a token stemmers
+// leave as it is.
+type Token struct {
+	Term              string
+	PositionIncrement int
+	Start             int
+	End               int
+	Variant           bool
+	Keyword           bool
+}
+
+// Positions returns the position of every token, counting from 0.
+func Positions(tokens []Token) []int {
+	positions := make([]int, len(tokens))
+	position := -1
+	for i, token := range tokens {
+		position += token.PositionIncrement
+		positions[i] = position
+	}
+	return positions
+}
+
+// Tokenizer splits text into tokens, each one position on from the last.
+type Tokenizer interface {
+	Tokenize(text string) []Token
+}
+
+// TokenFilter rewrites, removes or adds tokens. A filter removing a token
+// carries its position increment over to the next token it keeps.
+type TokenFilter interface {
+	Filter(tokens []Token) []Token
+}
+
+// QueryRewriter is a filter that rewrites queries into alternative token
+// streams rather than filtering them, for when a query may be written in
+// more than one way, such as with multi-word synonyms. A document matches the
+// query if it matches any of the rewrites.
+type QueryRewriter interface {
+	TokenFilter
+	Rewrite(tokens []Token) [][]Token
+}
+
+type TokenizerFunc func(text string) []Token
+
+func (f TokenizerFunc) Tokenize(text string) []Token {
+	return f(text)
+}
+
+type TokenFilterFunc func(tokens []Token) []Token
+
+func (f TokenFilterFunc) Filter(tokens []Token) []Token {
+	return f(tokens)
+}
+
+// Analyzer is a tokenizer followed by a chain of filters. Documents and the
+// queries run against them must go through the same analyzer, which is why
+// indexes record the name of theirs. Definition names the tokenizer and
+// filters, for showing what each of them does.
+type Analyzer struct {
+	Name       string
+	Tokenizer  Tokenizer
+	Filters    []TokenFilter
+	Definition Definition
+	// languages are the analyzers of each language sharing the word lists
+	// of a copy made by WithWords, nil for the others.
+	languages map[string]*Analyzer
+}
+
+// Stage is the tokens a tokenizer or filter of an analyzer put out.
+type Stage struct {
+	Name   string
+	Tokens []Token
+}
+
+func (a *Analyzer) Analyze(text string) []Token {
+	tokens := a.Tokenizer.Tokenize(text)
+	for _, f := range a.Filters {
+		tokens = f.Filter(tokens)
+	}
 	return tokens
 }
 
-func tokenize(text string) []string {
-	return strings.FieldsFunc(text, func(r rune) bool {
-		// Split on any character that is not a letter or a number.
-		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
-	})
+// Stages analyzes text as a document, keeping the tokens after the tokenizer
+// and after every filter.
+func (a *Analyzer) Stages(text string) []Stage {
+	name := func(names []string, i int, kind string) string {
+		if i < len(names) && names[i] != "" {
+			return names[i]
+		}
+		return fmt.Sprintf("%s %d", kind, i)
+	}
+
+	tokens := a.Tokenizer.Tokenize(text)
+	stages := []Stage{{Name: name([]string{a.Definition.Tokenizer}, 0, "tokenizer"), Tokens: tokens}}
+	for i, f := range a.Filters {
+		tokens = f.Filter(tokens)
+		stages = append(stages, Stage{Name: name(a.Definition.Filters, i, "filter"), Tokens: tokens})
+	}
+	return stages
+}
+
+// AnalyzeQuery analyzes a query into the token streams it may match as. Every
+// filter that is a QueryRewriter rewrites the streams so far, and the others
+// filter each of them. There is a single stream unless something rewrote it.
+func (a *Analyzer) AnalyzeQuery(text string) [][]Token {
+	streams := [][]Token{a.Tokenizer.Tokenize(text)}
+	for _, f := range a.Filters {
+		next := make([][]Token, 0, len(streams))
+		for _, tokens := range streams {
+			if r, ok := f.(QueryRewriter); ok {
+				next = append(next, r.Rewrite(tokens)...)
+			} else {
+				next = append(next, f.Filter(tokens))
+			}
+		}
+
+		if len(next) > MaxQueryRewrites {
+			next = next[:MaxQueryRewrites]
+		}
+		streams = next
+	}
+	return streams
 }
 
-func lowercaseFilter(tokens []string) []string {
+// Terms is Analyze without the positions and offsets.
+func (a *Analyzer) Terms(text string) []string {
+	return terms(a.Analyze(text))
+}
+
+// Analyze runs text through the english analyzer.
+func Analyze(text string) []string {
+	return englishAnalyzer.Terms(text)
+}
+
+// AnalyzeTokens is Analyze keeping each term's positions and offsets in text.
+func AnalyzeTokens(text string) []Token {
+	return englishAnalyzer.Analyze(text)
+}
+
+func terms(tokens []Token) []string {
 	r := make([]string, len(tokens))
 	for i, token := range tokens {
-		r[i] = strings.ToLower(token)
+		r[i] = token.Term
 	}
 	return r
 }
 
-func stopwordFilter(tokens []string) []string {
-	r := make([]string, 0, len(tokens))
+func tokenize(text string) []Token {
+	tokens := []Token{}
+	start := -1
+	for i, r := range text {
+		// Split on any character that is not a letter or a number, keeping
+		// the combining marks of decomposed accents with their letters.
+		if unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.IsMark(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+
+		if start >= 0 {
+			tokens = append(tokens, Token{Term: text[start:i], PositionIncrement: 1, Start: start, End: i})
+			start = -1
+		}
+	}
+
+	if start >= 0 {
+		tokens = append(tokens, Token{Term: text[start:], PositionIncrement: 1, Start: start, End: len(text)})
+	}
+	return tokens
+}
+
+func tokenizeWhitespace(text string) []Token {
+	tokens := []Token{}
+	start := -1
+	for i, r := range text {
+		if !unicode.IsSpace(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+
+		if start >= 0 {
+			tokens = append(tokens, Token{Term: text[start:i], PositionIncrement: 1, Start: start, End: i})
+			start = -1
+		}
+	}
+
+	if start >= 0 {
+		tokens = append(tokens, Token{Term: text[start:], PositionIncrement: 1, Start: start, End: len(text)})
+	}
+	return tokens
+}
+
+// tokenizeKeyword keeps the whole text as a single token.
+func tokenizeKeyword(text string) []Token {
+	if text == "" {
+		return []Token{}
+	}
+	return []Token{{Term: text, PositionIncrement: 1, Start: 0, End: len(text)}}
+}
+
+func lowercaseFilter(tokens []Token) []Token {
+	r := make([]Token, len(tokens))
+	for i, token := range tokens {
+		token.Term = strings.ToLower(token.Term)
+		r[i] = token
+	}
+	return r
+}
+
+func removeStopwords(tokens []Token, stopwords map[string]string) []Token {
+	r := make([]Token, 0, len(tokens))
+	skipped := 0
 	for _, token := range tokens {
-		if _, ok := english[token]; !ok {
-			r = append(r, token)
+		if _, ok := stopwords[token.Term]; ok {
+			skipped += token.PositionIncrement
+			continue
 		}
+
+		token.PositionIncrement += skipped
+		skipped = 0
+		r = append(r, token)
 	}
 	return r
 }
 
-func stemmerFilter(tokens []string) []string {
-	r := make([]string, len(tokens))
+func stem(tokens []Token, stemmer func(word string, stemStopWords bool) string) []Token {
+	r := make([]Token, len(tokens))
 	for i, token := range tokens {
-		r[i] = snowballeng.Stem(token, false)
+		if !token.Keyword {
+			token.Term = stemmer(token.Term, false)
+		}
+		r[i] = token
 	}
 	return r
 }
@@ -0,0 +1,59 @@
+package analyzer
+
+import "unicode"
+
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// tokenizeCJK tokenizes like tokenize, except that Chinese, Japanese and
+// Korean text, which does not separate its words with spaces, becomes
+// overlapping bigrams of its characters. A lone CJK character is kept as is.
+func tokenizeCJK(text string) []Token {
+	tokens := []Token{}
+
+	word := -1
+	run := []int{}
+	flushWord := func(end int) {
+		if word >= 0 {
+			tokens = append(tokens, Token{Term: text[word:end], PositionIncrement: 1, Start: word, End: end})
+			word = -1
+		}
+	}
+	flushRun := func(end int) {
+		run = append(run, end)
+		if len(run) == 2 {
+			tokens = append(tokens, Token{Term: text[run[0]:end], PositionIncrement: 1, Start: run[0], End: end})
+		}
+		for i := 0; i+2 < len(run); i++ {
+			tokens = append(tokens, Token{Term: text[run[i]:run[i+2]], PositionIncrement: 1, Start: run[i], End: run[i+2]})
+		}
+		run = run[:0]
+	}
+
+	for i, r := range text {
+		switch {
+		case isCJK(r):
+			flushWord(i)
+			run = append(run, i)
+		case unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.IsMark(r):
+			if len(run) > 0 {
+				flushRun(i)
+			}
+			if word < 0 {
+				word = i
+			}
+		default:
+			flushWord(i)
+			if len(run) > 0 {
+				flushRun(i)
+			}
+		}
+	}
+
+	flushWord(len(text))
+	if len(run) > 0 {
+		flushRun(len(text))
+	}
+	return tokens
+}
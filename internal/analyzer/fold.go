@@ -0,0 +1,92 @@
+package analyzer
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// nfkcFilter brings every term to Unicode normalization form KC, so that
+// composed and decomposed accents, full-width letters and ligatures such as
+// "ﬁ" each end up as one and the same term.
+func nfkcFilter(tokens []Token) []Token {
+	r := make([]Token, len(tokens))
+	for i, token := range tokens {
+		token.Term = norm.NFKC.String(token.Term)
+		r[i] = token
+	}
+	return r
+}
+
+// asciiFolds are the letters with no decomposition into an ASCII letter and
+// combining marks, and the ASCII they fold to.
+var asciiFolds = map[rune]string{
+	'ß': "ss", 'ẞ': "SS",
+	'æ': "ae", 'Æ': "AE",
+	'œ': "oe", 'Œ': "OE",
+	'ø': "o", 'Ø': "O",
+	'đ': "d", 'Đ': "D",
+	'ð': "d", 'Ð': "D",
+	'þ': "th", 'Þ': "TH",
+	'ł': "l", 'Ł': "L",
+	'ı': "i",
+	'ħ': "h", 'Ħ': "H",
+	'ŋ': "n", 'Ŋ': "N",
+}
+
+// foldDiacritics strips the combining marks off term, turning "café" into
+// "cafe" but leaving letters such as "ß" and "ø" alone.
+func foldDiacritics(term string) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(term) {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return norm.NFC.String(b.String())
+}
+
+// foldASCII is foldDiacritics that also spells out the letters in asciiFolds.
+func foldASCII(term string) string {
+	term = foldDiacritics(term)
+
+	var b strings.Builder
+	for _, r := range term {
+		if s, ok := asciiFolds[r]; ok {
+			b.WriteString(s)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// folding replaces each term with its folded form. When preserveOriginal is
+// set, a term that folding changes is kept as well, with its folded form at
+// the same position, so that a query spelled the same way as the text
+// matches both and ranks it above text that only matches once folded.
+type folding struct {
+	fold             func(term string) string
+	preserveOriginal bool
+}
+
+func (f folding) Filter(tokens []Token) []Token {
+	r := make([]Token, 0, len(tokens))
+	for _, token := range tokens {
+		folded := f.fold(token.Term)
+		if !f.preserveOriginal || folded == token.Term {
+			token.Term = folded
+			r = append(r, token)
+			continue
+		}
+
+		r = append(r, token)
+		token.Term = folded
+		token.PositionIncrement = 0
+		token.Variant = true
+		r = append(r, token)
+	}
+	return r
+}
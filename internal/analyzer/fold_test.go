@@ -0,0 +1,56 @@
+package analyzer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFolding(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected []string
+	}{
+		// composed and decomposed é, full-width letters and a ligature
+		{"Caf\u00e9 cafe\u0301 ＣＡＦＥ", []string{"café", "cafe", "café", "cafe", "cafe"}},
+		{"ﬁnal Straße Øresund", []string{"final", "straße", "strasse", "øresund", "oresund"}},
+	}
+
+	a, err := Get("folding")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		if got := a.Terms(tt.text); !reflect.DeepEqual(got, tt.expected) {
+			t.Fatalf("%q: expected %q, got %q", tt.text, tt.expected, got)
+		}
+	}
+}
+
+func TestFoldingKeepsPositions(t *testing.T) {
+	a, err := Get("folding")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokens := a.Analyze("naïve café")
+	expected := []Token{
+		{Term: "naïve", PositionIncrement: 1, Start: 0, End: 6},
+		{Term: "naive", PositionIncrement: 0, Start: 0, End: 6, Variant: true},
+		{Term: "café", PositionIncrement: 1, Start: 7, End: 12},
+		{Term: "cafe", PositionIncrement: 0, Start: 7, End: 12, Variant: true},
+	}
+	if !reflect.DeepEqual(tokens, expected) {
+		t.Fatalf("expected %v, got %v", expected, tokens)
+	}
+}
+
+func TestDiacriticFolding(t *testing.T) {
+	f := folding{fold: foldDiacritics}
+	got := terms(f.Filter(tokenize("crème brûlée smørrebrød")))
+
+	expected := []string{"creme", "brulee", "smørrebrød"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+}
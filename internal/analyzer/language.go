@@ -0,0 +1,141 @@
+package analyzer
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	snowballeng "github.com/kljensen/snowball/english"
+	snowballfr "github.com/kljensen/snowball/french"
+	snowballhu "github.com/kljensen/snowball/hungarian"
+	snowballno "github.com/kljensen/snowball/norwegian"
+	snowballru "github.com/kljensen/snowball/russian"
+	snowballes "github.com/kljensen/snowball/spanish"
+	snowballsv "github.com/kljensen/snowball/swedish"
+)
+
+// AutoLanguage asks for a document's or query's language to be detected.
+const AutoLanguage = "auto"
+
+type language struct {
+	name      string
+	code      string
+	stopwords map[string]string
+	stem      func(word string, stemStopWords bool) string
+}
+
+// languages have a stopword list and a snowball stemmer each. Every one of
+// them is a built-in analyzer under its name, made of the standard tokenizer
+// and the lowercase, <name>_stop, <name>_keywords and <name>_stem filters.
+// The stop filter removes the language's stopwords and the keyword_marker
+// filter protects no words, until a collection gives them words of its own.
+var languages = []language{
+	{name: "english", code: "en", stopwords: english, stem: snowballeng.Stem},
+	{name: "french", code: "fr", stopwords: french, stem: snowballfr.Stem},
+	{name: "spanish", code: "es", stopwords: spanish, stem: snowballes.Stem},
+	{name: "russian", code: "ru", stopwords: russian, stem: snowballru.Stem},
+	{name: "swedish", code: "sv", stopwords: swedish, stem: snowballsv.Stem},
+	{name: "norwegian", code: "no", stopwords: norwegian, stem: snowballno.Stem},
+	{name: "hungarian", code: "hu", stopwords: hungarian, stem: snowballhu.Stem},
+}
+
+func init() {
+	for _, l := range languages {
+		l := l
+		stopwords := make([]string, 0, len(l.stopwords))
+		for word := range l.stopwords {
+			stopwords = append(stopwords, word)
+		}
+
+		stop := stopFilter{words: newWordList(stopwords)}
+		keywords := keywordMarker{words: newWordList(nil)}
+		stemmer := TokenFilterFunc(func(tokens []Token) []Token { return stem(tokens, l.stem) })
+
+		registry.filters[l.name+"_stop"] = stop
+		registry.filters[l.name+"_keywords"] = keywords
+		registry.filters[l.name+"_stem"] = stemmer
+
+		a := &Analyzer{Name: l.name, Tokenizer: TokenizerFunc(tokenize)}
+		if l.name == DefaultAnalyzer {
+			a = englishAnalyzer
+		}
+		a.Filters = []TokenFilter{TokenFilterFunc(lowercaseFilter), stop, keywords, stemmer}
+		a.Definition = Definition{
+			Tokenizer: "standard",
+			Filters:   []string{"lowercase", l.name + "_stop", l.name + "_keywords", l.name + "_stem"},
+		}
+		registry.analyzers[l.name] = a
+		registry.builtin[l.name] = true
+	}
+}
+
+// cjkCodes are the languages analyzed with the cjk analyzer, which splits
+// their text into bigrams instead of stemming it.
+var cjkCodes = map[string]bool{"zh": true, "ja": true, "ko": true}
+
+// Language returns the name of a supported language given its name or its
+// ISO 639-1 code, such as "french" or "fr". Chinese, Japanese and Korean are
+// all "cjk".
+func Language(nameOrCode string) (string, error) {
+	nameOrCode = strings.ToLower(nameOrCode)
+	if nameOrCode == "cjk" || cjkCodes[nameOrCode] {
+		return "cjk", nil
+	}
+
+	for _, l := range languages {
+		if l.name == nameOrCode || l.code == nameOrCode {
+			return l.name, nil
+		}
+	}
+	return "", fmt.Errorf("analyzer: unsupported language %q", nameOrCode)
+}
+
+// ForLanguage returns the analyzer of a supported language.
+func ForLanguage(nameOrCode string) (*Analyzer, error) {
+	name, err := Language(nameOrCode)
+	if err != nil {
+		return nil, err
+	}
+	return Get(name)
+}
+
+// DetectLanguage guesses the language of text from its script and from which
+// language's stopwords it uses most. It returns "" when text gives nothing
+// to go on.
+func DetectLanguage(text string) string {
+	tokens := lowercaseFilter(tokenizeCJK(text))
+
+	cyrillic, cjk := 0, 0
+	for _, token := range tokens {
+		for _, r := range token.Term {
+			if unicode.Is(unicode.Cyrillic, r) {
+				cyrillic++
+			}
+			if isCJK(r) {
+				cjk++
+			}
+			break
+		}
+	}
+	if cjk > 0 && cjk*2 >= len(tokens) {
+		return "cjk"
+	}
+	if cyrillic > 0 && cyrillic*2 >= len(tokens) {
+		return "russian"
+	}
+
+	best, bestHits := "", 0
+	for _, l := range languages {
+		hits := 0
+		for _, token := range tokens {
+			if _, ok := l.stopwords[token.Term]; ok {
+				hits++
+			}
+		}
+
+		if hits > bestHits {
+			best, bestHits = l.name, hits
+		}
+	}
+	return best
+}
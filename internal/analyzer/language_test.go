@@ -0,0 +1,40 @@
+package analyzer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDetectLanguage(t *testing.T) {
+	tests := map[string]string{
+		"the printer is not working and I have restarted it":   "english",
+		"je ne peux pas me connecter à mon compte depuis hier": "french",
+		"no puedo acceder a mi cuenta desde el lunes":          "spanish",
+		"я не могу войти в свой аккаунт":                       "russian",
+		"jag kan inte logga in på mitt konto sedan i går":      "swedish",
+		"nem tudok belépni a fiókomba, mert a jelszó nem jó":   "hungarian",
+		"12345": "",
+	}
+
+	for text, expected := range tests {
+		if got := DetectLanguage(text); got != expected {
+			t.Fatalf("%q: expected %q, got %q", text, expected, got)
+		}
+	}
+}
+
+func TestLanguageAnalyzers(t *testing.T) {
+	a, err := ForLanguage("fr")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"connex", "impossibl", "compt"}
+	if got := a.Terms("Connexions impossibles à mon compte"); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+
+	if _, err := ForLanguage("klingon"); err == nil {
+		t.Fatal("expected an unsupported language to fail")
+	}
+}
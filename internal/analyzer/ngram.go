@@ -0,0 +1,85 @@
+package analyzer
+
+import "fmt"
+
+const (
+	defaultMinGram = 2
+	defaultMaxGram = 3
+)
+
+// ngrams cuts substring-style n-grams of minGram to maxGram characters.
+type ngrams struct {
+	minGram int
+	maxGram int
+}
+
+func newNgrams(minGram, maxGram int) (ngrams, error) {
+	if minGram == 0 && maxGram == 0 {
+		minGram, maxGram = defaultMinGram, defaultMaxGram
+	}
+
+	if minGram < 1 || maxGram < minGram {
+		return ngrams{}, fmt.Errorf("analyzer: n-grams need 1 <= min_gram <= max_gram, got %d and %d", minGram, maxGram)
+	}
+	return ngrams{minGram: minGram, maxGram: maxGram}, nil
+}
+
+// grams returns the n-grams of text, which starts at byte start of the
+// analyzed text. The grams starting at the same character share a position,
+// so the grams of text starting at character i are at position i.
+func (n ngrams) grams(text string, start int) []Token {
+	bounds := make([]int, 0, len(text)+1)
+	for i := range text {
+		bounds = append(bounds, i)
+	}
+	bounds = append(bounds, len(text))
+
+	tokens := []Token{}
+	for i := 0; i+1 < len(bounds); i++ {
+		increment := 1
+		for size := n.minGram; size <= n.maxGram && i+size < len(bounds); size++ {
+			from, to := bounds[i], bounds[i+size]
+			tokens = append(tokens, Token{Term: text[from:to], PositionIncrement: increment, Start: start + from, End: start + to})
+			increment = 0
+		}
+	}
+	return tokens
+}
+
+// Tokenize cuts the n-grams of the whole text, spaces and punctuation
+// included, one position per character, so that a query matches wherever its
+// text occurs as a substring.
+func (n ngrams) Tokenize(text string) []Token {
+	return n.grams(text, 0)
+}
+
+// Filter replaces every token with its n-grams. The grams of a token all take
+// its position, so a query matches tokens its text is a substring of.
+func (n ngrams) Filter(tokens []Token) []Token {
+	r := make([]Token, 0, len(tokens))
+	skipped := 0
+	for _, token := range tokens {
+		grams := n.grams(token.Term, token.Start)
+		if len(grams) == 0 {
+			// shorter than the smallest gram
+			skipped += token.PositionIncrement
+			continue
+		}
+
+		if len(token.Term) != token.End-token.Start {
+			// earlier filters changed the term, so its characters no longer
+			// map onto the text; every gram spans the whole token
+			for i := range grams {
+				grams[i].Start, grams[i].End = token.Start, token.End
+			}
+		}
+
+		for i := range grams {
+			grams[i].PositionIncrement = 0
+		}
+		grams[0].PositionIncrement = token.PositionIncrement + skipped
+		skipped = 0
+		r = append(r, grams...)
+	}
+	return r
+}
@@ -0,0 +1,75 @@
+package analyzer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCJKBigrams(t *testing.T) {
+	text := "東京都 in Japan, 日"
+	tokens := tokenizeCJK(text)
+
+	expected := []string{"東京", "京都", "in", "Japan", "日"}
+	if got := terms(tokens); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+
+	for _, token := range tokens {
+		if text[token.Start:token.End] != token.Term {
+			t.Fatalf("expected offsets of %q to cover it, got %q", token.Term, text[token.Start:token.End])
+		}
+	}
+
+	if got := DetectLanguage("東京都に住んでいます"); got != "cjk" {
+		t.Fatalf("expected cjk, got %q", got)
+	}
+}
+
+func TestNgramTokenizer(t *testing.T) {
+	n, err := newNgrams(2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokens := n.Tokenize("abcd")
+	expected := []Token{
+		{Term: "ab", PositionIncrement: 1, Start: 0, End: 2},
+		{Term: "abc", PositionIncrement: 0, Start: 0, End: 3},
+		{Term: "bc", PositionIncrement: 1, Start: 1, End: 3},
+		{Term: "bcd", PositionIncrement: 0, Start: 1, End: 4},
+		{Term: "cd", PositionIncrement: 1, Start: 2, End: 4},
+	}
+	if !reflect.DeepEqual(tokens, expected) {
+		t.Fatalf("expected %v, got %v", expected, tokens)
+	}
+
+	if _, err := newNgrams(3, 2); err == nil {
+		t.Fatal("expected min_gram above max_gram to fail")
+	}
+}
+
+func TestNgramFilterConfig(t *testing.T) {
+	config := `{
+		"filters": {"trigrams": {"type": "ngram", "min_gram": 3, "max_gram": 3}},
+		"analyzers": {"substring": {"tokenizer": "standard", "filters": ["lowercase", "trigrams"]}}
+	}`
+	if err := LoadConfig(strings.NewReader(config)); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := Get("substring")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokens := a.Analyze("Log of")
+	expected := []Token{{Term: "log", PositionIncrement: 1, Start: 0, End: 3}}
+	if !reflect.DeepEqual(tokens, expected) {
+		t.Fatalf("expected %v, got %v", expected, tokens)
+	}
+
+	if got := Positions(a.Analyze("raft logs")); !reflect.DeepEqual(got, []int{0, 0, 1, 1}) {
+		t.Fatalf("expected the grams of a word to share its position, got %v", got)
+	}
+}
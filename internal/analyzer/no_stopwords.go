@@ -0,0 +1,123 @@
+package analyzer
+
+var norwegian = map[string]string{
+	"alle":     "",
+	"andre":    "",
+	"arbeid":   "",
+	"av":       "",
+	"begge":    "",
+	"bort":     "",
+	"bra":      "",
+	"bruke":    "",
+	"da":       "",
+	"denne":    "",
+	"der":      "",
+	"deres":    "",
+	"det":      "",
+	"din":      "",
+	"disse":    "",
+	"du":       "",
+	"eller":    "",
+	"en":       "",
+	"ene":      "",
+	"eneste":   "",
+	"enhver":   "",
+	"enn":      "",
+	"er":       "",
+	"et":       "",
+	"folk":     "",
+	"for":      "",
+	"fordi":    "",
+	"forsøke":  "",
+	"fra":      "",
+	"få":       "",
+	"før":      "",
+	"først":    "",
+	"gjorde":   "",
+	"gjøre":    "",
+	"god":      "",
+	"gå":       "",
+	"ha":       "",
+	"hadde":    "",
+	"han":      "",
+	"hans":     "",
+	"hennes":   "",
+	"her":      "",
+	"hva":      "",
+	"hvem":     "",
+	"hver":     "",
+	"hvilken":  "",
+	"hvis":     "",
+	"hvor":     "",
+	"hvordan":  "",
+	"hvorfor":  "",
+	"i":        "",
+	"ikke":     "",
+	"inn":      "",
+	"innen":    "",
+	"kan":      "",
+	"kunne":    "",
+	"lage":     "",
+	"lang":     "",
+	"lik":      "",
+	"like":     "",
+	"makt":     "",
+	"mange":    "",
+	"med":      "",
+	"meg":      "",
+	"meget":    "",
+	"men":      "",
+	"mens":     "",
+	"mer":      "",
+	"mest":     "",
+	"min":      "",
+	"mye":      "",
+	"må":       "",
+	"måte":     "",
+	"navn":     "",
+	"nei":      "",
+	"ny":       "",
+	"nå":       "",
+	"når":      "",
+	"og":       "",
+	"også":     "",
+	"om":       "",
+	"opp":      "",
+	"oss":      "",
+	"over":     "",
+	"part":     "",
+	"punkt":    "",
+	"på":       "",
+	"rett":     "",
+	"riktig":   "",
+	"samme":    "",
+	"sant":     "",
+	"si":       "",
+	"siden":    "",
+	"sist":     "",
+	"skulle":   "",
+	"slik":     "",
+	"slutt":    "",
+	"som":      "",
+	"start":    "",
+	"stille":   "",
+	"så":       "",
+	"tid":      "",
+	"til":      "",
+	"tilbake":  "",
+	"tilstand": "",
+	"under":    "",
+	"ut":       "",
+	"uten":     "",
+	"var":      "",
+	"ved":      "",
+	"verdi":    "",
+	"vi":       "",
+	"vil":      "",
+	"ville":    "",
+	"vite":     "",
+	"vår":      "",
+	"våre":     "",
+	"vårt":     "",
+	"å":        "",
+}
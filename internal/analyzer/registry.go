@@ -0,0 +1,299 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// DefaultAnalyzer is the analyzer indexes use unless configured otherwise.
+const DefaultAnalyzer = "english"
+
+var ErrUnknownAnalyzer = errors.New("analyzer: unknown analyzer")
+
+// Definition names the tokenizer and filters an analyzer is built from, as
+// it appears in config.
+type Definition struct {
+	Tokenizer string   `json:"tokenizer"`
+	Filters   []string `json:"filters"`
+}
+
+// ComponentDefinition configures a tokenizer or filter of a parameterized
+// type, such as
+//
+//	{"type": "ngram", "min_gram": 3, "max_gram": 3}
+//	{"type": "ascii_folding", "preserve_original": true}
+//	{"type": "synonym", "synonyms_path": "synonyms.txt", "expand": "index"}
+//	{"type": "stop", "language": "english", "exclude": ["will", "can"]}
+//	{"type": "keyword_marker", "words_path": "protected.txt"}
+type ComponentDefinition struct {
+	Type             string `json:"type"`
+	MinGram          int    `json:"min_gram"`
+	MaxGram          int    `json:"max_gram"`
+	PreserveOriginal bool   `json:"preserve_original"`
+	SynonymsPath     string `json:"synonyms_path"`
+	// Expand is when synonyms are expanded, ExpandAtQuery (the default) or
+	// ExpandAtIndex.
+	Expand string `json:"expand"`
+	// Words, the words in WordsPath and, for stop filters, the stopwords of
+	// Language make up a word list, less the words in Exclude.
+	Words     []string `json:"words"`
+	WordsPath string   `json:"words_path"`
+	Language  string   `json:"language"`
+	Exclude   []string `json:"exclude"`
+}
+
+const (
+	// ExpandAtQuery rewrites queries into their synonyms. Changes to the
+	// synonyms apply to every document as soon as they are reloaded.
+	ExpandAtQuery = "query"
+	// ExpandAtIndex indexes the synonyms of a document's words alongside
+	// them. Queries stay short, but changes to the synonyms only apply to
+	// documents indexed after them.
+	ExpandAtIndex = "index"
+)
+
+// Config defines tokenizers, filters and the analyzers built from them by
+// name, e.g.
+//
+//	{
+//	  "tokenizers": {"trigrams": {"type": "ngram", "min_gram": 3, "max_gram": 3}},
+//	  "analyzers": {"substring": {"tokenizer": "trigrams", "filters": ["lowercase"]}}
+//	}
+type Config struct {
+	Tokenizers map[string]ComponentDefinition `json:"tokenizers"`
+	Filters    map[string]ComponentDefinition `json:"filters"`
+	Analyzers  map[string]Definition          `json:"analyzers"`
+}
+
+// englishAnalyzer is the default analyzer. Its filters are those of every
+// language, set up by init.
+var englishAnalyzer = &Analyzer{
+	Name:      DefaultAnalyzer,
+	Tokenizer: TokenizerFunc(tokenize),
+}
+
+var registry = struct {
+	sync.RWMutex
+	tokenizers map[string]Tokenizer
+	filters    map[string]TokenFilter
+	analyzers  map[string]*Analyzer
+	builtin    map[string]bool
+	synonyms   map[string]*SynonymSet
+}{
+	tokenizers: map[string]Tokenizer{
+		"standard":   TokenizerFunc(tokenize),
+		"whitespace": TokenizerFunc(tokenizeWhitespace),
+		"keyword":    TokenizerFunc(tokenizeKeyword),
+		"cjk":        TokenizerFunc(tokenizeCJK),
+		"ngram":      ngrams{minGram: defaultMinGram, maxGram: defaultMaxGram},
+	},
+	filters: map[string]TokenFilter{
+		"lowercase":                       TokenFilterFunc(lowercaseFilter),
+		"ngram":                           ngrams{minGram: defaultMinGram, maxGram: defaultMaxGram},
+		"nfkc":                            TokenFilterFunc(nfkcFilter),
+		"ascii_folding":                   folding{fold: foldASCII},
+		"diacritic_folding":               folding{fold: foldDiacritics},
+		"ascii_folding_preserve_original": folding{fold: foldASCII, preserveOriginal: true},
+	},
+	analyzers: map[string]*Analyzer{
+		DefaultAnalyzer: englishAnalyzer,
+		"standard": {
+			Name:       "standard",
+			Tokenizer:  TokenizerFunc(tokenize),
+			Filters:    []TokenFilter{TokenFilterFunc(lowercaseFilter)},
+			Definition: Definition{Tokenizer: "standard", Filters: []string{"lowercase"}},
+		},
+		"whitespace": {Name: "whitespace", Tokenizer: TokenizerFunc(tokenizeWhitespace), Definition: Definition{Tokenizer: "whitespace"}},
+		"keyword":    {Name: "keyword", Tokenizer: TokenizerFunc(tokenizeKeyword), Definition: Definition{Tokenizer: "keyword"}},
+		"cjk": {
+			Name:       "cjk",
+			Tokenizer:  TokenizerFunc(tokenizeCJK),
+			Filters:    []TokenFilter{TokenFilterFunc(lowercaseFilter)},
+			Definition: Definition{Tokenizer: "cjk", Filters: []string{"lowercase"}},
+		},
+		"folding": {
+			Name:      "folding",
+			Tokenizer: TokenizerFunc(tokenize),
+			Filters: []TokenFilter{
+				TokenFilterFunc(nfkcFilter),
+				TokenFilterFunc(lowercaseFilter),
+				folding{fold: foldASCII, preserveOriginal: true},
+			},
+			Definition: Definition{Tokenizer: "standard", Filters: []string{"nfkc", "lowercase", "ascii_folding_preserve_original"}},
+		},
+	},
+	builtin:  map[string]bool{DefaultAnalyzer: true, "standard": true, "whitespace": true, "keyword": true, "cjk": true, "folding": true},
+	synonyms: map[string]*SynonymSet{},
+}
+
+// Get returns the analyzer registered under name.
+func Get(name string) (*Analyzer, error) {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	a, ok := registry.analyzers[name]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownAnalyzer, name)
+	}
+	return a, nil
+}
+
+// Default returns the english analyzer.
+func Default() *Analyzer {
+	return englishAnalyzer
+}
+
+// RegisterTokenizer makes a tokenizer available to definitions under name.
+func RegisterTokenizer(name string, t Tokenizer) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.tokenizers[name] = t
+}
+
+// RegisterFilter makes a filter available to definitions under name.
+func RegisterFilter(name string, f TokenFilter) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.filters[name] = f
+}
+
+// DefineTokenizer builds a tokenizer of a parameterized type and makes it
+// available to definitions under name.
+func DefineTokenizer(name string, d ComponentDefinition) error {
+	switch d.Type {
+	case "ngram":
+		n, err := newNgrams(d.MinGram, d.MaxGram)
+		if err != nil {
+			return fmt.Errorf("analyzer: tokenizer %q: %w", name, err)
+		}
+		RegisterTokenizer(name, n)
+		return nil
+	}
+	return fmt.Errorf("analyzer: tokenizer %q: unknown type %q", name, d.Type)
+}
+
+// ReloadSynonyms rereads the file of every synonym filter, so that queries,
+// and documents indexed from now on, use the synonyms as they are now.
+func ReloadSynonyms() error {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	for name, set := range registry.synonyms {
+		if err := set.Reload(); err != nil {
+			return fmt.Errorf("analyzer: filter %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// DefineFilter builds a filter of a parameterized type and makes it available
+// to definitions under name.
+func DefineFilter(name string, d ComponentDefinition) error {
+	switch d.Type {
+	case "ngram":
+		n, err := newNgrams(d.MinGram, d.MaxGram)
+		if err != nil {
+			return fmt.Errorf("analyzer: filter %q: %w", name, err)
+		}
+		RegisterFilter(name, n)
+		return nil
+	case "ascii_folding":
+		RegisterFilter(name, folding{fold: foldASCII, preserveOriginal: d.PreserveOriginal})
+		return nil
+	case "diacritic_folding":
+		RegisterFilter(name, folding{fold: foldDiacritics, preserveOriginal: d.PreserveOriginal})
+		return nil
+	case "synonym":
+		if d.Expand != "" && d.Expand != ExpandAtQuery && d.Expand != ExpandAtIndex {
+			return fmt.Errorf("analyzer: filter %q: expand must be %q or %q, got %q", name, ExpandAtQuery, ExpandAtIndex, d.Expand)
+		}
+
+		set, err := LoadSynonyms(d.SynonymsPath)
+		if err != nil {
+			return fmt.Errorf("analyzer: filter %q: %w", name, err)
+		}
+		RegisterFilter(name, synonyms{set: set, expandAtIndex: d.Expand == ExpandAtIndex})
+
+		registry.Lock()
+		registry.synonyms[name] = set
+		registry.Unlock()
+		return nil
+	case "stop", "keyword_marker":
+		if d.Type == "keyword_marker" && d.Language != "" {
+			return fmt.Errorf("analyzer: filter %q: keyword_marker takes no language", name)
+		}
+
+		words, err := definitionWords(d)
+		if err != nil {
+			return fmt.Errorf("analyzer: filter %q: %w", name, err)
+		}
+
+		list := newWordList(words)
+		if d.Type == "stop" {
+			RegisterFilter(name, stopFilter{words: list})
+		} else {
+			RegisterFilter(name, keywordMarker{words: list})
+		}
+		return nil
+	}
+	return fmt.Errorf("analyzer: filter %q: unknown type %q", name, d.Type)
+}
+
+// Define builds an analyzer from d and registers it under name, replacing any
+// analyzer defined there before. The built-in analyzers cannot be replaced,
+// since indexes written with them must keep reading the same way.
+func Define(name string, d Definition) error {
+	registry.Lock()
+	defer registry.Unlock()
+
+	if registry.builtin[name] {
+		return fmt.Errorf("analyzer: %q is built in and cannot be redefined", name)
+	}
+
+	tokenizer, ok := registry.tokenizers[d.Tokenizer]
+	if !ok {
+		return fmt.Errorf("analyzer: %q: unknown tokenizer %q", name, d.Tokenizer)
+	}
+
+	a := &Analyzer{Name: name, Tokenizer: tokenizer, Definition: d}
+	for _, f := range d.Filters {
+		filter, ok := registry.filters[f]
+		if !ok {
+			return fmt.Errorf("analyzer: %q: unknown filter %q", name, f)
+		}
+		a.Filters = append(a.Filters, filter)
+	}
+
+	registry.analyzers[name] = a
+	return nil
+}
+
+// LoadConfig defines every tokenizer, filter and analyzer in a JSON Config.
+func LoadConfig(r io.Reader) error {
+	var config Config
+	if err := json.NewDecoder(r).Decode(&config); err != nil {
+		return fmt.Errorf("analyzer: reading config: %w", err)
+	}
+
+	for name, d := range config.Tokenizers {
+		if err := DefineTokenizer(name, d); err != nil {
+			return err
+		}
+	}
+
+	for name, d := range config.Filters {
+		if err := DefineFilter(name, d); err != nil {
+			return err
+		}
+	}
+
+	for name, d := range config.Analyzers {
+		if err := Define(name, d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package analyzer
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuiltinAnalyzers(t *testing.T) {
+	text := "The Raft-based logs, REPLICATED"
+
+	tests := map[string][]string{
+		"english":    {"raft", "base", "log", "replic"},
+		"standard":   {"the", "raft", "based", "logs", "replicated"},
+		"whitespace": {"The", "Raft-based", "logs,", "REPLICATED"},
+		"keyword":    {text},
+	}
+
+	for name, expected := range tests {
+		a, err := Get(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := a.Terms(text); !reflect.DeepEqual(got, expected) {
+			t.Fatalf("%s: expected %q, got %q", name, expected, got)
+		}
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	config := `{"analyzers": {"unstemmed": {"tokenizer": "standard", "filters": ["lowercase", "english_stop"]}}}`
+	if err := LoadConfig(strings.NewReader(config)); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := Get("unstemmed")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokens := a.Analyze("the logs are replicated")
+	expected := []Token{
+		{Term: "logs", PositionIncrement: 2, Start: 4, End: 8},
+		{Term: "replicated", PositionIncrement: 2, Start: 13, End: 23},
+	}
+	if !reflect.DeepEqual(tokens, expected) {
+		t.Fatalf("expected %v, got %v", expected, tokens)
+	}
+
+	if err := Define("english", Definition{Tokenizer: "keyword"}); err == nil {
+		t.Fatal("expected redefining a built-in analyzer to fail")
+	}
+
+	if err := Define("broken", Definition{Tokenizer: "standard", Filters: []string{"nope"}}); err == nil {
+		t.Fatal("expected an unknown filter to fail")
+	}
+
+	if _, err := Get("broken"); !errors.Is(err, ErrUnknownAnalyzer) {
+		t.Fatalf("expected ErrUnknownAnalyzer, got %v", err)
+	}
+}
+
+func TestStages(t *testing.T) {
+	a, err := Get("english")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stages := a.Stages("The Logs")
+	expected := []struct {
+		name  string
+		terms []string
+	}{
+		{"standard", []string{"The", "Logs"}},
+		{"lowercase", []string{"the", "logs"}},
+		{"english_stop", []string{"logs"}},
+		{"english_keywords", []string{"logs"}},
+		{"english_stem", []string{"log"}},
+	}
+
+	if len(stages) != len(expected) {
+		t.Fatalf("expected %d stages, got %v", len(expected), stages)
+	}
+	for i, e := range expected {
+		if stages[i].Name != e.name || !reflect.DeepEqual(terms(stages[i].Tokens), e.terms) {
+			t.Fatalf("stage %d: expected %s %q, got %s %q", i, e.name, e.terms, stages[i].Name, terms(stages[i].Tokens))
+		}
+	}
+
+	if last := stages[len(stages)-1].Tokens[0]; last.PositionIncrement != 2 || last.Start != 4 || last.End != 8 {
+		t.Fatalf("expected log at position 1, bytes 4 to 8, got %v", last)
+	}
+}
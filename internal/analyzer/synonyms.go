@@ -0,0 +1,408 @@
+package analyzer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MaxQueryRewrites caps how many alternative token streams synonyms may
+// rewrite a query into.
+const MaxQueryRewrites = 16
+
+// SynonymSet holds synonym rules read from a file in the Solr format,
+//
+//	# equivalent words and phrases, each matching all the others
+//	k8s, kubernetes
+//	ny, new york
+//	# explicit mappings, replacing the words on the left
+//	colour, colour's => color
+//
+// or from a WordNet prolog file, whose words sharing a synset are equivalent.
+// Rules are lowercased and split on spaces, so the synonym filter belongs
+// after the lowercase filter and before stopwords and stemming.
+type SynonymSet struct {
+	path string
+
+	mu sync.RWMutex
+	// rules are keyed by the first word they match
+	rules map[string][]synonymRule
+}
+
+// synonymRule replaces the words of input with any one of forms, which
+// include input itself unless the rule is an explicit mapping.
+type synonymRule struct {
+	input []string
+	forms [][]string
+}
+
+// replaces reports whether the rule takes the words it matches away.
+func (r synonymRule) replaces() bool {
+	for _, form := range r.forms {
+		if sameWords(form, r.input) {
+			return false
+		}
+	}
+	return true
+}
+
+func sameWords(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// LoadSynonyms reads the synonym rules in the file at path.
+func LoadSynonyms(path string) (*SynonymSet, error) {
+	s := &SynonymSet{path: path}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload reads the set's file again, replacing its rules once the whole file
+// has been read. Analysis running meanwhile sees either the old rules or the
+// new ones.
+func (s *SynonymSet) Reload() error {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return fmt.Errorf("analyzer: reading synonyms: %w", err)
+	}
+	defer f.Close()
+
+	rules, err := parseSynonyms(f)
+	if err != nil {
+		return fmt.Errorf("analyzer: reading synonyms from %s: %w", s.path, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rules = rules
+	return nil
+}
+
+func parseSynonyms(r io.Reader) (map[string][]synonymRule, error) {
+	inputs := map[string][]string{}
+	forms := map[string][][]string{}
+	synsets := map[string][][]string{}
+	synsetOrder := []string{}
+
+	add := func(input []string, outputs [][]string) {
+		key := strings.Join(input, " ")
+		inputs[key] = input
+		for _, output := range outputs {
+			duplicate := false
+			for _, form := range forms[key] {
+				duplicate = duplicate || sameWords(form, output)
+			}
+			if !duplicate {
+				forms[key] = append(forms[key], output)
+			}
+		}
+	}
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(text, "s(") {
+			id, words, err := parseWordNet(text)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			if _, ok := synsets[id]; !ok {
+				synsetOrder = append(synsetOrder, id)
+			}
+			synsets[id] = append(synsets[id], words)
+			continue
+		}
+
+		sides := strings.Split(text, "=>")
+		if len(sides) > 2 {
+			return nil, fmt.Errorf("line %d: more than one =>", line)
+		}
+
+		left := synonymPhrases(sides[0])
+		if len(left) == 0 {
+			return nil, fmt.Errorf("line %d: no synonyms", line)
+		}
+
+		if len(sides) == 1 {
+			for _, input := range left {
+				// the input itself comes first, so rewrites keep the query
+				// as written before its synonyms
+				add(input, append([][]string{input}, left...))
+			}
+			continue
+		}
+
+		right := synonymPhrases(sides[1])
+		if len(right) == 0 {
+			return nil, fmt.Errorf("line %d: nothing to map to", line)
+		}
+		for _, input := range left {
+			add(input, right)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, id := range synsetOrder {
+		words := synsets[id]
+		if len(words) < 2 {
+			continue
+		}
+		for _, input := range words {
+			add(input, append([][]string{input}, words...))
+		}
+	}
+
+	rules := map[string][]synonymRule{}
+	for key, input := range inputs {
+		rules[input[0]] = append(rules[input[0]], synonymRule{input: input, forms: forms[key]})
+	}
+
+	// longest first, so the longest rule matching wins
+	for _, r := range rules {
+		sort.SliceStable(r, func(i, j int) bool { return len(r[i].input) > len(r[j].input) })
+	}
+	return rules, nil
+}
+
+// synonymPhrases splits a comma-separated list of phrases into their words.
+func synonymPhrases(list string) [][]string {
+	phrases := [][]string{}
+	for _, phrase := range strings.Split(list, ",") {
+		if words := strings.Fields(strings.ToLower(phrase)); len(words) > 0 {
+			phrases = append(phrases, words)
+		}
+	}
+	return phrases
+}
+
+// parseWordNet parses a line of wn_s.pl, such as
+//
+//	s(100001740,1,'entity',n,1,11).
+//
+// into its synset id and words.
+func parseWordNet(line string) (string, []string, error) {
+	first := strings.IndexByte(line, '\'')
+	last := strings.LastIndexByte(line, '\'')
+	comma := strings.IndexByte(line, ',')
+	if first < 0 || last <= first || comma < 0 || comma > first {
+		return "", nil, fmt.Errorf("malformed WordNet entry %q", line)
+	}
+
+	word := strings.ReplaceAll(line[first+1:last], "''", "'")
+	return line[len("s("):comma], strings.Fields(strings.ToLower(word)), nil
+}
+
+// synonymMatch is a rule matching tokens seq[from:to] of a token stream,
+// where seq are the indices of its tokens that are not variants.
+type synonymMatch struct {
+	from, to int
+	rule     synonymRule
+}
+
+// match finds the rules matching tokens, longest first from left to right,
+// leaving out rules that keep the words they match unless all is set.
+func (s *SynonymSet) match(tokens []Token, seq []int, all bool) []synonymMatch {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	matches := []synonymMatch{}
+	for i := 0; i < len(seq); {
+		matched := false
+		for _, rule := range s.rules[tokens[seq[i]].Term] {
+			if !all && !rule.replaces() {
+				continue
+			}
+			if i+len(rule.input) > len(seq) {
+				continue
+			}
+
+			words := make([]string, len(rule.input))
+			for k := range words {
+				words[k] = tokens[seq[i+k]].Term
+			}
+			if sameWords(words, rule.input) {
+				matches = append(matches, synonymMatch{from: i, to: i + len(rule.input), rule: rule})
+				i += len(rule.input)
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			i++
+		}
+	}
+	return matches
+}
+
+// synonyms expands the words it matches into their synonyms, in documents
+// when expandAtIndex is set and in queries otherwise. Explicit mappings are
+// applied to both, so the words they replace are never indexed or searched.
+type synonyms struct {
+	set           *SynonymSet
+	expandAtIndex bool
+}
+
+func nonVariants(tokens []Token) []int {
+	seq := make([]int, 0, len(tokens))
+	for i, token := range tokens {
+		if !token.Variant {
+			seq = append(seq, i)
+		}
+	}
+	return seq
+}
+
+// Filter stacks the synonyms of the words it matches on top of them: each
+// form starts at the position of the words it stands for and takes one
+// position per word, so phrases written with either form match. The words
+// after them stay where they are, so a phrase running on from a form longer
+// or shorter than the text, such as "ny tonight" over "new york tonight",
+// does not match; expanding at query time has no such gap.
+func (f synonyms) Filter(tokens []Token) []Token {
+	seq := nonVariants(tokens)
+	matches := f.set.match(tokens, seq, f.expandAtIndex)
+	if len(matches) == 0 {
+		return tokens
+	}
+
+	type positioned struct {
+		position int
+		token    Token
+	}
+
+	positions := Positions(tokens)
+	dropped := map[int]bool{}
+	stacked := []positioned{}
+	for _, m := range matches {
+		span := seq[m.from:m.to]
+		replaced := m.rule.replaces()
+		if replaced {
+			for i := span[0]; i <= span[len(span)-1]; i++ {
+				dropped[i] = true
+			}
+		}
+
+		stacking := !replaced
+		for _, form := range m.rule.forms {
+			if sameWords(form, m.rule.input) {
+				continue
+			}
+
+			for k, word := range form {
+				// each word covers the text of the word it lines up with;
+				// the last one also covers any words left over
+				at := tokens[span[len(span)-1]]
+				if k < len(span) {
+					at = tokens[span[k]]
+				}
+				end := at.End
+				if k == len(form)-1 {
+					end = tokens[span[len(span)-1]].End
+				}
+
+				token := Token{Term: word, Start: at.Start, End: end, Variant: stacking || k > 0}
+				stacked = append(stacked, positioned{position: positions[span[0]] + k, token: token})
+			}
+			stacking = true
+		}
+	}
+
+	all := make([]positioned, 0, len(tokens)+len(stacked))
+	for i, token := range tokens {
+		if !dropped[i] {
+			all = append(all, positioned{position: positions[i], token: token})
+		}
+	}
+	all = append(all, stacked...)
+	sort.SliceStable(all, func(i, j int) bool { return all[i].position < all[j].position })
+
+	r := make([]Token, len(all))
+	previous := -1
+	for i, p := range all {
+		r[i] = p.token
+		r[i].PositionIncrement = p.position - previous
+		previous = p.position
+	}
+	return r
+}
+
+// Rewrite returns the query written with every combination of the forms of
+// the words it matches, the query as written first. Multi-word forms take a
+// position per word in their rewrite, so each rewrite can be matched as a
+// phrase.
+func (f synonyms) Rewrite(tokens []Token) [][]Token {
+	seq := nonVariants(tokens)
+	matches := f.set.match(tokens, seq, !f.expandAtIndex)
+	if len(matches) == 0 {
+		return [][]Token{tokens}
+	}
+
+	// copyTokens copies the tokens from seq[from] up to seq[to], variants
+	// included
+	copyTokens := func(r []Token, from, to int) []Token {
+		if from == to {
+			return r
+		}
+		end := len(tokens)
+		if to < len(seq) {
+			end = seq[to]
+		}
+		return append(r, tokens[seq[from]:end]...)
+	}
+
+	rewrites := [][]Token{{}}
+	done := 0
+	for _, m := range matches {
+		next := [][]Token{}
+		for _, r := range rewrites {
+			r = copyTokens(r, done, m.from)
+			for _, form := range m.rule.forms {
+				if len(next) == MaxQueryRewrites {
+					break
+				}
+
+				rewrite := append([]Token{}, r...)
+				if sameWords(form, m.rule.input) {
+					rewrite = copyTokens(rewrite, m.from, m.to)
+				} else {
+					first, last := tokens[seq[m.from]], tokens[seq[m.to-1]]
+					for k, word := range form {
+						increment := 1
+						if k == 0 {
+							increment = first.PositionIncrement
+						}
+						rewrite = append(rewrite, Token{Term: word, PositionIncrement: increment, Start: first.Start, End: last.End})
+					}
+				}
+				next = append(next, rewrite)
+			}
+		}
+		rewrites, done = next, m.to
+	}
+
+	for i := range rewrites {
+		rewrites[i] = copyTokens(rewrites[i], done, len(seq))
+	}
+	return rewrites
+}
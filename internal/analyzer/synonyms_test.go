@@ -0,0 +1,121 @@
+package analyzer
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeSynonyms(t *testing.T, rules string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "synonyms.txt")
+	if err := os.WriteFile(path, []byte(rules), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSynonymsAtIndex(t *testing.T) {
+	set, err := LoadSynonyms(writeSynonyms(t, "# comment\nk8s, kubernetes\nny, new york\ncolour => color\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := synonyms{set: set, expandAtIndex: true}
+	tokens := f.Filter(lowercaseFilter(tokenize("NY pizza k8s colour")))
+
+	expected := []Token{
+		{Term: "ny", PositionIncrement: 1, Start: 0, End: 2},
+		{Term: "new", PositionIncrement: 0, Start: 0, End: 2, Variant: true},
+		{Term: "pizza", PositionIncrement: 1, Start: 3, End: 8},
+		{Term: "york", PositionIncrement: 0, Start: 0, End: 2, Variant: true},
+		{Term: "k8s", PositionIncrement: 1, Start: 9, End: 12},
+		{Term: "kubernetes", PositionIncrement: 0, Start: 9, End: 12, Variant: true},
+		{Term: "color", PositionIncrement: 1, Start: 13, End: 19},
+	}
+	if !reflect.DeepEqual(tokens, expected) {
+		t.Fatalf("expected %v, got %v", expected, tokens)
+	}
+
+	// at query time only the explicit mapping applies
+	rewrites := f.Rewrite(lowercaseFilter(tokenize("ny colour")))
+	if len(rewrites) != 1 || !reflect.DeepEqual(terms(rewrites[0]), []string{"ny", "color"}) {
+		t.Fatalf("expected one rewrite, got %v", rewrites)
+	}
+}
+
+func TestSynonymsAtQuery(t *testing.T) {
+	set, err := LoadSynonyms(writeSynonyms(t, "ny, new york\nk8s, kubernetes\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := synonyms{set: set}
+	tokens := lowercaseFilter(tokenize("new york k8s"))
+	if got := f.Filter(tokens); !reflect.DeepEqual(got, tokens) {
+		t.Fatalf("expected documents to be left alone, got %v", got)
+	}
+
+	expected := [][]string{
+		{"new", "york", "k8s"},
+		{"new", "york", "kubernetes"},
+		{"ny", "k8s"},
+		{"ny", "kubernetes"},
+	}
+	rewrites := f.Rewrite(tokens)
+	got := [][]string{}
+	for _, r := range rewrites {
+		got = append(got, terms(r))
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+
+	if positions := Positions(rewrites[2]); !reflect.DeepEqual(positions, []int{0, 1}) {
+		t.Fatalf("expected the rewrite to take a position per word, got %v", positions)
+	}
+}
+
+func TestSynonymsReload(t *testing.T) {
+	path := writeSynonyms(t, "k8s, kubernetes\n")
+	if err := DefineFilter("test_synonyms", ComponentDefinition{Type: "synonym", SynonymsPath: path}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Define("test_synonyms", Definition{Tokenizer: "standard", Filters: []string{"lowercase", "test_synonyms"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := Get("test_synonyms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rewrites := a.AnalyzeQuery("vm"); len(rewrites) != 1 {
+		t.Fatalf("expected no synonyms, got %v", rewrites)
+	}
+
+	if err := os.WriteFile(path, []byte("vm, virtual machine\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ReloadSynonyms(); err != nil {
+		t.Fatal(err)
+	}
+	if rewrites := a.AnalyzeQuery("vm"); len(rewrites) != 2 || !reflect.DeepEqual(terms(rewrites[1]), []string{"virtual", "machine"}) {
+		t.Fatalf("expected the reloaded synonyms, got %v", rewrites)
+	}
+}
+
+func TestWordNetSynonyms(t *testing.T) {
+	rules, err := parseSynonyms(strings.NewReader("s(100001,1,'auto',n,1,0).\ns(100001,2,'motor car',n,1,0).\ns(100002,1,'o''clock',n,1,0).\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := rules["auto"]; len(got) != 1 || !reflect.DeepEqual(got[0].forms, [][]string{{"auto"}, {"motor", "car"}}) {
+		t.Fatalf("expected auto to match motor car, got %v", got)
+	}
+	if _, ok := rules["o'clock"]; ok {
+		t.Fatal("expected a synset of one word to have no synonyms")
+	}
+}
@@ -0,0 +1,238 @@
+package analyzer
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var ErrUnknownWordList = errors.New("analyzer: unknown word list")
+
+// WordList is the set of words a stop or keyword_marker filter acts on. It
+// can be replaced while in use, and analysis running meanwhile sees either
+// the old words or the new ones. The lists of a filter as defined are never
+// replaced; WithWords gives an analyzer lists of its own to replace.
+type WordList struct {
+	mu    sync.RWMutex
+	words map[string]string
+}
+
+func newWordList(words []string) *WordList {
+	l := &WordList{}
+	l.Set(words)
+	return l
+}
+
+// Set replaces the words of the list. Words are lowercased, since the
+// filters using them come after the lowercase filter.
+func (l *WordList) Set(words []string) {
+	set := make(map[string]string, len(words))
+	for _, word := range words {
+		if word = strings.ToLower(strings.TrimSpace(word)); word != "" {
+			set[word] = ""
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.words = set
+}
+
+// Words returns the words of the list in order.
+func (l *WordList) Words() []string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	words := make([]string, 0, len(l.words))
+	for word := range l.words {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+	return words
+}
+
+// WithWords returns a copy of a whose stop and keyword_marker filters each
+// have a word list of their own: words[name] for the filters words names,
+// and a copy of the filter's words for the others. The copy comes with
+// copies of the analyzer of every language, which documents and queries in
+// that language are analyzed with, sharing its lists by filter name. The
+// lists are returned by filter name, to be replaced without affecting a or
+// any other copy. The copies keep their names, so that indexes written with
+// either read the same way.
+func (a *Analyzer) WithWords(words map[string][]string) (*Analyzer, map[string]*WordList, error) {
+	lists := map[string]*WordList{}
+	c := a.withLists(words, lists)
+
+	c.languages = map[string]*Analyzer{}
+	for _, l := range languages {
+		if l.name == a.Name {
+			c.languages[l.name] = c
+			continue
+		}
+
+		base, err := Get(l.name)
+		if err != nil {
+			return nil, nil, err
+		}
+		c.languages[l.name] = base.withLists(words, lists)
+	}
+
+	for name := range words {
+		if _, ok := lists[name]; !ok {
+			return nil, nil, fmt.Errorf("%w: analyzer %q and the language analyzers have no stop or keyword_marker filter %q", ErrUnknownWordList, a.Name, name)
+		}
+	}
+	return c, lists, nil
+}
+
+// withLists returns a copy of a whose stop and keyword_marker filters use the
+// list of lists under their name, adding one for the filters without.
+func (a *Analyzer) withLists(words map[string][]string, lists map[string]*WordList) *Analyzer {
+	names := a.Definition.Filters
+	c := *a
+	c.Filters = make([]TokenFilter, len(a.Filters))
+	c.languages = nil
+
+	for i, f := range a.Filters {
+		name := ""
+		if i < len(names) {
+			name = names[i]
+		}
+
+		list := func(l *WordList) *WordList {
+			if _, ok := lists[name]; !ok {
+				w, ok := words[name]
+				if !ok {
+					w = l.Words()
+				}
+				lists[name] = newWordList(w)
+			}
+			return lists[name]
+		}
+
+		switch f := f.(type) {
+		case stopFilter:
+			c.Filters[i] = stopFilter{words: list(f.words)}
+		case keywordMarker:
+			c.Filters[i] = keywordMarker{words: list(f.words)}
+		default:
+			c.Filters[i] = f
+		}
+	}
+	return &c
+}
+
+// ForLanguage returns the analyzer of a supported language, the copy sharing
+// the word lists of a if a was made by WithWords.
+func (a *Analyzer) ForLanguage(nameOrCode string) (*Analyzer, error) {
+	name, err := Language(nameOrCode)
+	if err != nil {
+		return nil, err
+	}
+
+	if l, ok := a.languages[name]; ok {
+		return l, nil
+	}
+	return Get(name)
+}
+
+// readWords reads a word per line from the file at path, skipping blank lines
+// and comments starting with #.
+func readWords(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("analyzer: reading words: %w", err)
+	}
+	defer f.Close()
+
+	words := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		words = append(words, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("analyzer: reading words from %s: %w", path, err)
+	}
+	return words, nil
+}
+
+// definitionWords gathers the words of a stop or keyword_marker definition:
+// the stopwords of its language, if any, then its words and the words in its
+// file, less the words it excludes.
+func definitionWords(d ComponentDefinition) ([]string, error) {
+	words := []string{}
+	if d.Language != "" {
+		name, err := Language(d.Language)
+		if err != nil {
+			return nil, err
+		}
+		for _, l := range languages {
+			if l.name == name {
+				for word := range l.stopwords {
+					words = append(words, word)
+				}
+			}
+		}
+	}
+
+	words = append(words, d.Words...)
+	if d.WordsPath != "" {
+		read, err := readWords(d.WordsPath)
+		if err != nil {
+			return nil, err
+		}
+		words = append(words, read...)
+	}
+
+	excluded := map[string]bool{}
+	for _, word := range d.Exclude {
+		excluded[strings.ToLower(word)] = true
+	}
+
+	kept := words[:0]
+	for _, word := range words {
+		if !excluded[strings.ToLower(word)] {
+			kept = append(kept, word)
+		}
+	}
+	return kept, nil
+}
+
+// stopFilter removes the words of its list.
+type stopFilter struct {
+	words *WordList
+}
+
+func (f stopFilter) Filter(tokens []Token) []Token {
+	f.words.mu.RLock()
+	defer f.words.mu.RUnlock()
+	return removeStopwords(tokens, f.words.words)
+}
+
+// keywordMarker marks the words of its list as keywords, which stemmers leave
+// as they are.
+type keywordMarker struct {
+	words *WordList
+}
+
+func (f keywordMarker) Filter(tokens []Token) []Token {
+	f.words.mu.RLock()
+	defer f.words.mu.RUnlock()
+
+	r := make([]Token, len(tokens))
+	for i, token := range tokens {
+		if _, ok := f.words.words[token.Term]; ok {
+			token.Keyword = true
+		}
+		r[i] = token
+	}
+	return r
+}
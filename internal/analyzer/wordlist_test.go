@@ -0,0 +1,93 @@
+package analyzer
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCustomStopwordsAndKeywords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "protected.txt")
+	if err := os.WriteFile(path, []byte("# product names\nRunning\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := DefineFilter("test_stop", ComponentDefinition{Type: "stop", Language: "en", Exclude: []string{"will", "Can"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := DefineFilter("test_protected", ComponentDefinition{Type: "keyword_marker", WordsPath: path}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Define("test_products", Definition{Tokenizer: "standard", Filters: []string{"lowercase", "test_stop", "test_protected", "english_stem"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := Get("test_products")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"will", "can", "running", "shoe"}
+	if got := a.Terms("The Will Can running shoes"); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+
+	c, lists, err := a.WithWords(map[string][]string{"test_protected": {"Shoes"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lists["test_stop"].Set([]string{"Shoes"})
+
+	expected = []string{"the", "will", "can", "run"}
+	if got := c.Terms("The Will Can running shoes"); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+
+	// the analyzer copied keeps its words
+	expected = []string{"will", "can", "running", "shoe"}
+	if got := a.Terms("The Will Can running shoes"); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+
+	if _, _, err := a.WithWords(map[string][]string{"english_stem": nil}); !errors.Is(err, ErrUnknownWordList) {
+		t.Fatalf("expected ErrUnknownWordList, got %v", err)
+	}
+}
+
+func TestLanguageWordLists(t *testing.T) {
+	c, lists, err := Default().WithWords(map[string][]string{"english_keywords": {"running"}, "french_stop": {"imprimantes"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lists["english_stop"].Set([]string{"shoes"})
+
+	expected := []string{"the", "running"}
+	if got := c.Terms("The running shoes"); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+
+	// documents and queries in another language use the lists of the copy
+	french, err := c.ForLanguage("fr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []string{"le", "fonctionnent"}
+	if got := french.Terms("Les imprimantes fonctionnent"); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+
+	// the built-in analyzers keep their words
+	if got := Default().Terms("The running shoes"); !reflect.DeepEqual(got, []string{"run", "shoe"}) {
+		t.Fatalf("expected [run shoe], got %q", got)
+	}
+	builtin, err := ForLanguage("fr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []string{"le", "imprim", "fonctionnent"}
+	if got := builtin.Terms("Les imprimantes fonctionnent"); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+}
@@ -0,0 +1,411 @@
+package index
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// TermsAggregation counts the documents holding each value of a
+	// keyword, number or date field, most frequent first.
+	TermsAggregation = "terms"
+	// RangeAggregation counts the documents with a value of a number or date
+	// field in each of a list of ranges.
+	RangeAggregation = "range"
+	// HistogramAggregation counts the documents with a value of a number or
+	// date field in each interval of a fixed width.
+	HistogramAggregation = "histogram"
+	// StatsAggregation sums up the values of a number field.
+	StatsAggregation = "stats"
+)
+
+const defaultAggregationSize = 10
+
+// Aggregation summarizes a field over the documents a query matches in full
+// text and that pass its filter; semantic matches are left out. A query with
+// no text aggregates over the documents passing its filter.
+type Aggregation struct {
+	Type  string `json:"type"`
+	Field string `json:"field"`
+	// Size is how many of the most frequent values a terms aggregation
+	// keeps, 10 if zero.
+	Size int `json:"size,omitempty"`
+	// Ranges are the buckets of a range aggregation.
+	Ranges []AggregationRange `json:"ranges,omitempty"`
+	// Interval is the width of the buckets of a histogram: a number for
+	// number fields, and hour, day, week, month or year for date fields.
+	Interval string `json:"interval,omitempty"`
+}
+
+// AggregationRange is the values from From up to but not including To; a
+// missing bound leaves that side open.
+type AggregationRange struct {
+	Key  string      `json:"key,omitempty"`
+	From interface{} `json:"from,omitempty"`
+	To   interface{} `json:"to,omitempty"`
+}
+
+// key returns the range's key, or its bounds as in "10-20" or "*-20".
+func (r AggregationRange) key() string {
+	if r.Key != "" {
+		return r.Key
+	}
+
+	bound := func(v interface{}) string {
+		if v == nil {
+			return "*"
+		}
+		return fmt.Sprint(v)
+	}
+	return bound(r.From) + "-" + bound(r.To)
+}
+
+var dateIntervals = map[string]bool{"hour": true, "day": true, "week": true, "month": true, "year": true}
+
+// check reports whether the aggregation suits a field of s.
+func (a Aggregation) check(s *Schema) error {
+	f, ok := s.Field(a.Field)
+	if !ok {
+		return fmt.Errorf("%w: aggregation: unknown field %q", ErrInvalidQuery, a.Field)
+	}
+	if !f.Indexed() {
+		return fmt.Errorf("%w: aggregation: field %q is not indexed", ErrInvalidQuery, a.Field)
+	}
+
+	numeric := f.Type == NumberField || f.Type == DateField
+	switch {
+	case a.Type == TermsAggregation && f.Type != TextField:
+	case a.Type == RangeAggregation && numeric:
+		if len(a.Ranges) == 0 {
+			return fmt.Errorf("%w: aggregation: a range aggregation needs ranges", ErrInvalidQuery)
+		}
+		for _, r := range a.Ranges {
+			for _, bound := range []interface{}{r.From, r.To} {
+				if bound == nil {
+					continue
+				}
+				if _, err := f.term(bound); err != nil {
+					return fmt.Errorf("%w: aggregation: range of field %q: %s", ErrInvalidQuery, a.Field, err)
+				}
+			}
+		}
+	case a.Type == HistogramAggregation && f.Type == NumberField:
+		if interval, err := strconv.ParseFloat(a.Interval, 64); err != nil || interval <= 0 {
+			return fmt.Errorf("%w: aggregation: the interval of a number histogram must be a positive number, got %q", ErrInvalidQuery, a.Interval)
+		}
+	case a.Type == HistogramAggregation && f.Type == DateField:
+		if !dateIntervals[a.Interval] {
+			return fmt.Errorf("%w: aggregation: the interval of a date histogram must be hour, day, week, month or year, got %q", ErrInvalidQuery, a.Interval)
+		}
+	case a.Type == StatsAggregation && f.Type == NumberField:
+	case a.Type == TermsAggregation, a.Type == RangeAggregation, a.Type == HistogramAggregation, a.Type == StatsAggregation:
+		return fmt.Errorf("%w: aggregation: a %s aggregation does not take %s field %q", ErrInvalidQuery, a.Type, f.Type, a.Field)
+	default:
+		return fmt.Errorf("%w: aggregation: unknown type %q", ErrInvalidQuery, a.Type)
+	}
+
+	if a.Size < 0 {
+		return fmt.Errorf("%w: aggregation: size must not be negative", ErrInvalidQuery)
+	}
+	return nil
+}
+
+// Bucket is the number of documents with a value under Key.
+type Bucket struct {
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+}
+
+// Stats are the number of values of a field and their minimum, maximum, sum
+// and average. A document holding several values counts each.
+type Stats struct {
+	Count int     `json:"count"`
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Sum   float64 `json:"sum"`
+	Avg   float64 `json:"avg"`
+}
+
+type AggregationResult struct {
+	Buckets []Bucket `json:"buckets,omitempty"`
+	Stats   *Stats   `json:"stats,omitempty"`
+}
+
+// Aggregations are the results of the aggregations of a query by name.
+// Those of each memtable and segment, or of each shard, are partial: they
+// hold every bucket, and Merge adds them up. Finish then orders the buckets
+// and cuts terms to size, and must come last.
+type Aggregations map[string]*AggregationResult
+
+// Aggregate computes the aggregations of q over the documents of r it
+// matches.
+func Aggregate(r TermReader, q Query) Aggregations {
+	if len(q.Aggregations) == 0 {
+		return nil
+	}
+
+	q = q.withFilter(r)
+	var matches []fieldMatch
+	if strings.TrimSpace(q.Text) != "" {
+		matches = matchQuery(r, q)
+	}
+	return aggregate(r, q, matches)
+}
+
+// aggregate computes the aggregations of q, already resolved against r by
+// withFilter, over the documents of matches, its matches in r, or over the
+// documents passing its filter if it has no text.
+func aggregate(r TermReader, q Query, matches []fieldMatch) Aggregations {
+	docs := documentSet{}
+	if strings.TrimSpace(q.Text) == "" {
+		if q.passing != nil {
+			docs = q.passing.documents()
+		}
+	} else {
+		for _, m := range matches {
+			docs[m.Offsets[0].DocumentID] = true
+		}
+	}
+
+	aggs := Aggregations{}
+	for name, a := range q.Aggregations {
+		aggs[name] = a.compute(r, docs)
+	}
+	return aggs
+}
+
+// compute runs the aggregation over docs, walking the postings of every term
+// of its field.
+func (a Aggregation) compute(r TermReader, docs documentSet) *AggregationResult {
+	res := &AggregationResult{}
+	if a.Type == StatsAggregation {
+		res.Stats = &Stats{Min: math.Inf(1), Max: math.Inf(-1)}
+	}
+
+	// ranges keep a bucket, and a set of documents so each counts once per
+	// bucket, in order whether or not anything falls in them
+	buckets := []Bucket{}
+	bucketDocs := []documentSet{}
+	index := map[string]int{}
+	bucket := func(key string) int {
+		i, ok := index[key]
+		if !ok {
+			i = len(buckets)
+			index[key] = i
+			buckets = append(buckets, Bucket{Key: key})
+			bucketDocs = append(bucketDocs, documentSet{})
+		}
+		return i
+	}
+	if a.Type == RangeAggregation {
+		for _, rng := range a.Ranges {
+			bucket(rng.key())
+		}
+	}
+
+	f := scopedReader(r, a.Field)
+	if f != nil && len(docs) > 0 {
+		it := f.Terms("", "")
+		for it.Next() {
+			term := it.Term()
+			matched := matchingDocuments(f, term, docs)
+			if len(matched) == 0 {
+				continue
+			}
+
+			switch a.Type {
+			case TermsAggregation:
+				buckets[bucket(term)].Count += len(matched)
+			case RangeAggregation:
+				for _, rng := range a.Ranges {
+					if inRange(f.decl, rng, term) {
+						i := bucket(rng.key())
+						for _, doc := range matched {
+							bucketDocs[i][doc] = true
+						}
+					}
+				}
+			case HistogramAggregation:
+				key, ok := histogramKey(f.decl.Type, a.Interval, term)
+				if !ok {
+					continue
+				}
+				i := bucket(key)
+				for _, doc := range matched {
+					bucketDocs[i][doc] = true
+				}
+			case StatsAggregation:
+				v, err := strconv.ParseFloat(term, 64)
+				if err != nil {
+					continue
+				}
+				s := res.Stats
+				s.Count += len(matched)
+				s.Sum += v * float64(len(matched))
+				s.Min = math.Min(s.Min, v)
+				s.Max = math.Max(s.Max, v)
+			}
+		}
+	}
+
+	if a.Type == RangeAggregation || a.Type == HistogramAggregation {
+		for i := range buckets {
+			buckets[i].Count = len(bucketDocs[i])
+		}
+	}
+	if a.Type != StatsAggregation {
+		res.Buckets = buckets
+	}
+	return res
+}
+
+// matchingDocuments returns the documents of docs term occurs in.
+func matchingDocuments(p PostingsReader, term string, docs documentSet) []float64 {
+	withTerm := documentSet{}
+	addDocuments(withTerm, p, term)
+
+	matched := []float64{}
+	for doc := range withTerm {
+		if docs[doc] {
+			matched = append(matched, doc)
+		}
+	}
+	return matched
+}
+
+// inRange reports whether a term of a field holds a value within rng.
+func inRange(f Field, rng AggregationRange, term string) bool {
+	if rng.From != nil {
+		from, _ := f.term(rng.From)
+		if !(filterClause{op: filterGreaterOrEqual, value: from}).compares(f.Type, term) {
+			return false
+		}
+	}
+
+	if rng.To != nil {
+		to, _ := f.term(rng.To)
+		if !(filterClause{op: filterLess, value: to}).compares(f.Type, term) {
+			return false
+		}
+	}
+	return true
+}
+
+// histogramKey returns the start of the interval a term of a number or date
+// field falls in: a multiple of the interval for numbers, and the start of
+// the hour, day, week (from Monday), month or year in UTC for dates.
+func histogramKey(t FieldType, interval string, term string) (string, bool) {
+	if t == NumberField {
+		v, err := strconv.ParseFloat(term, 64)
+		width, wErr := strconv.ParseFloat(interval, 64)
+		if err != nil || wErr != nil {
+			return "", false
+		}
+		return strconv.FormatFloat(math.Floor(v/width)*width, 'f', -1, 64), true
+	}
+
+	d, err := parseDate(term)
+	if err != nil {
+		return "", false
+	}
+
+	d = d.UTC()
+	switch interval {
+	case "hour":
+		d = d.Truncate(time.Hour)
+	case "day":
+		d = time.Date(d.Year(), d.Month(), d.Day(), 0, 0, 0, 0, time.UTC)
+	case "week":
+		d = time.Date(d.Year(), d.Month(), d.Day()-(int(d.Weekday())+6)%7, 0, 0, 0, 0, time.UTC)
+	case "month":
+		d = time.Date(d.Year(), d.Month(), 1, 0, 0, 0, 0, time.UTC)
+	case "year":
+		d = time.Date(d.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return d.Format(time.RFC3339), true
+}
+
+// Merge adds the partial results of other to those of aggs.
+func (aggs Aggregations) Merge(other Aggregations) {
+	for name, res := range other {
+		total, ok := aggs[name]
+		if !ok {
+			total = &AggregationResult{}
+			if res.Stats != nil {
+				total.Stats = &Stats{Min: math.Inf(1), Max: math.Inf(-1)}
+			}
+			aggs[name] = total
+		}
+
+		if res.Stats != nil {
+			s := total.Stats
+			s.Count += res.Stats.Count
+			s.Sum += res.Stats.Sum
+			s.Min = math.Min(s.Min, res.Stats.Min)
+			s.Max = math.Max(s.Max, res.Stats.Max)
+		}
+
+		index := map[string]int{}
+		for i, b := range total.Buckets {
+			index[b.Key] = i
+		}
+		for _, b := range res.Buckets {
+			if i, ok := index[b.Key]; ok {
+				total.Buckets[i].Count += b.Count
+				continue
+			}
+			index[b.Key] = len(total.Buckets)
+			total.Buckets = append(total.Buckets, b)
+		}
+	}
+}
+
+// Finish orders the buckets of the aggregations of q, the most frequent
+// terms first and histograms by key, keeps the size of each terms
+// aggregation and averages stats.
+func (aggs Aggregations) Finish(q Query) {
+	for name, a := range q.Aggregations {
+		res, ok := aggs[name]
+		if !ok {
+			continue
+		}
+
+		switch a.Type {
+		case TermsAggregation:
+			sort.SliceStable(res.Buckets, func(i, j int) bool {
+				if res.Buckets[i].Count != res.Buckets[j].Count {
+					return res.Buckets[i].Count > res.Buckets[j].Count
+				}
+				return res.Buckets[i].Key < res.Buckets[j].Key
+			})
+
+			size := a.Size
+			if size == 0 {
+				size = defaultAggregationSize
+			}
+			if len(res.Buckets) > size {
+				res.Buckets = res.Buckets[:size]
+			}
+		case HistogramAggregation:
+			sort.SliceStable(res.Buckets, func(i, j int) bool {
+				x, xErr := strconv.ParseFloat(res.Buckets[i].Key, 64)
+				y, yErr := strconv.ParseFloat(res.Buckets[j].Key, 64)
+				if xErr == nil && yErr == nil {
+					return x < y
+				}
+				// dates, whose keys sort in time order
+				return res.Buckets[i].Key < res.Buckets[j].Key
+			})
+		case StatsAggregation:
+			if s := res.Stats; s.Count == 0 {
+				*s = Stats{}
+			} else {
+				s.Avg = s.Sum / float64(s.Count)
+			}
+		}
+	}
+}
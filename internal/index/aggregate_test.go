@@ -0,0 +1,96 @@
+package index
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/farouqzaib/fast-search/internal/analyzer"
+)
+
+func TestAggregate(t *testing.T) {
+	s, err := ParseSchema(strings.NewReader(`{"fields": {
+		"tags": {"type": "keyword"},
+		"views": {"type": "number"},
+		"created_at": {"type": "date"}
+	}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a memtable and a segment, whose partial results are merged
+	memtable := NewInvertedIndexWithSchema(analyzer.Default(), s)
+	memtable.IndexDocument(1, Document{Text: "raftone", Fields: map[string]interface{}{"tags": []interface{}{"logs", "raft"}, "views": 10.0, "created_at": "2026-01-01"}})
+	memtable.IndexDocument(2, Document{Text: "rafttwo", Fields: map[string]interface{}{"tags": "notes", "views": 25.0, "created_at": "2026-01-20T10:00:00Z"}})
+
+	flushed := NewInvertedIndexWithSchema(analyzer.Default(), s)
+	flushed.IndexDocument(3, Document{Text: "raftthree", Fields: map[string]interface{}{"tags": "logs", "views": 10.0, "created_at": "2026-02-03"}})
+	segment, err := OpenInvertedIndex(flushed.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	aggregate := func(q Query) Aggregations {
+		if err := q.ValidateFields(s); err != nil {
+			t.Fatal(err)
+		}
+
+		aggs := Aggregations{}
+		for _, r := range []TermReader{memtable, segment} {
+			aggs.Merge(Aggregate(r, q))
+		}
+		aggs.Finish(q)
+		return aggs
+	}
+
+	aggs := aggregate(Query{Text: "raft*", Aggregations: map[string]Aggregation{
+		"tags":  {Type: TermsAggregation, Field: "tags", Size: 2},
+		"views": {Type: RangeAggregation, Field: "views", Ranges: []AggregationRange{{To: 20.0}, {Key: "popular", From: 20.0}}},
+		"hist":  {Type: HistogramAggregation, Field: "views", Interval: "10"},
+		"month": {Type: HistogramAggregation, Field: "created_at", Interval: "month"},
+		"stats": {Type: StatsAggregation, Field: "views"},
+	}})
+
+	expected := Aggregations{
+		"tags":  {Buckets: []Bucket{{Key: "logs", Count: 2}, {Key: "notes", Count: 1}}},
+		"views": {Buckets: []Bucket{{Key: "*-20", Count: 2}, {Key: "popular", Count: 1}}},
+		"hist":  {Buckets: []Bucket{{Key: "10", Count: 2}, {Key: "20", Count: 1}}},
+		"month": {Buckets: []Bucket{{Key: "2026-01-01T00:00:00Z", Count: 2}, {Key: "2026-02-01T00:00:00Z", Count: 1}}},
+		"stats": {Stats: &Stats{Count: 3, Min: 10, Max: 25, Sum: 45, Avg: 15}},
+	}
+	for name, res := range expected {
+		if !reflect.DeepEqual(aggs[name], res) {
+			t.Fatalf("%s: expected %+v, got %+v", name, res, aggs[name])
+		}
+	}
+
+	// only the documents matching the query, or passing the filter of a
+	// query without text, are aggregated
+	tags := map[string]Aggregation{"tags": {Type: TermsAggregation, Field: "tags"}}
+	if got := aggregate(Query{Text: "raftone", Aggregations: tags})["tags"].Buckets; !reflect.DeepEqual(got, []Bucket{{Key: "logs", Count: 1}, {Key: "raft", Count: 1}}) {
+		t.Fatalf("expected the tags of document 1, got %v", got)
+	}
+	if got := aggregate(Query{Filter: "views >= 20", Aggregations: tags})["tags"].Buckets; !reflect.DeepEqual(got, []Bucket{{Key: "notes", Count: 1}}) {
+		t.Fatalf("expected the tags of document 2, got %v", got)
+	}
+
+	stats := aggregate(Query{Text: "missing", Aggregations: map[string]Aggregation{"stats": {Type: StatsAggregation, Field: "views"}}})
+	if *stats["stats"].Stats != (Stats{}) {
+		t.Fatalf("expected empty stats, got %+v", stats["stats"].Stats)
+	}
+
+	for _, bad := range []Aggregation{
+		{Type: "average", Field: "views"},
+		{Type: TermsAggregation, Field: "missing"},
+		{Type: StatsAggregation, Field: "tags"},
+		{Type: RangeAggregation, Field: "views"},
+		{Type: RangeAggregation, Field: "created_at", Ranges: []AggregationRange{{From: "soon"}}},
+		{Type: HistogramAggregation, Field: "views", Interval: "-1"},
+		{Type: HistogramAggregation, Field: "created_at", Interval: "fortnight"},
+	} {
+		if err := (Query{Aggregations: map[string]Aggregation{"bad": bad}}).ValidateFields(s); !errors.Is(err, ErrInvalidQuery) {
+			t.Fatalf("expected %+v to be invalid, got %v", bad, err)
+		}
+	}
+}
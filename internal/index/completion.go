@@ -0,0 +1,65 @@
+package index
+
+import (
+	"sort"
+	"strings"
+)
+
+// Completion is a word that completes a search-as-you-type prefix, with the
+// number of documents it occurs in.
+type Completion struct {
+	Text      string `json:"text"`
+	Frequency int    `json:"frequency"`
+}
+
+// CompletionReader lists the words of an index that start with a prefix.
+type CompletionReader interface {
+	Complete(prefix string) []Completion
+	// CompletionTerms returns the words starting with prefix in sorted
+	// order.
+	CompletionTerms(prefix string) TermIterator
+}
+
+// Complete returns the n most frequent completions of the last word of text
+// across readers. Earlier words are kept as typed, so "raft cons" completes
+// to "raft consensus".
+func Complete(readers []CompletionReader, text string, n int) []Completion {
+	text = strings.ToLower(text)
+	words := strings.Fields(text)
+	if len(words) == 0 || strings.TrimRight(text, " \t\n") != text {
+		return []Completion{}
+	}
+
+	leading := strings.Join(words[:len(words)-1], " ")
+	if leading != "" {
+		leading += " "
+	}
+
+	frequencies := map[string]int{}
+	for _, r := range readers {
+		for _, c := range r.Complete(words[len(words)-1]) {
+			frequencies[c.Text] += c.Frequency
+		}
+	}
+
+	completions := make([]Completion, 0, len(frequencies))
+	for word, frequency := range frequencies {
+		completions = append(completions, Completion{Text: word, Frequency: frequency})
+	}
+
+	sort.Slice(completions, func(i, j int) bool {
+		if completions[i].Frequency != completions[j].Frequency {
+			return completions[i].Frequency > completions[j].Frequency
+		}
+		return completions[i].Text < completions[j].Text
+	})
+
+	if len(completions) > n {
+		completions = completions[:n]
+	}
+
+	for i := range completions {
+		completions[i].Text = leading + completions[i].Text
+	}
+	return completions
+}
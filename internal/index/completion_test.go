@@ -0,0 +1,65 @@
+package index
+
+import (
+	"testing"
+
+	"github.com/farouqzaib/fast-search/internal/analyzer"
+)
+
+func TestComplete(t *testing.T) {
+	memtable := NewInvertedIndex()
+	memtable.Index(1, "Distributed consensus")
+	memtable.Index(2, "distribution of replicas")
+
+	flushed := NewInvertedIndex()
+	flushed.Index(3, "distributed systems, distributed logs")
+	flushed.Index(4, "distributed consensus")
+
+	segment, err := OpenInvertedIndex(flushed.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := Complete([]CompletionReader{memtable, segment}, "Distr", 10)
+	if len(got) != 2 {
+		t.Fatalf("expected 2 completions, got %v", got)
+	}
+
+	if got[0] != (Completion{Text: "distributed", Frequency: 3}) || got[1] != (Completion{Text: "distribution", Frequency: 1}) {
+		t.Fatalf("expected completions ranked by document count, got %v", got)
+	}
+
+	got = Complete([]CompletionReader{memtable, segment}, "raft cons", 1)
+	if len(got) != 1 || got[0].Text != "raft consensus" {
+		t.Fatalf("expected the leading words to be kept, got %v", got)
+	}
+
+	if got := Complete([]CompletionReader{memtable, segment}, "distr ", 10); len(got) != 0 {
+		t.Fatalf("expected nothing to complete after a space, got %v", got)
+	}
+}
+
+func TestCompleteUsesIndexAnalyzer(t *testing.T) {
+	french, err := analyzer.ForLanguage("french")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// french stopwords are dropped and english ones kept, and words are
+	// completed as written rather than stemmed
+	memtable := NewInvertedIndexWithAnalyzer(french)
+	memtable.Index(1, "La thèse de the Beatles")
+
+	segment, err := OpenInvertedIndex(memtable.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, r := range []CompletionReader{memtable, segment} {
+		for prefix, expected := range map[string]int{"la": 0, "th": 2, "beatles": 1} {
+			if got := r.Complete(prefix); len(got) != expected {
+				t.Fatalf("%s: expected %d completions, got %v", prefix, expected, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package index
+
+import (
+	"encoding/binary"
+	"math"
+	"sort"
+)
+
+// DocValues are the values of a field by document: the terms a keyword,
+// number or date field was indexed under, and none for text, which only
+// records that a document holds the field. Filters read the values of the
+// documents they are asked about rather than walking every term of a field
+// and its postings.
+type DocValues interface {
+	// Values returns the values of doc and whether it holds the field.
+	Values(doc float64) ([]string, bool)
+	// Documents returns the documents holding the field in ascending order.
+	Documents() []float64
+}
+
+// addDocValues records the values of a field of a document. The caller
+// holds the write lock.
+func (i *InvertedIndex) addDocValues(field string, docID int, values []string) {
+	if i.docValues == nil {
+		i.docValues = map[string]map[float64][]string{}
+	}
+
+	docs, ok := i.docValues[field]
+	if !ok {
+		docs = map[float64][]string{}
+		i.docValues[field] = docs
+	}
+	docs[float64(docID)] = append(docs[float64(docID)], values...)
+}
+
+// DocValues returns the values of field by document, the document text for
+// DocumentText.
+func (i *InvertedIndex) DocValues(field string) DocValues {
+	return memoryDocValues{i: i, field: field}
+}
+
+// memoryDocValues reads the values of a field of an index being written to,
+// under its lock.
+type memoryDocValues struct {
+	i     *InvertedIndex
+	field string
+}
+
+func (v memoryDocValues) Values(doc float64) ([]string, bool) {
+	v.i.mu.RLock()
+	defer v.i.mu.RUnlock()
+
+	values, ok := v.i.docValues[v.field][doc]
+	return values, ok
+}
+
+func (v memoryDocValues) Documents() []float64 {
+	v.i.mu.RLock()
+	defer v.i.mu.RUnlock()
+
+	docs := make([]float64, 0, len(v.i.docValues[v.field]))
+	for doc := range v.i.docValues[v.field] {
+		docs = append(docs, doc)
+	}
+	sort.Float64s(docs)
+	return docs
+}
+
+// The doc values of a segment are stored a field at a time, in order of
+// name:
+//
+//	field count                                          (uint32)
+//	per field: name length | document count |
+//	           values length                             (uint32s)
+//	           name
+//	           documents: document ID | values offset    (float64 bits as uint64, uint32), ascending
+//	           values:    value count, then value length | value
+//	                      (uvarints and bytes), per document
+//
+// The documents of a field are fixed width, so a document's values are found
+// by binary search without decoding those of other documents.
+const docValuesEntrySize = 12
+
+func encodeDocValues(fields map[string]map[float64][]string) []byte {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	b := binary.LittleEndian.AppendUint32(nil, uint32(len(names)))
+	for _, name := range names {
+		docs := make([]float64, 0, len(fields[name]))
+		for doc := range fields[name] {
+			docs = append(docs, doc)
+		}
+		sort.Float64s(docs)
+
+		entries := []byte{}
+		values := []byte{}
+		for _, doc := range docs {
+			entries = binary.LittleEndian.AppendUint64(entries, math.Float64bits(doc))
+			entries = binary.LittleEndian.AppendUint32(entries, uint32(len(values)))
+
+			values = binary.AppendUvarint(values, uint64(len(fields[name][doc])))
+			for _, v := range fields[name][doc] {
+				values = binary.AppendUvarint(values, uint64(len(v)))
+				values = append(values, v...)
+			}
+		}
+
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(name)))
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(docs)))
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(values)))
+		b = append(b, name...)
+		b = append(b, entries...)
+		b = append(b, values...)
+	}
+	return b
+}
+
+func openDocValues(b []byte) (map[string]mappedDocValues, error) {
+	if len(b) < 4 {
+		return nil, ErrCorruptSegment
+	}
+
+	n := int(binary.LittleEndian.Uint32(b[0:4]))
+	b = b[4:]
+
+	fields := make(map[string]mappedDocValues, n)
+	for f := 0; f < n; f++ {
+		if len(b) < 12 {
+			return nil, ErrCorruptSegment
+		}
+
+		nameLength := int(binary.LittleEndian.Uint32(b[0:4]))
+		count := int(binary.LittleEndian.Uint32(b[4:8]))
+		valuesLength := int(binary.LittleEndian.Uint32(b[8:12]))
+		b = b[12:]
+
+		size := nameLength + count*docValuesEntrySize + valuesLength
+		if size > len(b) {
+			return nil, ErrCorruptSegment
+		}
+
+		entriesStart := nameLength
+		valuesStart := entriesStart + count*docValuesEntrySize
+		fields[string(b[:nameLength])] = mappedDocValues{
+			count:   count,
+			entries: b[entriesStart:valuesStart],
+			values:  b[valuesStart:size],
+		}
+		b = b[size:]
+	}
+	return fields, nil
+}
+
+// mappedDocValues reads the values of a field straight from a segment.
+type mappedDocValues struct {
+	count   int
+	entries []byte
+	values  []byte
+}
+
+func (v mappedDocValues) document(n int) float64 {
+	return math.Float64frombits(binary.LittleEndian.Uint64(v.entries[n*docValuesEntrySize:]))
+}
+
+func (v mappedDocValues) Values(doc float64) ([]string, bool) {
+	n := sort.Search(v.count, func(i int) bool { return v.document(i) >= doc })
+	if n == v.count || v.document(n) != doc {
+		return nil, false
+	}
+
+	offset := int(binary.LittleEndian.Uint32(v.entries[n*docValuesEntrySize+8:]))
+	if offset > len(v.values) {
+		return nil, false
+	}
+
+	b := v.values[offset:]
+	count, k := binary.Uvarint(b)
+	if k <= 0 {
+		return nil, false
+	}
+	b = b[k:]
+
+	values := make([]string, 0, count)
+	for j := uint64(0); j < count; j++ {
+		length, k := binary.Uvarint(b)
+		if k <= 0 || uint64(len(b)-k) < length {
+			return nil, false
+		}
+		values = append(values, string(b[k:k+int(length)]))
+		b = b[k+int(length):]
+	}
+	return values, true
+}
+
+func (v mappedDocValues) Documents() []float64 {
+	docs := make([]float64, v.count)
+	for n := range docs {
+		docs[n] = v.document(n)
+	}
+	return docs
+}
@@ -0,0 +1,36 @@
+package index
+
+import (
+	"sort"
+	"strings"
+)
+
+// Documents returns the IDs of the documents of r that q matches in full
+// text and that pass its filter, in ascending order. A query without text
+// returns every document passing its filter, or, without a filter either,
+// every document of r, found by walking the postings of every term.
+func Documents(r TermReader, q Query) []float64 {
+	q = q.withFilter(r)
+
+	docs := documentSet{}
+	switch {
+	case strings.TrimSpace(q.Text) != "":
+		for _, m := range matchQuery(r, q) {
+			docs[m.Offsets[0].DocumentID] = true
+		}
+	case q.passing != nil:
+		docs = q.passing.documents()
+	default:
+		it := r.Terms("", "")
+		for it.Next() {
+			addDocuments(docs, r, it.Term())
+		}
+	}
+
+	ids := make([]float64, 0, len(docs))
+	for doc := range docs {
+		ids = append(ids, doc)
+	}
+	sort.Float64s(ids)
+	return ids
+}
@@ -0,0 +1,41 @@
+package index
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/farouqzaib/fast-search/internal/analyzer"
+)
+
+func TestDocuments(t *testing.T) {
+	s, err := ParseSchema(strings.NewReader(`{"fields": {"views": {"type": "number"}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idx := NewInvertedIndexWithSchema(analyzer.Default(), s)
+	idx.IndexDocument(3, Document{Text: "raft leader", Fields: map[string]interface{}{"views": 10.0}})
+	idx.IndexDocument(1, Document{Text: "gossip protocol", Fields: map[string]interface{}{"views": 25.0}})
+	idx.IndexDocument(2, Document{Fields: map[string]interface{}{"views": 5.0}})
+	segment, err := OpenInvertedIndex(idx.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, r := range []TermReader{idx, segment} {
+		for _, tc := range []struct {
+			q        Query
+			expected []float64
+		}{
+			{Query{}, []float64{1, 2, 3}},
+			{Query{Text: "raft"}, []float64{3}},
+			{Query{Filter: "views >= 10"}, []float64{1, 3}},
+			{Query{Text: "gossip", Filter: "views < 10"}, []float64{}},
+		} {
+			if got := Documents(r, tc.q); !reflect.DeepEqual(got, tc.expected) {
+				t.Fatalf("%+v: expected %v, got %v", tc.q, tc.expected, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package index
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/farouqzaib/fast-search/internal/analyzer"
+)
+
+// fieldSeparator joins the name of a field to the terms indexed under it, so
+// every field has postings of its own in the one term dictionary. The text of
+// a document, which predates fields, is indexed under bare terms, so indexes
+// written before fields read as they did.
+const fieldSeparator = "\x00"
+
+func fieldTerm(field, term string) string {
+	if field == "" {
+		return term
+	}
+	return field + fieldSeparator + term
+}
+
+// fieldReader reads the terms of one field of a TermReader as though they
+// were all it held, so that queries run over a field exactly as they do over
+// a whole index. The empty field is the document text.
+type fieldReader struct {
+	TermReader
+	field    string
+	decl     Field
+	analyzer *analyzer.Analyzer
+	// ownAnalyzer is set for fields with an analyzer of their own, which
+	// the language of a query does not replace.
+	ownAnalyzer bool
+	// boost multiplies the scores of matches in the field.
+	boost float64
+}
+
+// searchFields returns the fields of r queries search by default: the
+// document text and every indexed text field of r's schema.
+func searchFields(r TermReader) []*fieldReader {
+	fields := []*fieldReader{scopedReader(r, DocumentText)}
+	for _, name := range r.Schema().textFields() {
+		fields = append(fields, newFieldReader(r, name))
+	}
+	return fields
+}
+
+// scopedReader returns the field of r a query names, or nil if r's schema
+// does not declare it.
+func scopedReader(r TermReader, name string) *fieldReader {
+	if name == DocumentText {
+		return &fieldReader{TermReader: r, analyzer: r.Analyzer(), boost: 1}
+	}
+
+	if _, ok := r.Schema().Field(name); !ok {
+		return nil
+	}
+	return newFieldReader(r, name)
+}
+
+func newFieldReader(r TermReader, name string) *fieldReader {
+	decl, _ := r.Schema().Field(name)
+	f := &fieldReader{TermReader: r, field: name, decl: decl, analyzer: r.Analyzer(), boost: 1}
+
+	switch {
+	case decl.Type != TextField:
+		// fields indexed as is are searched as is
+		f.analyzer, _ = analyzer.Get("keyword")
+		f.ownAnalyzer = true
+	case decl.Analyzer != "":
+		if a, err := analyzer.Get(decl.Analyzer); err == nil {
+			f.analyzer = a
+			f.ownAnalyzer = true
+		}
+	}
+	return f
+}
+
+// queryText returns text as the term a number or date field indexes it
+// under, such as 2026-01-02T00:00:00Z for 2026-01-02, and other text as is.
+func (f *fieldReader) queryText(text string) string {
+	var value interface{} = text
+	switch f.decl.Type {
+	case NumberField:
+		n, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return text
+		}
+		value = n
+	case DateField:
+	default:
+		return text
+	}
+
+	term, err := f.decl.term(value)
+	if err != nil {
+		return text
+	}
+	return term
+}
+
+// SearchFields returns a TermReader for each field of r queries search, the
+// document text first.
+func SearchFields(r TermReader) []TermReader {
+	readers := []TermReader{}
+	for _, f := range searchFields(r) {
+		readers = append(readers, f)
+	}
+	return readers
+}
+
+func (f *fieldReader) Analyzer() *analyzer.Analyzer {
+	return f.analyzer
+}
+
+// fieldAnalyzer returns the field's own analyzer, or else a, the analyzer of
+// the document or query's language.
+func (f *fieldReader) fieldAnalyzer(a *analyzer.Analyzer) *analyzer.Analyzer {
+	if f.ownAnalyzer {
+		return f.analyzer
+	}
+	return a
+}
+
+func (f *fieldReader) First(token string) (Position, error) {
+	return f.TermReader.First(fieldTerm(f.field, token))
+}
+
+func (f *fieldReader) Last(token string) (Position, error) {
+	return f.TermReader.Last(fieldTerm(f.field, token))
+}
+
+func (f *fieldReader) Next(token string, offset Position) (Position, error) {
+	return f.TermReader.Next(fieldTerm(f.field, token), offset)
+}
+
+func (f *fieldReader) Previous(token string, offset Position) (Position, error) {
+	return f.TermReader.Previous(fieldTerm(f.field, token), offset)
+}
+
+func (f *fieldReader) DocumentFrequency(term string) int {
+	return f.TermReader.DocumentFrequency(fieldTerm(f.field, term))
+}
+
+func (f *fieldReader) Terms(lower, upper string) TermIterator {
+	if f.field == "" {
+		return &fieldIterator{it: f.TermReader.Terms(lower, upper)}
+	}
+
+	if upper == "" {
+		// the first term past every term of the field
+		return &fieldIterator{it: f.TermReader.Terms(fieldTerm(f.field, lower), f.field+"\x01"), prefix: fieldTerm(f.field, "")}
+	}
+	return &fieldIterator{it: f.TermReader.Terms(fieldTerm(f.field, lower), fieldTerm(f.field, upper)), prefix: fieldTerm(f.field, "")}
+}
+
+func (f *fieldReader) PrefixTerms(prefix string) TermIterator {
+	if f.field == "" {
+		return &fieldIterator{it: f.TermReader.PrefixTerms(prefix)}
+	}
+	return &fieldIterator{it: f.TermReader.PrefixTerms(fieldTerm(f.field, prefix)), prefix: fieldTerm(f.field, "")}
+}
+
+// fieldIterator strips the field name off the terms of a field. With no field
+// prefix it iterates over the document text, skipping the terms of fields.
+type fieldIterator struct {
+	it     TermIterator
+	prefix string
+	term   string
+}
+
+func (it *fieldIterator) Next() bool {
+	for it.it.Next() {
+		term := it.it.Term()
+		if it.prefix == "" && strings.Contains(term, fieldSeparator) {
+			continue
+		}
+
+		it.term = term[len(it.prefix):]
+		return true
+	}
+	return false
+}
+
+func (it *fieldIterator) Term() string {
+	return it.term
+}
+
+func (it *fieldIterator) Seek(term string) {
+	it.it.Seek(it.prefix + term)
+}
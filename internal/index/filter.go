@@ -0,0 +1,394 @@
+package index
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Filter operators. The comparisons compare numbers as numbers, dates in
+// time order and keywords as strings.
+const (
+	filterEqual          = "="
+	filterLess           = "<"
+	filterLessOrEqual    = "<="
+	filterGreater        = ">"
+	filterGreaterOrEqual = ">="
+	filterExists         = "exists"
+)
+
+// filterClause restricts a query to the documents whose field has a value
+// op value, or, for exists, any value at all.
+type filterClause struct {
+	field string
+	op    string
+	value string
+}
+
+// parseFilter parses clauses joined by AND, each either a comparison of a
+// keyword, number or date field to a value or exists(field), e.g.
+//
+//	tenant_id = 42 AND created_at > 2026-01-01 AND exists(tags)
+//
+// Values holding spaces or operators are written in double quotes, as in
+// tags = "machine learning".
+func parseFilter(text string) ([]filterClause, error) {
+	tokens, err := filterTokens(text)
+	if err != nil {
+		return nil, err
+	}
+
+	clauses := []filterClause{}
+	for len(tokens) > 0 {
+		if len(clauses) > 0 {
+			if !strings.EqualFold(tokens[0].text, "and") || tokens[0].quoted {
+				return nil, fmt.Errorf("%w: filter: expected AND, got %q", ErrInvalidQuery, tokens[0].text)
+			}
+			tokens = tokens[1:]
+		}
+
+		c, n, err := parseFilterClause(tokens)
+		if err != nil {
+			return nil, err
+		}
+		clauses = append(clauses, c)
+		tokens = tokens[n:]
+	}
+	return clauses, nil
+}
+
+// parseFilterClause parses the clause tokens start with, returning it and
+// the number of tokens it took.
+func parseFilterClause(tokens []filterToken) (filterClause, int, error) {
+	word := func(i int) string {
+		if i < len(tokens) {
+			return tokens[i].text
+		}
+		return ""
+	}
+
+	if strings.EqualFold(word(0), filterExists) && word(1) == "(" && word(3) == ")" {
+		if !isFieldName(word(2)) {
+			return filterClause{}, 0, fmt.Errorf("%w: filter: invalid field name %q", ErrInvalidQuery, word(2))
+		}
+		return filterClause{field: word(2), op: filterExists}, 4, nil
+	}
+
+	if len(tokens) < 3 || !isFieldName(word(0)) || tokens[0].quoted {
+		return filterClause{}, 0, fmt.Errorf("%w: filter: expected a field, an operator and a value or exists(field) near %q", ErrInvalidQuery, word(0))
+	}
+
+	switch word(1) {
+	case filterEqual, filterLess, filterLessOrEqual, filterGreater, filterGreaterOrEqual:
+	default:
+		return filterClause{}, 0, fmt.Errorf("%w: filter: unknown operator %q", ErrInvalidQuery, word(1))
+	}
+
+	if tokens[1].quoted || (!tokens[2].quoted && isFilterPunctuation(word(2))) {
+		return filterClause{}, 0, fmt.Errorf("%w: filter: expected a value after %s %s", ErrInvalidQuery, word(0), word(1))
+	}
+	return filterClause{field: word(0), op: word(1), value: word(2)}, 3, nil
+}
+
+type filterToken struct {
+	text   string
+	quoted bool
+}
+
+// filterTokens splits a filter into words, quoted values, parentheses and
+// comparison operators, which need no spaces around them.
+func filterTokens(text string) ([]filterToken, error) {
+	tokens := []filterToken{}
+	for i := 0; i < len(text); {
+		switch c := text[i]; {
+		case unicode.IsSpace(rune(c)):
+			i++
+		case c == '"':
+			end := i + 1
+			for end < len(text) && text[end] != '"' {
+				if text[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(text) {
+				return nil, fmt.Errorf("%w: filter: unterminated quoted value", ErrInvalidQuery)
+			}
+
+			value, err := strconv.Unquote(text[i : end+1])
+			if err != nil {
+				return nil, fmt.Errorf("%w: filter: %s", ErrInvalidQuery, err)
+			}
+			tokens = append(tokens, filterToken{text: value, quoted: true})
+			i = end + 1
+		case c == '(' || c == ')':
+			tokens = append(tokens, filterToken{text: string(c)})
+			i++
+		case c == '<' || c == '>' || c == '=':
+			end := i + 1
+			if c != '=' && end < len(text) && text[end] == '=' {
+				end++
+			}
+			tokens = append(tokens, filterToken{text: text[i:end]})
+			i = end
+		default:
+			end := i
+			for end < len(text) && !unicode.IsSpace(rune(text[end])) && !strings.ContainsRune(`"()<>=`, rune(text[end])) {
+				end++
+			}
+			tokens = append(tokens, filterToken{text: text[i:end]})
+			i = end
+		}
+	}
+	return tokens, nil
+}
+
+func isFilterPunctuation(s string) bool {
+	return s == "" || strings.ContainsAny(s[:1], "()<>=")
+}
+
+// check reports whether the clause can be run against a field of s: term and
+// range clauses against indexed keyword, number and date fields holding a
+// value of their type, exists against any indexed field.
+func (c filterClause) check(s *Schema) error {
+	if c.field == DocumentText {
+		if c.op != filterExists {
+			return fmt.Errorf("%w: filter: the document text only takes exists", ErrInvalidQuery)
+		}
+		return nil
+	}
+
+	f, ok := s.Field(c.field)
+	if !ok {
+		return fmt.Errorf("%w: filter: unknown field %q", ErrInvalidQuery, c.field)
+	}
+	if !f.Indexed() {
+		return fmt.Errorf("%w: filter: field %q is not indexed", ErrInvalidQuery, c.field)
+	}
+	if c.op == filterExists {
+		return nil
+	}
+
+	switch f.Type {
+	case NumberField:
+		if _, err := strconv.ParseFloat(c.value, 64); err != nil {
+			return fmt.Errorf("%w: filter: field %q takes numbers, got %q", ErrInvalidQuery, c.field, c.value)
+		}
+	case DateField:
+		if _, err := parseDate(c.value); err != nil {
+			return fmt.Errorf("%w: filter: field %q: %s", ErrInvalidQuery, c.field, err)
+		}
+	case KeywordField:
+	default:
+		return fmt.Errorf("%w: filter: %s field %q only takes exists", ErrInvalidQuery, f.Type, c.field)
+	}
+	return nil
+}
+
+// documentSet is a set of document IDs.
+type documentSet map[float64]bool
+
+// filter is a filter resolved against a TermReader. Equality clauses are
+// looked up once, in the postings of their term; range and exists clauses
+// are checked against the doc values of each document asked about, rather
+// than walking every term of their field.
+type filter struct {
+	// docs are the documents passing every equality clause, nil if the
+	// filter has none.
+	docs   documentSet
+	checks []filterCheck
+}
+
+// filterCheck is a range or exists clause and the doc values of its field.
+type filterCheck struct {
+	filterClause
+	fieldType FieldType
+	values    DocValues
+}
+
+// newFilter resolves clauses against r, or returns nil if there are none.
+func newFilter(r TermReader, clauses []filterClause) *filter {
+	if len(clauses) == 0 {
+		return nil
+	}
+
+	f := &filter{}
+	for _, c := range clauses {
+		fr := scopedReader(r, c.field)
+		if fr == nil {
+			// a field r does not declare lets nothing through
+			f.docs = documentSet{}
+			continue
+		}
+
+		if c.op != filterEqual {
+			f.checks = append(f.checks, filterCheck{filterClause: c, fieldType: fr.decl.Type, values: r.DocValues(c.field)})
+			continue
+		}
+
+		docs := documentSet{}
+		addDocuments(docs, fr, fr.queryText(c.value))
+		if f.docs == nil {
+			f.docs = docs
+			continue
+		}
+		for doc := range f.docs {
+			if !docs[doc] {
+				delete(f.docs, doc)
+			}
+		}
+	}
+	return f
+}
+
+// accepts reports whether doc passes every clause of the filter.
+func (f *filter) accepts(doc float64) bool {
+	if f.docs != nil && !f.docs[doc] {
+		return false
+	}
+
+	for _, c := range f.checks {
+		if !c.accepts(doc) {
+			return false
+		}
+	}
+	return true
+}
+
+// documents returns the documents passing the filter, checking those that
+// pass its equality clauses or else those holding the field of its first
+// range or exists clause.
+func (f *filter) documents() documentSet {
+	passing := documentSet{}
+	if f.docs != nil {
+		for doc := range f.docs {
+			if f.accepts(doc) {
+				passing[doc] = true
+			}
+		}
+		return passing
+	}
+
+	for _, doc := range f.checks[0].values.Documents() {
+		if f.accepts(doc) {
+			passing[doc] = true
+		}
+	}
+	return passing
+}
+
+// accepts reports whether doc holds the field of the clause and, unless it
+// is exists, a value comparing to the clause's as its operator asks.
+func (c filterCheck) accepts(doc float64) bool {
+	values, ok := c.values.Values(doc)
+	if !ok {
+		return false
+	}
+	if c.op == filterExists {
+		return true
+	}
+
+	for _, v := range values {
+		if c.compares(c.fieldType, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// compares reports whether a term of a field of type t holds a value that
+// compares to the clause's value as its operator asks.
+func (c filterClause) compares(t FieldType, term string) bool {
+	var cmp int
+	switch t {
+	case NumberField:
+		a, aErr := strconv.ParseFloat(term, 64)
+		b, bErr := strconv.ParseFloat(c.value, 64)
+		if aErr != nil || bErr != nil {
+			return false
+		}
+		cmp = compareFloats(a, b)
+	case DateField:
+		a, aErr := parseDate(term)
+		b, bErr := parseDate(c.value)
+		if aErr != nil || bErr != nil {
+			return false
+		}
+		switch {
+		case a.Before(b):
+			cmp = -1
+		case a.After(b):
+			cmp = 1
+		}
+	default:
+		cmp = strings.Compare(term, c.value)
+	}
+
+	switch c.op {
+	case filterLess:
+		return cmp < 0
+	case filterLessOrEqual:
+		return cmp <= 0
+	case filterGreater:
+		return cmp > 0
+	case filterGreaterOrEqual:
+		return cmp >= 0
+	}
+	return cmp == 0
+}
+
+func compareFloats(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// addDocuments adds every document term occurs in to docs, skipping from
+// each document to the next past its other occurrences.
+func addDocuments(docs documentSet, p PostingsReader, term string) {
+	pos, err := p.First(term)
+	for err == nil && pos.DocumentID < EOF {
+		docs[pos.DocumentID] = true
+		pos, err = p.Next(term, Position{DocumentID: pos.DocumentID, Offset: math.MaxFloat64})
+	}
+}
+
+// withFilter returns q along with its filter resolved against r, so the
+// terms of its equality clauses are looked up once however many times q runs
+// against r.
+func (q Query) withFilter(r TermReader) Query {
+	if q.Filter == "" || q.passing != nil {
+		return q
+	}
+
+	clauses, err := parseFilter(q.Filter)
+	if err != nil {
+		// an invalid filter lets nothing through
+		slog.Error("index: parsing filter", slog.String("error", err.Error()))
+		q.passing = &filter{docs: documentSet{}}
+		return q
+	}
+
+	q.passing = newFilter(r, clauses)
+	return q
+}
+
+// accepts reports whether doc passes the filter q was resolved against.
+func (q Query) accepts(doc float64) bool {
+	return q.passing == nil || q.passing.accepts(doc)
+}
+
+// acceptFunc returns accepts for vector search, or nil if q has no filter.
+func (q Query) acceptFunc() func(docID int) bool {
+	if q.passing == nil {
+		return nil
+	}
+	return func(docID int) bool { return q.passing.accepts(float64(docID)) }
+}
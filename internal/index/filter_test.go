@@ -0,0 +1,172 @@
+package index
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/farouqzaib/fast-search/internal/analyzer"
+)
+
+func TestParseFilter(t *testing.T) {
+	clauses, err := parseFilter(`tenant_id=42 and created_at > 2026-01-01 AND exists(tags) AND tags = "machine learning"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []filterClause{
+		{field: "tenant_id", op: filterEqual, value: "42"},
+		{field: "created_at", op: filterGreater, value: "2026-01-01"},
+		{field: "tags", op: filterExists},
+		{field: "tags", op: filterEqual, value: "machine learning"},
+	}
+	if len(clauses) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, clauses)
+	}
+	for i := range expected {
+		if clauses[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected[i], clauses[i])
+		}
+	}
+
+	for _, bad := range []string{"tenant_id", "tenant_id = ", "tenant_id ~ 42", "a = 1 b = 2", "a = 1 AND", `a = "1`, "exists(a:b)", "a = <"} {
+		if _, err := parseFilter(bad); !errors.Is(err, ErrInvalidQuery) {
+			t.Fatalf("expected %q to be invalid, got %v", bad, err)
+		}
+	}
+
+	s, err := ParseSchema(strings.NewReader(testSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := (Query{Filter: "views >= 10 AND created_at < 2026-02-01 AND exists(title) AND exists(text)"}).ValidateFields(s); err != nil {
+		t.Fatal(err)
+	}
+	for _, bad := range []string{"views > many", "created_at = yesterday", "title = raft", "missing = 1", "text = raft"} {
+		if err := (Query{Filter: bad}).ValidateFields(s); !errors.Is(err, ErrInvalidQuery) {
+			t.Fatalf("expected %q to be invalid, got %v", bad, err)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	s, err := ParseSchema(strings.NewReader(`{"fields": {
+		"tenant_id": {"type": "number"},
+		"created_at": {"type": "date"},
+		"tags": {"type": "keyword"}
+	}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// every value is a term of its own in one document, which filters must
+	// tell apart by value
+	idx := NewInvertedIndexWithSchema(analyzer.Default(), s)
+	for i, fields := range []map[string]interface{}{
+		{"tenant_id": 42.0, "created_at": "2025-12-31", "tags": "notes"},
+		{"tenant_id": 42.5, "created_at": "2026-01-02T10:00:00.5+01:00"},
+		{"tenant_id": 7.0, "created_at": "2026-03-01"},
+		{"tenant_id": 100.0, "created_at": "2026-03-02", "tags": []interface{}{"raft", "logs"}},
+	} {
+		text := []string{"raftone", "rafttwo", "raftthree", "raftfour"}[i]
+		if err := idx.IndexDocument(i+1, Document{Text: text, Fields: fields}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mapped, err := OpenInvertedIndex(idx.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, r := range []TermReader{idx, mapped, idx.Decode(idx.Encode())} {
+		// filters look up the terms they compare to and read the values of
+		// documents, without walking the terms of a field
+		walking := &walkingReader{TermReader: r}
+		for filter, expected := range map[string][]float64{
+			"tenant_id = 42.0":                           {1},
+			"tenant_id > 42 AND created_at > 2026-01-01": {2, 4},
+			"tenant_id > 8":                              {1, 2, 4},
+			"tenant_id <= 42.5":                          {1, 2, 3},
+			"created_at < 2026-01-02T09:00:00.6Z":        {1, 2},
+			"created_at >= 2026-03-01":                   {3, 4},
+			"exists(tags)":                               {1, 4},
+			"exists(text) AND tenant_id < 50":            {1, 2, 3},
+			"tags = logs AND tags = raft":                {4},
+			"tags > o":                                   {4},
+			"tenant_id = 1":                              {},
+		} {
+			clauses, err := parseFilter(filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			f := newFilter(walking, clauses)
+			passing := f.documents()
+			if len(passing) != len(expected) {
+				t.Fatalf("%q: expected %v, got %v", filter, expected, passing)
+			}
+			for _, doc := range expected {
+				if !passing[doc] {
+					t.Fatalf("%q: expected %v, got %v", filter, expected, passing)
+				}
+			}
+			for doc := 1.0; doc <= 4; doc++ {
+				if f.accepts(doc) != passing[doc] {
+					t.Fatalf("%q: document %v: expected accepts to agree with %v", filter, doc, passing)
+				}
+			}
+		}
+		if walking.walked > 0 {
+			t.Fatalf("expected filters not to walk terms, walked %d times", walking.walked)
+		}
+
+		// the filter applies before the cut, so k matches still come back
+		matches := rankQuery(r, Query{Text: "raft*", Filter: "tenant_id >= 42 AND exists(created_at)"}, 2)
+		if len(matches) != 2 || matches[0].Offsets[0].DocumentID != 1 || matches[1].Offsets[0].DocumentID != 2 {
+			t.Fatalf("expected the first two matching documents, got %v", matches)
+		}
+		if matches := rankQuery(r, Query{Text: "raft*", Filter: "tenant_id = 7"}, 1); len(matches) != 1 || matches[0].Offsets[0].DocumentID != 3 {
+			t.Fatalf("expected document 3, got %v", matches)
+		}
+	}
+}
+
+func TestHNSWFilter(t *testing.T) {
+	hnsw := NewHNSW(5, 0.62, 8, 16)
+	vectors := []VectorNode{}
+	for i := 0; i < 500; i++ {
+		v := randomPoint()
+		v.ID = i
+		vectors = append(vectors, v)
+	}
+	hnsw.Create(vectors)
+
+	rare := func(docID int) bool { return docID%50 == 0 }
+	matches := hnsw.Search(randomPoint(), 10, rare)
+	if len(matches) != 10 {
+		t.Fatalf("expected every one of the 10 accepted documents, got %v", matches)
+	}
+	for _, m := range matches {
+		if !rare(int(m.Offsets[0].DocumentID)) {
+			t.Fatalf("expected only accepted documents, got %v", matches)
+		}
+	}
+}
+
+// walkingReader counts the term iterators asked of it.
+type walkingReader struct {
+	TermReader
+	walked int
+}
+
+func (r *walkingReader) Terms(lower, upper string) TermIterator {
+	r.walked++
+	return r.TermReader.Terms(lower, upper)
+}
+
+func (r *walkingReader) PrefixTerms(prefix string) TermIterator {
+	r.walked++
+	return r.TermReader.PrefixTerms(prefix)
+}
@@ -0,0 +1,161 @@
+package index
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	DefaultFragmentSize      = 100
+	DefaultNumberOfFragments = 3
+	DefaultPreTag            = "<em>"
+	DefaultPostTag           = "</em>"
+)
+
+// HighlightOptions shape the snippets Highlight cuts from a document. Zero
+// values take the defaults above.
+type HighlightOptions struct {
+	// FragmentSize is the length in bytes a snippet is padded out to around
+	// its matches.
+	FragmentSize      int
+	NumberOfFragments int
+	PreTag            string
+	PostTag           string
+}
+
+func (o HighlightOptions) withDefaults() HighlightOptions {
+	if o.FragmentSize <= 0 {
+		o.FragmentSize = DefaultFragmentSize
+	}
+	if o.NumberOfFragments <= 0 {
+		o.NumberOfFragments = DefaultNumberOfFragments
+	}
+	if o.PreTag == "" && o.PostTag == "" {
+		o.PreTag, o.PostTag = DefaultPreTag, DefaultPostTag
+	}
+	return o
+}
+
+type fragment struct {
+	start, end int
+	spans      []Position
+}
+
+// Highlight cuts snippets of text around the byte ranges of positions, such
+// as the Highlights of a Match, wrapping each range in the pre and post tags.
+// Matches close enough to fit in one fragment share it; the fragments with
+// the most matches are returned, in document order.
+func Highlight(text string, positions []Position, opts HighlightOptions) []string {
+	opts = opts.withDefaults()
+
+	spans := make([]Position, 0, len(positions))
+	for _, p := range positions {
+		if p.Start < p.End && p.End <= len(text) {
+			spans = append(spans, p)
+		}
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].Start < spans[j].Start })
+
+	fragments := []fragment{}
+	for _, span := range spans {
+		if n := len(fragments); n > 0 {
+			f := &fragments[n-1]
+			if span.Start < f.end {
+				// overlaps the previous match
+				continue
+			}
+
+			if span.End-f.start <= opts.FragmentSize {
+				f.end = span.End
+				f.spans = append(f.spans, span)
+				continue
+			}
+		}
+
+		fragments = append(fragments, fragment{start: span.Start, end: span.End, spans: []Position{span}})
+	}
+
+	sort.SliceStable(fragments, func(i, j int) bool { return len(fragments[i].spans) > len(fragments[j].spans) })
+	if len(fragments) > opts.NumberOfFragments {
+		fragments = fragments[:opts.NumberOfFragments]
+	}
+	sort.Slice(fragments, func(i, j int) bool { return fragments[i].start < fragments[j].start })
+
+	snippets := make([]string, len(fragments))
+	for n, f := range fragments {
+		snippets[n] = f.render(text, opts)
+	}
+	return snippets
+}
+
+// render pads the fragment out to the fragment size with the text on either
+// side of its matches, trimming back to whole words.
+func (f fragment) render(text string, opts HighlightOptions) string {
+	padding := opts.FragmentSize - (f.end - f.start)
+	if padding < 0 {
+		padding = 0
+	}
+
+	start := f.start - padding/2
+	if start < 0 {
+		start = 0
+	}
+	end := start + opts.FragmentSize
+	if end < f.end {
+		end = f.end
+	}
+	if end > len(text) {
+		end = len(text)
+	}
+
+	start = wordStart(text, start, f.start)
+	end = wordEnd(text, end, f.end)
+
+	var b strings.Builder
+	at := start
+	for _, span := range f.spans {
+		b.WriteString(text[at:span.Start])
+		b.WriteString(opts.PreTag)
+		b.WriteString(text[span.Start:span.End])
+		b.WriteString(opts.PostTag)
+		at = span.End
+	}
+	b.WriteString(text[at:end])
+
+	return strings.TrimSpace(b.String())
+}
+
+// wordStart moves i forward, no further than limit, to just after a space.
+// Spaces are single bytes, so wherever it stops is a rune boundary.
+func wordStart(text string, i, limit int) int {
+	if i == 0 {
+		return 0
+	}
+
+	for ; i < limit; i++ {
+		if isSpace(text[i-1]) {
+			return i
+		}
+	}
+	return limit
+}
+
+// wordEnd moves i back, no further than limit, to a space.
+func wordEnd(text string, i, limit int) int {
+	if i == len(text) {
+		return i
+	}
+
+	for ; i > limit; i-- {
+		if isSpace(text[i]) {
+			return i
+		}
+	}
+	return limit
+}
+
+func isSpace(b byte) bool {
+	return b < utf8.RuneSelf && unicode.IsSpace(rune(b))
+}
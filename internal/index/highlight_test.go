@@ -0,0 +1,58 @@
+package index
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHighlight(t *testing.T) {
+	text := "Raft is a consensus algorithm. It was designed to be easy to understand, unlike Paxos, and Raft is used widely."
+
+	idx := NewInvertedIndex()
+	idx.Index(1, text)
+
+	matches := idx.Rank(Query{Text: "raft"}, 10)
+	if len(matches) != 1 {
+		t.Fatalf("expected 1 match, got %d", len(matches))
+	}
+
+	highlights := matches[0].Highlights
+	if len(highlights) != 2 {
+		t.Fatalf("expected 2 highlighted positions, got %v", highlights)
+	}
+
+	for _, p := range highlights {
+		if got := text[p.Start:p.End]; got != "Raft" {
+			t.Fatalf("expected span to cover Raft, got %q", got)
+		}
+	}
+
+	snippets := Highlight(text, highlights, HighlightOptions{FragmentSize: 40, PreTag: "[", PostTag: "]"})
+	expected := []string{"[Raft] is a consensus algorithm. It was", "unlike Paxos, and [Raft] is used widely."}
+	if !reflect.DeepEqual(snippets, expected) {
+		t.Fatalf("expected %q, got %q", expected, snippets)
+	}
+
+	// a fragment big enough for both matches holds them together
+	snippets = Highlight(text, highlights, HighlightOptions{FragmentSize: 200})
+	if len(snippets) != 1 || strings.Count(snippets[0], "<em>Raft</em>") != 2 {
+		t.Fatalf("expected one snippet with both matches, got %q", snippets)
+	}
+}
+
+func TestHighlightMostMatchedFragments(t *testing.T) {
+	text := "alpha beta gamma delta alpha epsilon zeta eta theta iota kappa alpha alpha"
+	spans := []Position{
+		{Start: 0, End: 5},
+		{Start: 23, End: 28},
+		{Start: 63, End: 68},
+		{Start: 69, End: 74},
+	}
+
+	snippets := Highlight(text, spans, HighlightOptions{FragmentSize: 12, NumberOfFragments: 1})
+	expected := []string{"<em>alpha</em> <em>alpha</em>"}
+	if !reflect.DeepEqual(snippets, expected) {
+		t.Fatalf("expected %q, got %q", expected, snippets)
+	}
+}
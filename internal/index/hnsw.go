@@ -3,9 +3,11 @@ package index
 import (
 	"bytes"
 	"container/heap"
-	"encoding/gob"
+	"encoding/binary"
+	"fmt"
 	"math"
 	"math/rand"
+	"sync"
 )
 
 type maxHeap []Candidate
@@ -68,7 +70,10 @@ type Candidate struct {
 	Entry    int
 }
 
+// HNSW is the in-memory graph of a memtable. Inserts take it exclusively
+// and searches share it, so it may be searched while it is being built.
 type HNSW struct {
+	mu    sync.RWMutex
 	L     int
 	mL    float64
 	M     int
@@ -88,11 +93,33 @@ func NewHNSW(L int, mL float64, m int, efc int) *HNSW {
 	}
 }
 
-func (hnsw *HNSW) searchLayer(graph Graph, entry int, query VectorNode, ef int) []Candidate {
-	candidate := Candidate{distance(query.Vector, graph.Elements[entry].Vector), entry}
+// layer is one level of the graph. It is implemented by the in-memory Graph
+// and by the memory-mapped layers of a segment so both share one search.
+type layer interface {
+	size() int
+	vector(i int) []float64
+	neighbours(i int) []int
+	entry(i int) int
+	id(i int) int
+}
 
-	nearestNeighbours := &maxHeap{candidate}
-	heap.Init(nearestNeighbours)
+func (g Graph) size() int              { return len(g.Elements) }
+func (g Graph) vector(i int) []float64 { return g.Elements[i].Vector }
+func (g Graph) neighbours(i int) []int { return g.Elements[i].Indices }
+func (g Graph) entry(i int) int        { return g.Elements[i].Entry }
+func (g Graph) id(i int) int           { return g.Elements[i].ID }
+
+// searchLayer returns the ef nearest neighbours of query in graph whose
+// documents accept takes, or of all of them for a nil accept. The others are
+// still walked through, so neighbours behind them are found, and the search
+// goes on until ef are found or the graph runs out.
+func searchLayer(graph layer, entry int, query VectorNode, ef int, accept func(docID int) bool) []Candidate {
+	candidate := Candidate{distance(query.Vector, graph.vector(entry)), entry}
+
+	nearestNeighbours := &maxHeap{}
+	if accept == nil || accept(graph.id(entry)) {
+		heap.Push(nearestNeighbours, candidate)
+	}
 
 	visited := make(map[int]map[float64]bool)
 	visited[candidate.Entry] = map[float64]bool{candidate.Distance: true}
@@ -103,12 +130,12 @@ func (hnsw *HNSW) searchLayer(graph Graph, entry int, query VectorNode, ef int)
 	for candidateHeap.Len() > 0 {
 		current := heap.Pop(candidateHeap).(Candidate)
 
-		if current.Distance > (*nearestNeighbours)[0].Distance {
+		if nearestNeighbours.Len() > 0 && current.Distance > (*nearestNeighbours)[0].Distance && (accept == nil || nearestNeighbours.Len() >= ef) {
 			break
 		}
 
-		for _, e := range graph.Elements[current.Entry].Indices {
-			d := distance(query.Vector, graph.Elements[e].Vector)
+		for _, e := range graph.neighbours(current.Entry) {
+			d := distance(query.Vector, graph.vector(e))
 
 			if val, ok := visited[e]; ok {
 				if val[d] {
@@ -122,8 +149,12 @@ func (hnsw *HNSW) searchLayer(graph Graph, entry int, query VectorNode, ef int)
 				visited[e] = map[float64]bool{d: true}
 			}
 
-			if d < (*nearestNeighbours)[0].Distance || nearestNeighbours.Len() < ef {
+			if nearestNeighbours.Len() < ef || d < (*nearestNeighbours)[0].Distance {
 				heap.Push(candidateHeap, Candidate{Distance: d, Entry: e})
+				if accept != nil && !accept(graph.id(e)) {
+					continue
+				}
+
 				heap.Push(nearestNeighbours, Candidate{Distance: d, Entry: e})
 				if nearestNeighbours.Len() > ef {
 					_ = heap.Pop(nearestNeighbours)
@@ -135,6 +166,37 @@ func (hnsw *HNSW) searchLayer(graph Graph, entry int, query VectorNode, ef int)
 	return *nearestNeighbours
 }
 
+// searchLayers descends from the top layer to the first one without a lower
+// entry point and returns the ef nearest neighbours found there that accept
+// takes.
+func searchLayers(layers []layer, query VectorNode, ef int, accept func(docID int) bool) []Match {
+	if len(layers) == 0 || layers[0].size() == 0 {
+		return []Match{}
+	}
+
+	bestNode := 0
+	for _, graph := range layers {
+		nn := searchLayer(graph, bestNode, query, 1, nil)[0]
+		bestNode = nn.Entry
+		if graph.entry(bestNode) > 0 {
+			bestNode = graph.entry(bestNode)
+		} else {
+			neighbours := searchLayer(graph, bestNode, query, ef, accept)
+			result := []Match{}
+			for _, neighbour := range neighbours {
+				result = append(result,
+					Match{
+						Offsets: []Position{{DocumentID: float64(graph.id(neighbour.Entry))}},
+						Score:   neighbour.Distance,
+					},
+				)
+			}
+			return result
+		}
+	}
+	return []Match{}
+}
+
 func distance(a, b []float64) float64 {
 	dotProduct := 0.0
 	magnitudeA := 0.0
@@ -153,37 +215,24 @@ func distance(a, b []float64) float64 {
 }
 
 func (hnsw *HNSW) Create(dataset []VectorNode) {
+	hnsw.mu.Lock()
+	defer hnsw.mu.Unlock()
+
 	for _, v := range dataset {
 		hnsw.insert(v)
 	}
 }
 
-func (hnsw *HNSW) Search(query VectorNode, ef int) []Match {
-	if len(hnsw.Index[0].Elements) == 0 {
-		return []Match{}
-	}
+func (hnsw *HNSW) Search(query VectorNode, ef int, accept func(docID int) bool) []Match {
+	hnsw.mu.RLock()
+	defer hnsw.mu.RUnlock()
 
-	bestNode := 0
-	for _, graph := range hnsw.Index {
-		nn := hnsw.searchLayer(graph, bestNode, query, 1)[0]
-		bestNode = nn.Entry
-		if graph.Elements[bestNode].Entry > 0 {
-			bestNode = graph.Elements[bestNode].Entry
-		} else {
-			neighbours := hnsw.searchLayer(graph, bestNode, query, ef)
-			result := []Match{}
-			for _, neighbour := range neighbours {
-				result = append(result,
-					Match{
-						Offsets: []Position{{DocumentID: float64(hnsw.Index[len(hnsw.Index)-1].Elements[neighbour.Entry].ID)}},
-						Score:   neighbour.Distance,
-					},
-				)
-			}
-			return result
-		}
+	layers := make([]layer, len(hnsw.Index))
+	for i, graph := range hnsw.Index {
+		layers[i] = graph
 	}
-	return []Match{}
+
+	return searchLayers(layers, query, ef, accept)
 }
 
 func (hnsw *HNSW) getInsertLayer() int {
@@ -206,7 +255,7 @@ func (hnsw *HNSW) insert(vec VectorNode) {
 	startingNode := 0
 	for i := range hnsw.Index {
 		if i < l {
-			startingNode = hnsw.searchLayer(hnsw.Index[i], startingNode, vec, 1)[0].Entry
+			startingNode = searchLayer(hnsw.Index[i], startingNode, vec, 1, nil)[0].Entry
 		} else {
 			entry := -1
 			if i < hnsw.L-1 {
@@ -214,7 +263,7 @@ func (hnsw *HNSW) insert(vec VectorNode) {
 			}
 			node := VectorNode{Vector: vec.Vector, Indices: []int{}, Entry: entry, ID: vec.ID}
 
-			nearestNeighbours := hnsw.searchLayer(hnsw.Index[i], startingNode, vec, hnsw.EFC)
+			nearestNeighbours := searchLayer(hnsw.Index[i], startingNode, vec, hnsw.EFC, nil)
 
 			m := int(math.Min(float64(hnsw.M), float64(len(nearestNeighbours))))
 			if len(nearestNeighbours) > hnsw.M {
@@ -234,25 +283,84 @@ func (hnsw *HNSW) insert(vec VectorNode) {
 	}
 }
 
+// Encode writes the graph in the segment layout read by OpenHNSW:
+//
+//	magic | version | L | M | EFC | mL | dimensions
+//	layer table: element count | elements offset, per layer
+//	elements:    ID | entry | neighbours offset | neighbour count | vector
+//	neighbours:  element indices
+//
+// Element records are fixed width so a mapped segment can address them directly.
 func (h *HNSW) Encode() []byte {
-	var b bytes.Buffer
-	enc := gob.NewEncoder(&b)
+	h.mu.RLock()
+	defer h.mu.RUnlock()
 
-	err := enc.Encode(h)
-	if err != nil {
-		panic(err)
+	dimensions := 0
+	if len(h.Index) > 0 && len(h.Index[0].Elements) > 0 {
+		dimensions = len(h.Index[0].Elements[0].Vector)
 	}
+
+	elementsStart := vectorIndexHeaderSize + len(h.Index)*layerEntrySize
+
+	layers := new(bytes.Buffer)
+	elements := new(bytes.Buffer)
+	neighbours := new(bytes.Buffer)
+
+	neighbourCount := 0
+	for _, graph := range h.Index {
+		binary.Write(layers, binary.LittleEndian, uint32(len(graph.Elements)))
+		binary.Write(layers, binary.LittleEndian, uint64(elementsStart+elements.Len()))
+
+		for _, e := range graph.Elements {
+			if len(e.Vector) != dimensions {
+				panic(fmt.Errorf("index: vector of %d dimensions in a %d dimension graph", len(e.Vector), dimensions))
+			}
+
+			binary.Write(elements, binary.LittleEndian, uint32(e.ID))
+			binary.Write(elements, binary.LittleEndian, int32(e.Entry))
+			binary.Write(elements, binary.LittleEndian, uint32(neighbourCount))
+			binary.Write(elements, binary.LittleEndian, uint32(len(e.Indices)))
+			binary.Write(elements, binary.LittleEndian, e.Vector)
+
+			for _, n := range e.Indices {
+				binary.Write(neighbours, binary.LittleEndian, uint32(n))
+			}
+			neighbourCount += len(e.Indices)
+		}
+	}
+
+	b := new(bytes.Buffer)
+	b.WriteString(vectorIndexMagic)
+	binary.Write(b, binary.LittleEndian, uint32(segmentVersion))
+	binary.Write(b, binary.LittleEndian, uint32(h.L))
+	binary.Write(b, binary.LittleEndian, uint32(h.M))
+	binary.Write(b, binary.LittleEndian, uint32(h.EFC))
+	binary.Write(b, binary.LittleEndian, h.mL)
+	binary.Write(b, binary.LittleEndian, uint32(dimensions))
+	b.Write(layers.Bytes())
+	b.Write(elements.Bytes())
+	b.Write(neighbours.Bytes())
+
 	return b.Bytes()
 }
 
-func (h *HNSW) Decode(b []byte) HNSW {
-	var q HNSW
-	buf := bytes.NewBuffer(b)
-	dec := gob.NewDecoder(buf)
-	err := dec.Decode(&q)
+func (h *HNSW) Decode(b []byte) *HNSW {
+	mapped, err := OpenHNSW(b)
 	if err != nil {
 		panic(err)
 	}
 
+	q := &HNSW{L: mapped.L, mL: mapped.mL, M: mapped.M, EFC: mapped.EFC, Index: make([]Graph, len(mapped.layers))}
+	for i, graph := range mapped.layers {
+		for n := 0; n < graph.size(); n++ {
+			q.Index[i].Elements = append(q.Index[i].Elements, VectorNode{
+				Vector:  graph.vector(n),
+				ID:      graph.id(n),
+				Indices: graph.neighbours(n),
+				Entry:   graph.entry(n),
+			})
+		}
+	}
+
 	return q
 }
@@ -32,12 +32,12 @@ func TestHNSW(t *testing.T) {
 
 	start := time.Now()
 	for i := 0; i < 1000; i++ {
-		hnsw.Search(randomPoint(), 10)
+		hnsw.Search(randomPoint(), 10, nil)
 	}
 	stop := time.Since(start)
 
 	fmt.Printf("%v queries / second (single thread)\n", 1000.0/stop.Seconds())
-	fmt.Printf("%+v", hnsw.Search(randomPoint(), 10))
+	fmt.Printf("%+v", hnsw.Search(randomPoint(), 10, nil))
 
 	// var b bytes.Buffer
 	// enc := gob.NewEncoder(&b)
@@ -54,5 +54,5 @@ func TestHNSW(t *testing.T) {
 	// if err != nil {
 	// 	log.Fatal("decode error 1:", err)
 	// }
-	// fmt.Println("decoded index:", q.Search(randomPoint(), 10))
+	// fmt.Println("decoded index:", q.Search(randomPoint(), 10, nil))
 }
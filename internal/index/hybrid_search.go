@@ -3,12 +3,14 @@ package index
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"io"
 	"log/slog"
 	"math"
 	"net/http"
 	"os"
 	"sort"
+	"strings"
 )
 
 type TextEmbeddingResponse struct {
@@ -25,14 +27,41 @@ type IndexResults struct {
 	Semantic []Match
 }
 
+// TextIndex is the full-text side of a hybrid search, either an in-memory
+// InvertedIndex or a MappedInvertedIndex. Its terms also hold the values
+// the filters of both sides are looked up in.
+type TextIndex interface {
+	TermReader
+	Rank(q Query, k int) []Match
+}
+
+// VectorIndex is the semantic side of a hybrid search, either an in-memory
+// HNSW or a MappedHNSW. A nil accept accepts every document.
+type VectorIndex interface {
+	Search(query VectorNode, ef int, accept func(docID int) bool) []Match
+}
+
+type textIndexer interface {
+	TextIndex
+	IndexDocument(docID int, d Document) error
+	Schema() *Schema
+}
+
+type vectorIndexer interface {
+	VectorIndex
+	Create(dataset []VectorNode)
+}
+
+var ErrReadOnlyIndex = errors.New("index: cannot index into a read-only index")
+
 type HybridSearch struct {
-	FTS          *InvertedIndex
-	Semantic     *HNSW
+	FTS          TextIndex
+	Semantic     VectorIndex
 	logger       *slog.Logger
 	getEmbedding getEmbeddingFn
 }
 
-func NewHybridSearch(fts *InvertedIndex, semantic *HNSW, logger *slog.Logger, getEmbedding getEmbeddingFn) *HybridSearch {
+func NewHybridSearch(fts TextIndex, semantic VectorIndex, logger *slog.Logger, getEmbedding getEmbeddingFn) *HybridSearch {
 	return &HybridSearch{
 		FTS:          fts,
 		Semantic:     semantic,
@@ -40,37 +69,66 @@ func NewHybridSearch(fts *InvertedIndex, semantic *HNSW, logger *slog.Logger, ge
 	}
 }
 
-func (hs *HybridSearch) Index(docId int, document string) error {
-	vector, err := hs.getEmbedding(document)
+func (hs *HybridSearch) indexers() (textIndexer, vectorIndexer, error) {
+	fts, ok := hs.FTS.(textIndexer)
+	if !ok {
+		return nil, nil, ErrReadOnlyIndex
+	}
+
+	semantic, ok := hs.Semantic.(vectorIndexer)
+	if !ok {
+		return nil, nil, ErrReadOnlyIndex
+	}
+
+	return fts, semantic, nil
+}
+
+// Index indexes a document in both indexes. Semantic search embeds only its
+// text and the fields the schema embeds, and a document with none of them
+// is left out of it.
+func (hs *HybridSearch) Index(docId int, d Document) error {
+	fts, semantic, err := hs.indexers()
 	if err != nil {
 		return err
 	}
 
-	hs.FTS.Index(docId, document)
-	hs.Semantic.Create([]VectorNode{{Vector: vector, ID: docId}})
+	text := fts.Schema().EmbeddingText(d)
+	var vector []float64
+	if text != "" {
+		if vector, err = hs.getEmbedding(text); err != nil {
+			return err
+		}
+	}
+
+	if err := fts.IndexDocument(docId, d); err != nil {
+		return err
+	}
+	if vector != nil {
+		semantic.Create([]VectorNode{{Vector: vector, ID: docId}})
+	}
 
 	return nil
 }
 
-func (hs *HybridSearch) BulkIndex(docIds []float64, documents []string) error {
-	jobsCh := make(chan map[int]string, len(docIds))
+func (hs *HybridSearch) BulkIndex(docIds []float64, documents []Document) error {
+	if _, _, err := hs.indexers(); err != nil {
+		return err
+	}
+
+	jobsCh := make(chan map[int]Document, len(docIds))
 	resultsCh := make(chan int, len(docIds))
 
 	//TODO: make number of workers configurable
 	for worker := 0; worker < 8; worker++ {
 		slog.Info("bulk indexing: worker", slog.Int("worker", worker))
-		go func(jobs chan map[int]string) {
-			for job := range jobs {
-				for docId, document := range job {
-					vector, err := hs.getEmbedding(document)
-					if err != nil {
-						slog.Error("bulk indexing error:", err)
+		go func(jobs chan map[int]Document) {
+			for j := range jobs {
+				for docId, document := range j {
+					if err := hs.Index(docId, document); err != nil {
+						slog.Error("bulk indexing error", slog.String("error", err.Error()))
 						panic(err)
 					}
 
-					hs.FTS.Index(docId, document)
-					hs.Semantic.Create([]VectorNode{{Vector: vector, ID: docId}})
-
 					resultsCh <- 1
 				}
 			}
@@ -79,7 +137,7 @@ func (hs *HybridSearch) BulkIndex(docIds []float64, documents []string) error {
 
 	//send tasks to goroutines
 	for i := 0; i < len(docIds); i++ {
-		jobsCh <- map[int]string{int(docIds[i]): documents[i]}
+		jobsCh <- map[int]Document{int(docIds[i]): documents[i]}
 	}
 
 	//process results
@@ -89,16 +147,53 @@ func (hs *HybridSearch) BulkIndex(docIds []float64, documents []string) error {
 	return nil
 }
 
-func (hs *HybridSearch) Search(query string, k int) []Match {
-	ftsResult := hs.FTS.RankProximity(query, k)
+// Search runs q against both indexes, keeping only the documents that pass
+// its filter on either side before the k best are taken, or the first k
+// after its cursor in its sort order if it is sorted. A sorted query pages
+// through the documents it matches, so its nearest vectors are only kept if
+// they match its text too, and a query without text has no vector to search.
+func (hs *HybridSearch) Search(q Query, k int) []Match {
+	matches, _ := hs.SearchAggregate(q, k)
+	return matches
+}
 
-	vector, err := hs.getEmbedding(query)
-	if err != nil {
-		panic(err)
+// SearchAggregate is Search along with the aggregations of q, computed from
+// the documents its text and filter matched in full text rather than by
+// matching them again. They are nil if q has none.
+func (hs *HybridSearch) SearchAggregate(q Query, k int) ([]Match, Aggregations) {
+	q = q.withFilter(hs.FTS)
+	slog.Info("index: proximity ranking")
+	matched := matchQuery(hs.FTS, q)
+	ftsResult := rankMatches(q, matched, k)
+
+	var aggs Aggregations
+	if len(q.Aggregations) > 0 {
+		aggs = aggregate(hs.FTS, q, matched)
+	}
+
+	accept := q.acceptFunc()
+	if q.Sorted() {
+		docs := documentSet{}
+		for _, m := range matched {
+			docs[m.Offsets[0].DocumentID] = true
+		}
+		accept = func(docID int) bool { return docs[float64(docID)] }
 	}
-	semanticResult := hs.Semantic.Search(VectorNode{Vector: vector}, 64)
 
-	return mergeResult(IndexResults{FTS: ftsResult, Semantic: semanticResult}, 0.8, k)
+	semanticResult := []Match{}
+	if strings.TrimSpace(q.Text) != "" && (!q.Sorted() || len(matched) > 0) {
+		vector, err := hs.getEmbedding(q.Text)
+		if err != nil {
+			panic(err)
+		}
+		semanticResult = hs.Semantic.Search(VectorNode{Vector: vector}, 64, accept)
+	}
+
+	results := IndexResults{FTS: ftsResult, Semantic: semanticResult}
+	if q.Sorted() {
+		return pageMatches(hs.FTS, q, mergeResult(results, 0.8, len(ftsResult)+len(semanticResult)), k), aggs
+	}
+	return mergeResult(results, 0.8, k), aggs
 }
 
 func mergeResult(results IndexResults, mergeWeight float32, k int) []Match {
@@ -106,7 +201,7 @@ func mergeResult(results IndexResults, mergeWeight float32, k int) []Match {
 
 	seen := map[int]bool{}
 	for _, r := range results.FTS {
-		mergedResults = append(mergedResults, Match{Offsets: r.Offsets, Score: r.Score * float64(1-mergeWeight)})
+		mergedResults = append(mergedResults, Match{Offsets: r.Offsets, Highlights: r.Highlights, Field: r.Field, Score: r.Score * float64(1-mergeWeight)})
 		seen[int(r.Offsets[0].DocumentID)] = true
 	}
 
@@ -0,0 +1,148 @@
+package index
+
+import (
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/farouqzaib/fast-search/internal/analyzer"
+)
+
+// TestHybridSearchConcurrentBulkIndex bulk indexes with the workers of
+// BulkIndex, which write to both indexes at once, while searches run. Run
+// with -race.
+func TestHybridSearchConcurrentBulkIndex(t *testing.T) {
+	embed := func(text string) ([]float64, error) {
+		return []float64{float64(len(text)), 1, 2}, nil
+	}
+	fts := NewInvertedIndex()
+	semantic := NewHNSW(3, 0.62, 4, 8)
+	hs := NewHybridSearch(fts, semantic, slog.Default(), embed)
+
+	const docs = 200
+	ids := make([]float64, docs)
+	documents := make([]Document, docs)
+	for n := range ids {
+		ids[n] = float64(n + 1)
+		documents[n] = Document{Text: fmt.Sprintf("raft replication document%d", n+1)}
+	}
+
+	done := make(chan struct{})
+	var readers sync.WaitGroup
+	for r := 0; r < 4; r++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				hs.Search(Query{Text: "raft"}, 10)
+			}
+		}()
+	}
+
+	if err := hs.BulkIndex(ids, documents); err != nil {
+		t.Fatal(err)
+	}
+	close(done)
+	readers.Wait()
+
+	if df := fts.DocumentFrequency("raft"); df != docs {
+		t.Fatalf("expected raft in %d documents, got %d", docs, df)
+	}
+	// every vector is in the bottom layer
+	if n := len(semantic.Index[len(semantic.Index)-1].Elements); n != docs {
+		t.Fatalf("expected %d vectors, got %d", docs, n)
+	}
+}
+
+func TestHybridSearchAggregate(t *testing.T) {
+	s, err := ParseSchema(strings.NewReader(`{"fields": {"tags": {"type": "keyword"}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	embed := func(text string) ([]float64, error) {
+		return []float64{float64(len(text)), 1, 2}, nil
+	}
+	fts := NewInvertedIndexWithSchema(analyzer.Default(), s)
+	hs := NewHybridSearch(fts, NewHNSW(3, 0.62, 4, 8), slog.Default(), embed)
+
+	err = hs.BulkIndex([]float64{1, 2, 3}, []Document{
+		{Text: "raft leader", Fields: map[string]interface{}{"tags": "consensus"}},
+		{Text: "raft log", Fields: map[string]interface{}{"tags": "logs"}},
+		{Text: "gossip", Fields: map[string]interface{}{"tags": "logs"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, q := range []Query{
+		{Text: "raft", Aggregations: map[string]Aggregation{"tags": {Type: TermsAggregation, Field: "tags"}}},
+		{Filter: "tags = logs", Aggregations: map[string]Aggregation{"tags": {Type: TermsAggregation, Field: "tags"}}},
+	} {
+		_, aggs := hs.SearchAggregate(q, 10)
+		if len(aggs["tags"].Buckets) == 0 {
+			t.Fatalf("%+v: expected buckets, got %v", q, aggs)
+		}
+		if expected := Aggregate(fts, q); !reflect.DeepEqual(aggs, expected) {
+			t.Fatalf("%+v: expected %v, got %v", q, expected, aggs)
+		}
+	}
+
+	if _, aggs := hs.SearchAggregate(Query{Text: "raft"}, 10); aggs != nil {
+		t.Fatalf("expected no aggregations, got %v", aggs)
+	}
+}
+
+func TestHybridSearchSorted(t *testing.T) {
+	s, err := ParseSchema(strings.NewReader(`{"fields": {"views": {"type": "number"}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	embedded := 0
+	embed := func(text string) ([]float64, error) {
+		embedded++
+		return []float64{float64(len(text)), 1, 2}, nil
+	}
+	fts := NewInvertedIndexWithSchema(analyzer.Default(), s)
+	hs := NewHybridSearch(fts, NewHNSW(3, 0.62, 4, 8), slog.Default(), embed)
+
+	// indexed one at a time, since embed counts without a lock
+	for i, d := range []Document{
+		{Text: "raft leader", Fields: map[string]interface{}{"views": 1.0}},
+		{Text: "raft log", Fields: map[string]interface{}{"views": 2.0}},
+		{Text: "gossip", Fields: map[string]interface{}{"views": 3.0}},
+	} {
+		if err := hs.Index(i+1, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the vector of gossip is near, but it does not match the text
+	embedded = 0
+	matches := hs.Search(Query{Text: "raft", Sort: []SortField{{Field: "views", Order: Descending}}}, 10)
+	if len(matches) != 2 || matches[0].Offsets[0].DocumentID != 2 || matches[1].Offsets[0].DocumentID != 1 {
+		t.Fatalf("expected documents 2 and 1, got %v", matches)
+	}
+	if embedded != 1 {
+		t.Fatalf("expected the text to be embedded once, got %d", embedded)
+	}
+
+	if matches := hs.Search(Query{Filter: "views >= 2", Sort: []SortField{{Field: "views"}}}, 10); len(matches) != 0 {
+		t.Fatalf("expected no matches without text, got %v", matches)
+	}
+	if matches := hs.Search(Query{Text: "paxos", Sort: []SortField{{Field: "views"}}}, 10); len(matches) != 0 {
+		t.Fatalf("expected no matches, got %v", matches)
+	}
+	if embedded != 1 {
+		t.Fatalf("expected nothing more to be embedded, got %d", embedded)
+	}
+}
@@ -4,125 +4,214 @@ import (
 	"bytes"
 	"encoding/binary"
 	"errors"
-	"fmt"
 	"log/slog"
-	"math"
-	"runtime"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/farouqzaib/fast-search/internal/analyzer"
 )
 
+// InvertedIndex is the in-memory index of a memtable. It takes one writer
+// at a time and any number of readers alongside it: indexing a document holds
+// the index exclusively until every posting of the document is in, and each
+// read sees the index before or after a document, never halfway through.
+// Queries of several reads may see documents indexed between them.
 type InvertedIndex struct {
-	PostingsList map[string]SkipList
+	mu           sync.RWMutex
+	PostingsList map[string]*SkipList
+	// Completions counts the documents each unstemmed word occurs in, for
+	// search-as-you-type.
+	Completions map[string]int
+	// docValues are the values of each field by document, the document
+	// text under DocumentText, for filters to read.
+	docValues map[string]map[float64][]string
+	analyzer  *analyzer.Analyzer
+	schema    *Schema
 }
 
 func NewInvertedIndex() *InvertedIndex {
-	postingsList := map[string]SkipList{}
+	return NewInvertedIndexWithAnalyzer(analyzer.Default())
+}
+
+// NewInvertedIndexWithAnalyzer returns an index that analyzes documents, and
+// the queries run against them, with a.
+func NewInvertedIndexWithAnalyzer(a *analyzer.Analyzer) *InvertedIndex {
+	return NewInvertedIndexWithSchema(a, nil)
+}
+
+// NewInvertedIndexWithSchema returns an index of documents with the fields s
+// declares, analyzed with a unless a field names an analyzer of its own.
+func NewInvertedIndexWithSchema(a *analyzer.Analyzer, s *Schema) *InvertedIndex {
+	postingsList := map[string]*SkipList{}
 	return &InvertedIndex{
 		PostingsList: postingsList,
+		Completions:  map[string]int{},
+		docValues:    map[string]map[float64][]string{},
+		analyzer:     a,
+		schema:       s,
 	}
 }
 
+func (i *InvertedIndex) Analyzer() *analyzer.Analyzer {
+	return i.analyzer
+}
+
+// Schema returns the schema of the index's documents.
+func (i *InvertedIndex) Schema() *Schema {
+	return i.schema
+}
+
 func (i *InvertedIndex) ConcurrentIndex(docID int, tokens []string) {
-	tokenOffsets := map[string][]int{}
+	i.mu.Lock()
+	defer i.mu.Unlock()
 
+	analyzed := make([]analyzer.Token, len(tokens))
 	for j, token := range tokens {
-		_, ok := tokenOffsets[token]
-		if ok {
-			tokenOffsets[token] = append(tokenOffsets[token], j)
-		} else {
-			tokenOffsets[token] = []int{j}
+		analyzed[j] = analyzer.Token{Term: token, PositionIncrement: 1}
+	}
+
+	i.indexTokens(docID, analyzed)
+	i.addDocValues(DocumentText, docID, nil)
+}
+
+// indexTokens adds the positions of a document's tokens to the postings of
+// their terms. The caller holds the write lock, so the tokens are inserted as
+// they come.
+func (i *InvertedIndex) indexTokens(docID int, tokens []analyzer.Token) {
+	offsets := analyzer.Positions(tokens)
+
+	for j, token := range tokens {
+		sk, ok := i.PostingsList[token.Term]
+		if !ok {
+			sk = NewSkipList()
+			i.PostingsList[token.Term] = sk
 		}
+		sk.Insert(Position{DocumentID: float64(docID), Offset: float64(offsets[j]), Start: token.Start, End: token.End})
 	}
+}
 
-	tokensCh := make(chan map[string][]int, len(tokenOffsets))
-	resultCh := make(chan map[string]SkipList, len(tokenOffsets))
+func (i *InvertedIndex) Index(docID int, document string) {
+	slog.Info("index: indexing documents", slog.Int("docID", docID))
+	tokens := i.analyzer.Analyze(document)
 
-	//TODO: make number of workers configurable
-	for w := 0; w < runtime.GOMAXPROCS(0); w++ {
-		go func(tokenCh chan map[string][]int) {
-			for tokenOffset := range tokenCh {
-				sk := *NewSkipList()
-				token := ""
+	i.mu.Lock()
+	defer i.mu.Unlock()
 
-				for tok, offsets := range tokenOffset {
-					token = tok
-					for _, t := range offsets {
-						sk.Insert(Position{DocumentID: float64(docID), Offset: float64(t)})
-					}
-				}
+	i.indexTokens(docID, tokens)
+	i.indexCompletions(document, tokens)
+	i.addDocValues(DocumentText, docID, nil)
+}
 
-				resultCh <- map[string]SkipList{token: sk}
-			}
-		}(tokensCh)
-	}
+// IndexLanguage indexes a document written in language with that language's
+// analyzer, or with the index's own when language is empty. Queries must name
+// the same language to match it.
+func (i *InvertedIndex) IndexLanguage(docID int, document string, language string) error {
+	return i.IndexDocument(docID, Document{Text: document, Language: language})
+}
 
-	for token, offsets := range tokenOffsets {
-		tokensCh <- map[string][]int{token: offsets}
+// IndexDocument indexes the text of d and each of its indexed fields under
+// postings of their own. Text fields are analyzed like the text, unless they
+// have an analyzer of their own; the other fields are indexed a term per
+// value.
+func (i *InvertedIndex) IndexDocument(docID int, d Document) error {
+	if err := i.schema.Validate(d.Fields); err != nil {
+		return err
 	}
 
-	for k := 0; k < len(tokenOffsets); k++ {
-		result := <-resultCh
-		for k, v := range result {
-			i.PostingsList[k] = v
+	a := i.analyzer
+	if d.Language != "" {
+		var err error
+		if a, err = i.analyzer.ForLanguage(d.Language); err != nil {
+			return err
 		}
 	}
-}
 
-func (i *InvertedIndex) Index(docID int, document string) {
-	slog.Info("index: indexing documents", slog.Int("docID", docID))
-	tokens := analyzer.Analyze(document)
+	slog.Info("index: indexing documents", slog.Int("docID", docID), slog.String("language", a.Name))
 
-	i.ConcurrentIndex(docID, tokens)
+	i.mu.Lock()
+	defer i.mu.Unlock()
 
-	// for j, word := range tokens {
-	// 	_, ok := i.PostingsList[word]
+	if d.Text != "" {
+		tokens := a.Analyze(d.Text)
+		i.indexTokens(docID, tokens)
+		i.indexCompletions(d.Text, tokens)
+		i.addDocValues(DocumentText, docID, nil)
+	}
 
-	// 	if !ok {
-	// 		i.PostingsList[word] = *NewSkipList()
-	// 	}
+	for _, name := range i.schema.names() {
+		value, ok := d.Fields[name]
+		field := i.schema.Fields[name]
+		if !ok || !field.Indexed() {
+			continue
+		}
 
-	// 	sk := i.PostingsList[word]
-	// 	sk.Insert(Position{DocumentID: float64(docID), Offset: float64(j)})
-	// 	i.PostingsList[word] = sk
-	// }
+		var tokens []analyzer.Token
+		if field.Type == TextField {
+			text := value.(string)
+			tokens = newFieldReader(i, name).fieldAnalyzer(a).Analyze(text)
+			i.indexCompletions(text, tokens)
+			i.addDocValues(name, docID, nil)
+		} else {
+			terms, _ := field.terms(value)
+			for _, term := range terms {
+				tokens = append(tokens, analyzer.Token{Term: term, PositionIncrement: 1})
+			}
+			i.addDocValues(name, docID, terms)
+		}
+
+		for j := range tokens {
+			tokens[j].Term = fieldTerm(name, tokens[j].Term)
+		}
+		i.indexTokens(docID, tokens)
+	}
+	return nil
 }
 
 func (i *InvertedIndex) First(token string) (Position, error) {
-	_, ok := i.PostingsList[token]
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	return i.first(token)
+}
 
+func (i *InvertedIndex) first(token string) (Position, error) {
+	sk, ok := i.PostingsList[token]
 	if ok {
-		sk := i.PostingsList[token]
 		return sk.Head.Tower[0].Key, nil
 	}
 	return Position{DocumentID: EOF, Offset: EOF}, errors.New("no list exists for token")
 }
 
 func (i *InvertedIndex) Last(token string) (Position, error) {
-	_, ok := i.PostingsList[token]
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	return i.last(token)
+}
 
+func (i *InvertedIndex) last(token string) (Position, error) {
+	sk, ok := i.PostingsList[token]
 	if ok {
-		sk := i.PostingsList[token]
 		return sk.Last(), nil
 	}
 	return Position{DocumentID: EOF, Offset: EOF}, errors.New("no list exists for token")
 }
 
 func (i *InvertedIndex) Next(token string, offset Position) (Position, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
 	if offset.Offset == BOF {
-		return i.First(token)
+		return i.first(token)
 	}
 
 	if offset.Offset == EOF {
 		return Position{DocumentID: EOF, Offset: EOF}, nil
 	}
 
-	_, ok := i.PostingsList[token]
-
+	sk, ok := i.PostingsList[token]
 	if ok {
-		sk := i.PostingsList[token]
-
 		key, _ := sk.FindGreaterThan(offset)
 		return key, nil
 	}
@@ -131,18 +220,19 @@ func (i *InvertedIndex) Next(token string, offset Position) (Position, error) {
 }
 
 func (i *InvertedIndex) Previous(token string, offset Position) (Position, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
 	if offset.Offset == EOF {
-		return i.Last(token)
+		return i.last(token)
 	}
 
 	if offset.Offset == BOF {
 		return Position{DocumentID: BOF, Offset: BOF}, nil
 	}
 
-	_, ok := i.PostingsList[token]
-
+	sk, ok := i.PostingsList[token]
 	if ok {
-		sk := i.PostingsList[token]
 		key, _ := sk.FindLessThan(offset)
 		return key, nil
 	}
@@ -151,315 +241,216 @@ func (i *InvertedIndex) Previous(token string, offset Position) (Position, error
 }
 
 func (i *InvertedIndex) NextPhrase(query string, offset Position) []Position {
-	v := offset
-
-	terms := strings.Fields(query)
-	for _, char := range terms {
-		word := string(char)
-		v, _ = i.Next(word, v)
-	}
-
-	if v.Offset == EOF {
-		return []Position{{DocumentID: EOF, Offset: EOF}, {DocumentID: EOF, Offset: EOF}}
-	}
-
-	u := v
-
-	for j := len(terms) - 2; j >= 0; j-- {
-		word := terms[j]
-		u, _ = i.Previous(word, u)
-	}
-
-	if (v.DocumentID == u.DocumentID) && (v.GetOffset()-u.GetOffset() == len(strings.Fields(query))-1) {
-		return []Position{u, v}
-	}
-
-	return i.NextPhrase(query, u)
+	return nextPhrase(i, query, offset)
 }
 
 func (i *InvertedIndex) FindAllPhrases(query string, offset Position) [][]Position {
-	u := Position{DocumentID: BOF, Offset: BOF}
-
-	positions := [][]Position{}
-
-	for u.DocumentID != EOF {
-		offsets := i.NextPhrase(query, u)
-		u = offsets[0]
-
-		if u.DocumentID != EOF && u.Offset != EOF {
-			positions = append(positions, offsets)
-		}
-	}
-
-	return positions
+	return findAllPhrases(i, query, offset)
 }
 
 func (i *InvertedIndex) NextCover(tokens []string, offset Position) []Position {
-	v := offset
+	return nextCover(i, tokens, offset)
+}
 
-	for j, word := range tokens {
-		localMax, _ := i.Next(word, offset)
+func (i *InvertedIndex) RankProximity(query string, k int) []Match {
+	return rankProximity(i, query, k)
+}
 
-		//break if localMax is ever EOF
-		if localMax.DocumentID == EOF {
-			v = localMax
-			break
-		}
+func (i *InvertedIndex) Rank(q Query, k int) []Match {
+	return rankQuery(i, q, k)
+}
 
-		if j == 0 {
-			v = localMax
+// indexCompletions counts the words of document the analyzer kept as tokens,
+// lowercased but otherwise as they were written, since completions are shown
+// to users as they type rather than matched against stems.
+func (i *InvertedIndex) indexCompletions(document string, tokens []analyzer.Token) {
+	seen := map[string]bool{}
+	for _, token := range tokens {
+		if token.End <= token.Start || token.End > len(document) {
 			continue
 		}
 
-		if localMax.DocumentID > v.DocumentID || (localMax.DocumentID == v.DocumentID && localMax.Offset > v.Offset) {
-			v = localMax
+		word := strings.ToLower(document[token.Start:token.End])
+		if !seen[word] {
+			seen[word] = true
+			i.Completions[word]++
 		}
 	}
+}
 
-	if v.DocumentID == EOF {
-		return []Position{{DocumentID: EOF, Offset: EOF}, {DocumentID: EOF, Offset: EOF}}
-	}
-
-	u := Position{DocumentID: BOF, Offset: BOF}
-
-	for j, word := range tokens {
-		localMin, _ := i.Previous(word, Position{DocumentID: v.DocumentID, Offset: v.Offset + 1})
+// Complete returns the words starting with prefix and their document counts.
+func (i *InvertedIndex) Complete(prefix string) []Completion {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
 
-		if j == 0 {
-			u = localMin
-			continue
+	completions := []Completion{}
+	for word, frequency := range i.Completions {
+		if strings.HasPrefix(word, prefix) {
+			completions = append(completions, Completion{Text: word, Frequency: frequency})
 		}
+	}
+	return completions
+}
 
-		if localMin.DocumentID < u.DocumentID || (localMin.Offset == u.Offset && localMin.Offset < u.Offset) {
-			u = localMin
+// CompletionTerms returns the words starting with prefix in sorted order.
+func (i *InvertedIndex) CompletionTerms(prefix string) TermIterator {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	words := []string{}
+	for word := range i.Completions {
+		if strings.HasPrefix(word, prefix) {
+			words = append(words, word)
 		}
 	}
+	sort.Strings(words)
+	return &sliceIterator{terms: words}
+}
 
-	if u.DocumentID == v.DocumentID {
-		return []Position{u, v}
-	}
+// Terms returns the terms in [lower, upper) in sorted order. An empty upper
+// bound is unbounded.
+func (i *InvertedIndex) Terms(lower, upper string) TermIterator {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
 
-	return i.NextCover(tokens, u)
+	return &sliceIterator{terms: termRange(i.sortedTerms(), lower, upper)}
 }
 
-type Match struct {
-	Offsets []Position
-	Score   float64
+// PrefixTerms returns the terms starting with prefix in sorted order.
+func (i *InvertedIndex) PrefixTerms(prefix string) TermIterator {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	return &sliceIterator{terms: termPrefix(i.sortedTerms(), prefix)}
 }
 
-func (i *InvertedIndex) RankProximity(query string, k int) []Match {
-	slog.Info("index: proximity ranking")
-	tokens := analyzer.Analyze(query)
-	slog.Info("index: search tokens", slog.String("tokens", fmt.Sprintf("%v", tokens)))
-	if len(tokens) == 0 {
-		return []Match{}
+// DocumentFrequency returns the number of documents term occurs in.
+func (i *InvertedIndex) DocumentFrequency(term string) int {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	sk, ok := i.PostingsList[term]
+	if !ok {
+		return 0
 	}
 
-	offsets := i.NextCover(tokens, Position{DocumentID: BOF, Offset: BOF})
-	u, v := offsets[0], offsets[1]
-	candidate := []Position{u, v}
-	score := 0.0
-	results := []Match{}
-
-	for u.DocumentID < EOF {
-		if candidate[0].DocumentID < u.DocumentID {
-			results = append(results, Match{Offsets: candidate, Score: score})
-			candidate = []Position{u, v}
-			score = 0
+	df := 0
+	last := BOF
+	for node := sk.Head.Tower[0]; node != nil; node = node.Tower[0] {
+		if node.Key.DocumentID != last {
+			df++
+			last = node.Key.DocumentID
 		}
-
-		score = score + 1/(v.Offset-u.Offset+1)
-
-		offsets = i.NextCover(tokens, u)
-		u, v = offsets[0], offsets[1]
 	}
+	return df
+}
 
-	if candidate[0].DocumentID < EOF {
-		results = append(results, Match{Offsets: candidate, Score: score})
+func (i *InvertedIndex) sortedTerms() []string {
+	terms := make([]string, 0, len(i.PostingsList))
+	for term := range i.PostingsList {
+		terms = append(terms, term)
 	}
-
-	return results[:int(math.Min(float64(k), float64(len(results))))]
+	sort.Strings(terms)
+	return terms
 }
 
+// Encode writes the index in the segment layout read by OpenInvertedIndex:
+//
+//	magic | version | term dictionary length | completion dictionary length |
+//	doc values length | analyzer name length | schema length | analyzer name |
+//	schema as JSON
+//	term dictionary:       sorted terms and their postings (see encodeTermDictionary),
+//	                       the terms of a field prefixed with its name (see fieldTerm)
+//	completion dictionary: sorted words and their document counts
+//	doc values:            the values of each field by document (see encodeDocValues)
+//	postings:              block-compressed postings, per term (see encodePostings)
+//
+// The completion dictionary reuses the term dictionary layout, with a word's
+// document count in place of a postings offset.
 func (i *InvertedIndex) Encode() []byte {
-	b := new(bytes.Buffer)
-	// termList := []string{}
-	for k, v := range i.PostingsList {
-
-		// termList = append(termList, k)
-		//add len of title to buffer
-		binary.Write(b, binary.LittleEndian, uint32(len([]byte(k))))
-
-		//add title to buffer
-		b.Write([]byte(k))
-
-		curr := v.Head
-
-		type truncatedOffset struct {
-			DocId    uint32
-			Position uint32
-		}
-		//get all the keys first
-		nodes := map[truncatedOffset]int{}
-
-		head := curr
-		counter := 1
-
-		nodeBytes := new(bytes.Buffer)
-		// nodeEncoder := gob.NewEncoder(nodeBytes)
-		for head != nil {
-			offset := truncatedOffset{DocId: uint32(head.Key.DocumentID), Position: uint32(head.Key.Offset)}
-			nodes[offset] = counter
-			counter++
-
-			err := binary.Write(nodeBytes, binary.LittleEndian, uint32(head.Key.DocumentID))
-
-			if err != nil {
-				panic(err)
-			}
-
-			_ = binary.Write(nodeBytes, binary.LittleEndian, uint32(head.Key.Offset))
+	i.mu.RLock()
+	defer i.mu.RUnlock()
 
-			if err != nil {
-				panic(err)
-			}
+	entries := []termEntry{}
+	postings := []byte{}
 
-			head = head.Tower[0]
+	for _, term := range i.sortedTerms() {
+		sk := i.PostingsList[term]
 
+		positions := []Position{}
+		for node := sk.Head.Tower[0]; node != nil; node = node.Tower[0] {
+			positions = append(positions, node.Key)
 		}
 
-		//add len of nodes to buffer
-		// b.Write([]byte(strconv.Itoa(len(nodeBytes.Bytes()))))
-		binary.Write(b, binary.LittleEndian, uint32(len(nodeBytes.Bytes())))
-
-		//add nodes to buffer
-		b.Write(nodeBytes.Bytes())
-
-		for curr != nil {
-			tower := []Node{}
-			for level := 0; level < MaxHeight; level++ {
-				if curr.Tower[level] == nil {
-					break
-				}
-
-				tower = append(tower, *curr.Tower[level])
-			}
-
-			towerKeys := []uint16{}
-
-			towerNodeBytes := new(bytes.Buffer)
-			// towerNodeEncoder := gob.NewEncoder(towerNodeBytes)
-			for _, node := range tower {
-				offset := truncatedOffset{DocId: uint32(node.Key.DocumentID), Position: uint32(node.Key.Offset)}
-				towerKeys = append(towerKeys, uint16(nodes[offset]))
-
-				err := binary.Write(towerNodeBytes, binary.LittleEndian, uint16(nodes[offset]))
-
-				if err != nil {
-					panic(err)
-				}
-			}
-
-			if len(towerKeys) == 0 {
-				nilTowerNodeBytes := new(bytes.Buffer)
-
-				err := binary.Write(nilTowerNodeBytes, binary.LittleEndian, uint16(0))
-
-				if err != nil {
-					panic(err)
-				}
-				//add len of tower nodes to buffer
-				binary.Write(b, binary.LittleEndian, uint32(len(nilTowerNodeBytes.Bytes())))
+		encoded := encodePostings(positions)
+		entries = append(entries, termEntry{term: term, ref: postingsRef{offset: len(postings), length: len(encoded)}})
+		postings = append(postings, encoded...)
+	}
 
-				//add tower nodes to buffer
-				b.Write(nilTowerNodeBytes.Bytes())
-			} else {
-				// add len of tower nodes to buffer
-				binary.Write(b, binary.LittleEndian, uint32(len(towerNodeBytes.Bytes())))
+	dictionary := encodeTermDictionary(entries)
 
-				// add tower nodes to buffer
-				b.Write(towerNodeBytes.Bytes())
-			}
+	words := make([]string, 0, len(i.Completions))
+	for word := range i.Completions {
+		words = append(words, word)
+	}
+	sort.Strings(words)
 
-			curr = curr.Tower[0]
-		}
+	completionEntries := make([]termEntry, len(words))
+	for n, word := range words {
+		completionEntries[n] = termEntry{term: word, ref: postingsRef{offset: i.Completions[word]}}
 	}
+	completions := encodeTermDictionary(completionEntries)
+	docValues := encodeDocValues(i.docValues)
+	schema := i.schema.encode()
+
+	b := new(bytes.Buffer)
+	b.WriteString(invertedIndexMagic)
+	binary.Write(b, binary.LittleEndian, uint32(segmentVersion))
+	binary.Write(b, binary.LittleEndian, uint64(len(dictionary)))
+	binary.Write(b, binary.LittleEndian, uint64(len(completions)))
+	binary.Write(b, binary.LittleEndian, uint64(len(docValues)))
+	binary.Write(b, binary.LittleEndian, uint32(len(i.analyzer.Name)))
+	binary.Write(b, binary.LittleEndian, uint32(len(schema)))
+	b.WriteString(i.analyzer.Name)
+	b.Write(schema)
+	b.Write(dictionary)
+	b.Write(completions)
+	b.Write(docValues)
+	b.Write(postings)
 
 	return b.Bytes()
 }
 
-func (i *InvertedIndex) Decode(b []byte) InvertedIndex {
-	recoveredIndex := map[string]SkipList{}
-
-	offset := 0
-	round := 0
-	for offset < len(b) {
-		// fmt.Println("len", len(b.Bytes()))
-		n := int(binary.LittleEndian.Uint32(b[offset : offset+4]))
-		// fmt.Println("term index", n)
-
-		// n, _ := strconv.Atoi(string(b.Bytes()[offset]))
-		// fmt.Println(n)
-		offset = offset + 4
-		// fmt.Printf("actual: %s, recovered: %s\n\n", termList[round], string(b.Bytes()[offset:offset+n]))
-
-		term := string(b[offset : offset+n])
-
-		//get number of bytes for nodes
-		offset = offset + n
-		un := binary.LittleEndian.Uint32(b[offset : offset+4])
-		// n, _ = strconv.Atoi(string(b.Bytes()[offset : offset+1]))
-		// fmt.Println(un)
-
-		// os.Exit(-1)
-		positions := []*Node{}
-		positionMap := map[int]*Node{}
-		counter := 1
-		offset = offset + 4
-		for i := 0; i < int(un)/4; i++ {
-			node := binary.LittleEndian.Uint32(b[offset : offset+4])
-
-			if i%2 == 0 {
-				positions = append(positions, &Node{Key: Position{DocumentID: float64(node)}})
-			} else {
-				positions[len(positions)-1].Key.Offset = float64(node)
-				positionMap[counter] = positions[len(positions)-1]
-				counter++
-			}
-			offset = offset + 4
-		}
-
-		height := 1.0
-		//loop for each of the nodes found
-		for i := 1; i <= int(un)/8; i++ {
-			//get length of node tower keys
-			kn := binary.LittleEndian.Uint32(b[offset : offset+4])
-			// fmt.Println("len node keys", kn)
-
-			towerKeys := []*Node{}
-			offset = offset + 4
-			for j := 0; j < int(kn)/2; j++ {
-				node := binary.LittleEndian.Uint16(b[offset : offset+2])
-				if node != 0 {
-					towerKeys = append(towerKeys, positionMap[int(node)])
-					height = math.Max(float64(len(towerKeys)), float64(height))
-					positionMap[i].Tower[j] = positionMap[int(node)]
-				}
-				offset = offset + 2
-			}
-		}
+func (i *InvertedIndex) Decode(b []byte) *InvertedIndex {
+	mapped, err := OpenInvertedIndex(b)
+	if err != nil {
+		panic(err)
+	}
 
-		// fmt.Println("Height:", height)
+	recoveredIndex := map[string]*SkipList{}
+	for it := mapped.Terms("", ""); it.Next(); {
+		p, _ := mapped.postings(it.Term())
 
-		sk := SkipList{
-			Head:   positionMap[1],
-			Height: int(height),
+		sk := NewSkipList()
+		for _, position := range p.all() {
+			sk.Insert(position)
 		}
+		recoveredIndex[it.Term()] = sk
+	}
 
-		recoveredIndex[term] = sk
+	recoveredCompletions := map[string]int{}
+	for _, c := range mapped.Complete("") {
+		recoveredCompletions[c.Text] = c.Frequency
+	}
 
-		round++
+	recoveredDocValues := map[string]map[float64][]string{}
+	for field, values := range mapped.docValues {
+		docs := map[float64][]string{}
+		for _, doc := range values.Documents() {
+			docs[doc], _ = values.Values(doc)
+		}
+		recoveredDocValues[field] = docs
 	}
 
-	return InvertedIndex{PostingsList: recoveredIndex}
+	return &InvertedIndex{PostingsList: recoveredIndex, Completions: recoveredCompletions, docValues: recoveredDocValues, analyzer: mapped.analyzer, schema: mapped.schema}
 }
@@ -2,6 +2,8 @@ package index
 
 import (
 	"fmt"
+	"math"
+	"sync"
 	"testing"
 
 	"github.com/farouqzaib/fast-search/internal/analyzer"
@@ -158,3 +160,75 @@ func TestInvertedIndexConcurrentIndex(t *testing.T) {
 	// 	t.Fatalf("expected %v, document offset, got %v", expected, found)
 	// }
 }
+
+func TestInvertedIndexSharedTerms(t *testing.T) {
+	index := NewInvertedIndex()
+
+	index.Index(1, "raft leader election")
+	index.Index(2, "raft log replication")
+	index.Index(3, "gossip protocol")
+
+	// later documents add to the postings of a term rather than replace them
+	if df := index.DocumentFrequency("raft"); df != 2 {
+		t.Fatalf("expected raft in 2 documents, got %d", df)
+	}
+
+	first, _ := index.First("raft")
+	next, _ := index.Next("raft", Position{DocumentID: 1, Offset: math.MaxFloat64})
+	if first.DocumentID != 1 || next.DocumentID != 2 {
+		t.Fatalf("expected raft in documents 1 and 2, got %v and %v", first, next)
+	}
+}
+
+// TestInvertedIndexConcurrent indexes from several goroutines while others
+// search. Run with -race.
+func TestInvertedIndexConcurrent(t *testing.T) {
+	index := NewInvertedIndex()
+
+	const writers, docs = 4, 50
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for n := 0; n < docs; n++ {
+				index.Index(w*docs+n+1, fmt.Sprintf("raft replication writer%d", w))
+			}
+		}(w)
+	}
+
+	done := make(chan struct{})
+	var readers sync.WaitGroup
+	for r := 0; r < 4; r++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				index.Rank(Query{Text: "raft replication"}, 10)
+				index.DocumentFrequency("raft")
+				for it := index.PrefixTerms("writer"); it.Next(); {
+				}
+				index.Encode()
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(done)
+	readers.Wait()
+
+	if df := index.DocumentFrequency("raft"); df != writers*docs {
+		t.Fatalf("expected raft in %d documents, got %d", writers*docs, df)
+	}
+	for w := 0; w < writers; w++ {
+		if df := index.DocumentFrequency(fmt.Sprintf("writer%d", w)); df != docs {
+			t.Fatalf("expected writer%d in %d documents, got %d", w, docs, df)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package index
+
+import (
+	"sort"
+	"unicode/utf8"
+)
+
+// levenshteinAutomaton accepts the strings within maxEdits edits of term. A
+// state is a row of the edit distance table, so stepping it costs O(len(term))
+// and a state that can no longer reach maxEdits tells the caller to abandon
+// every string sharing the prefix read so far.
+//
+// Credit: https://julesjacobs.com/2015/06/17/disqus-levenshtein-simple-and-fast.html
+type levenshteinAutomaton struct {
+	term     []rune
+	maxEdits int
+}
+
+func newLevenshteinAutomaton(term string, maxEdits int) *levenshteinAutomaton {
+	return &levenshteinAutomaton{term: []rune(term), maxEdits: maxEdits}
+}
+
+func (a *levenshteinAutomaton) start() []int {
+	row := make([]int, len(a.term)+1)
+	for i := range row {
+		row[i] = i
+	}
+	return row
+}
+
+func (a *levenshteinAutomaton) step(row []int, r rune) []int {
+	next := make([]int, len(row))
+	next[0] = row[0] + 1
+	for i := 1; i < len(row); i++ {
+		cost := 1
+		if a.term[i-1] == r {
+			cost = 0
+		}
+
+		next[i] = row[i-1] + cost
+		if row[i]+1 < next[i] {
+			next[i] = row[i] + 1
+		}
+		if next[i-1]+1 < next[i] {
+			next[i] = next[i-1] + 1
+		}
+	}
+	return next
+}
+
+func (a *levenshteinAutomaton) distance(row []int) int {
+	return row[len(row)-1]
+}
+
+func (a *levenshteinAutomaton) isMatch(row []int) bool {
+	return a.distance(row) <= a.maxEdits
+}
+
+func (a *levenshteinAutomaton) canMatch(row []int) bool {
+	for _, d := range row {
+		if d <= a.maxEdits {
+			return true
+		}
+	}
+	return false
+}
+
+// fuzzyWeight scales a match down by how much of the shorter term was edited.
+func fuzzyWeight(term, match string, edits int) float64 {
+	shorter := utf8.RuneCountInString(term)
+	if n := utf8.RuneCountInString(match); n < shorter {
+		shorter = n
+	}
+
+	if edits == 0 {
+		return 1
+	}
+
+	if shorter <= edits {
+		return 0
+	}
+	return 1 - float64(edits)/float64(shorter)
+}
+
+// prefixSuccessor returns the first string after every string starting with
+// prefix. Terms are UTF-8, whose bytes are never 0xff.
+func prefixSuccessor(prefix string) string {
+	b := []byte(prefix)
+	b[len(b)-1]++
+	return string(b)
+}
+
+// prefixReader lists the terms starting with a prefix in sorted order, as a
+// TermReader does for its terms.
+type prefixReader interface {
+	PrefixTerms(prefix string) TermIterator
+}
+
+// fuzzyTerms returns up to limit terms of r within maxEdits of term that share
+// its first prefixLength characters, closest first. Terms are visited in
+// dictionary order and the automaton rows are kept per character, so a term
+// only steps the characters it does not share with the previous one, and the
+// iterator seeks past every term extending a prefix the automaton rejects.
+func fuzzyTerms(r prefixReader, term string, maxEdits, prefixLength, limit int) []weightedTerm {
+	runes := []rune(term)
+	if prefixLength > len(runes) {
+		prefixLength = len(runes)
+	}
+
+	prefix := string(runes[:prefixLength])
+	automaton := newLevenshteinAutomaton(string(runes[prefixLength:]), maxEdits)
+
+	type candidate struct {
+		term  string
+		edits int
+	}
+	candidates := []candidate{}
+
+	rows := [][]int{automaton.start()}
+	previous := []rune{}
+
+	it := r.PrefixTerms(prefix)
+	for it.Next() {
+		t := it.Term()
+		suffix := []rune(t[len(prefix):])
+
+		shared := 0
+		for shared < len(previous) && shared < len(suffix) && shared < len(rows)-1 && previous[shared] == suffix[shared] {
+			shared++
+		}
+		rows = rows[:shared+1]
+
+		alive := true
+		for i := shared; i < len(suffix); i++ {
+			row := automaton.step(rows[i], suffix[i])
+			rows = append(rows, row)
+
+			if !automaton.canMatch(row) {
+				alive = false
+				break
+			}
+		}
+		previous = suffix
+
+		if !alive {
+			// every term extending the rejected prefix is rejected too
+			it.Seek(prefixSuccessor(prefix + string(suffix[:len(rows)-1])))
+		}
+
+		if alive && automaton.isMatch(rows[len(suffix)]) {
+			candidates = append(candidates, candidate{term: t, edits: automaton.distance(rows[len(suffix)])})
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].edits < candidates[j].edits
+	})
+
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+
+	terms := make([]weightedTerm, len(candidates))
+	for i, c := range candidates {
+		terms[i] = weightedTerm{term: c.term, weight: fuzzyWeight(term, c.term, c.edits)}
+	}
+	return terms
+}
@@ -0,0 +1,168 @@
+package index
+
+import (
+	"testing"
+)
+
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	row := make([]int, len(rb)+1)
+	for j := range row {
+		row[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		prev := row[0]
+		row[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			next := prev + cost
+			if row[j]+1 < next {
+				next = row[j] + 1
+			}
+			if row[j-1]+1 < next {
+				next = row[j-1] + 1
+			}
+			prev, row[j] = row[j], next
+		}
+	}
+	return row[len(rb)]
+}
+
+func TestLevenshteinAutomaton(t *testing.T) {
+	words := []string{"raft", "rafts", "craft", "draft", "rat", "ra", "fart", "kraft", "raftr", "café", "cafe", "", "consensus"}
+
+	for _, query := range words {
+		for _, word := range words {
+			for maxEdits := 1; maxEdits <= 2; maxEdits++ {
+				a := newLevenshteinAutomaton(query, maxEdits)
+
+				row := a.start()
+				for _, r := range word {
+					row = a.step(row, r)
+				}
+
+				expected := editDistance(query, word) <= maxEdits
+				if a.isMatch(row) != expected {
+					t.Fatalf("%q vs %q within %d: expected %v", query, word, maxEdits, expected)
+				}
+			}
+		}
+	}
+}
+
+func TestFuzzyTerms(t *testing.T) {
+	index := NewInvertedIndex()
+	index.Index(1, "raft rafts craft draft rat fart kraft consensus")
+
+	got := fuzzyTerms(index, "raft", 1, 0, 10)
+	expected := map[string]bool{"raft": true, "craft": true, "draft": true, "rat": true, "kraft": true}
+	if len(got) != len(expected) {
+		t.Fatalf("expected %d terms, got %v", len(expected), got)
+	}
+
+	if got[0].term != "raft" || got[0].weight != 1 {
+		t.Fatalf("expected the exact term first with full weight, got %v", got[0])
+	}
+
+	for _, term := range got {
+		if !expected[term.term] {
+			t.Fatalf("unexpected term %v", term)
+		}
+		if term.term != "raft" && term.weight >= 1 {
+			t.Fatalf("expected %q to weigh less than an exact match", term.term)
+		}
+	}
+
+	got = fuzzyTerms(index, "raft", 1, 1, 10)
+	for _, term := range got {
+		if term.term[0] != 'r' {
+			t.Fatalf("expected only terms starting with r, got %v", got)
+		}
+	}
+
+	if got := fuzzyTerms(index, "raft", 2, 0, 2); len(got) != 2 {
+		t.Fatalf("expected the expansion to be capped at 2 terms, got %v", got)
+	}
+}
+
+// countingReader counts the terms its prefix iterators visit.
+type countingReader struct {
+	TermReader
+	visited int
+}
+
+func (r *countingReader) PrefixTerms(prefix string) TermIterator {
+	return &countingIterator{TermIterator: r.TermReader.PrefixTerms(prefix), r: r}
+}
+
+type countingIterator struct {
+	TermIterator
+	r *countingReader
+}
+
+func (it *countingIterator) Next() bool {
+	ok := it.TermIterator.Next()
+	if ok {
+		it.r.visited++
+	}
+	return ok
+}
+
+func TestFuzzyTermsSeeksPastDeadPrefixes(t *testing.T) {
+	index := NewInvertedIndex()
+	index.Index(1, "raft zza zzb zzc zzd zze zzf zzg zzh zzi zzj")
+
+	mapped, err := OpenInvertedIndex(index.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, r := range []TermReader{index, mapped} {
+		counting := &countingReader{TermReader: r}
+		got := fuzzyTerms(counting, "raft", 1, 0, 10)
+		if len(got) != 1 || got[0].term != "raft" {
+			t.Fatalf("expected only raft, got %v", got)
+		}
+
+		// "zz" is dead after zza, so none of the other zz terms are visited
+		if counting.visited != 2 {
+			t.Fatalf("expected 2 terms visited, got %d", counting.visited)
+		}
+	}
+}
+
+func TestRankFuzzyQuery(t *testing.T) {
+	index := NewInvertedIndex()
+	index.Index(1, "distributed consensus with raft")
+
+	mapped, err := OpenInvertedIndex(index.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, r := range []TextIndex{index, mapped} {
+		exact := r.Rank(Query{Text: "raft consensus"}, 10)
+		typo := r.Rank(Query{Text: "rafy~1 consensus"}, 10)
+		auto := r.Rank(Query{Text: "rafy consensus", Fuzzy: true}, 10)
+
+		if len(exact) != 1 || len(typo) != 1 || len(auto) != 1 {
+			t.Fatalf("expected one match each, got %v, %v, %v", exact, typo, auto)
+		}
+
+		if typo[0].Score >= exact[0].Score || auto[0].Score != typo[0].Score {
+			t.Fatalf("expected fuzzy matches to score lower, got %v, %v, %v", exact, typo, auto)
+		}
+
+		if got := r.Rank(Query{Text: "rafy consensus"}, 10); len(got) != 0 {
+			t.Fatalf("expected no matches without fuzziness, got %v", got)
+		}
+	}
+
+	if err := (Query{Text: "raft~3"}).Validate(); err == nil {
+		t.Fatalf("expected an edit distance of 3 to be rejected")
+	}
+}
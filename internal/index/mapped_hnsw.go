@@ -0,0 +1,122 @@
+package index
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+const (
+	vectorIndexMagic       = "FSVI"
+	vectorIndexHeaderSize  = 32
+	layerEntrySize         = 12
+	vectorRecordHeaderSize = 16
+)
+
+// MappedHNSW searches an encoded HNSW graph in place, usually a memory-mapped
+// segment file. Vectors and neighbour lists are read from the mapping on demand.
+type MappedHNSW struct {
+	L      int
+	mL     float64
+	M      int
+	EFC    int
+	layers []mappedLayer
+}
+
+type mappedLayer struct {
+	elements   []byte
+	count      int
+	recordSize int
+	adjacency  []byte
+}
+
+func OpenHNSW(b []byte) (*MappedHNSW, error) {
+	if err := checkSegmentHeader(b, vectorIndexMagic, vectorIndexHeaderSize); err != nil {
+		return nil, err
+	}
+
+	m := &MappedHNSW{
+		L:   int(binary.LittleEndian.Uint32(b[8:12])),
+		M:   int(binary.LittleEndian.Uint32(b[12:16])),
+		EFC: int(binary.LittleEndian.Uint32(b[16:20])),
+		mL:  math.Float64frombits(binary.LittleEndian.Uint64(b[20:28])),
+	}
+	dimensions := int(binary.LittleEndian.Uint32(b[28:32]))
+	recordSize := vectorRecordHeaderSize + dimensions*8
+
+	layerTableEnd := vectorIndexHeaderSize + m.L*layerEntrySize
+	if layerTableEnd > len(b) {
+		return nil, ErrCorruptSegment
+	}
+
+	// the neighbour lists follow the last element record of the last layer
+	elementsEnd := layerTableEnd
+	for i := 0; i < m.L; i++ {
+		entry := b[vectorIndexHeaderSize+i*layerEntrySize:]
+		count := int(binary.LittleEndian.Uint32(entry[0:4]))
+		start := int(binary.LittleEndian.Uint64(entry[4:12]))
+
+		end := start + count*recordSize
+		if start < layerTableEnd || end > len(b) {
+			return nil, ErrCorruptSegment
+		}
+
+		m.layers = append(m.layers, mappedLayer{elements: b[start:end], count: count, recordSize: recordSize})
+		if end > elementsEnd {
+			elementsEnd = end
+		}
+	}
+
+	for i := range m.layers {
+		m.layers[i].adjacency = b[elementsEnd:]
+	}
+
+	return m, nil
+}
+
+func (l mappedLayer) record(i int) []byte {
+	return l.elements[i*l.recordSize : (i+1)*l.recordSize]
+}
+
+func (l mappedLayer) size() int {
+	return l.count
+}
+
+func (l mappedLayer) id(i int) int {
+	return int(binary.LittleEndian.Uint32(l.record(i)[0:4]))
+}
+
+func (l mappedLayer) entry(i int) int {
+	return int(int32(binary.LittleEndian.Uint32(l.record(i)[4:8])))
+}
+
+func (l mappedLayer) neighbours(i int) []int {
+	r := l.record(i)
+	start := int(binary.LittleEndian.Uint32(r[8:12]))
+	count := int(binary.LittleEndian.Uint32(r[12:16]))
+
+	indices := make([]int, count)
+	for n := range indices {
+		p := (start + n) * 4
+		indices[n] = int(binary.LittleEndian.Uint32(l.adjacency[p : p+4]))
+	}
+	return indices
+}
+
+func (l mappedLayer) vector(i int) []float64 {
+	r := l.record(i)[vectorRecordHeaderSize:]
+
+	v := make([]float64, len(r)/8)
+	for n := range v {
+		v[n] = math.Float64frombits(binary.LittleEndian.Uint64(r[n*8 : n*8+8]))
+	}
+	return v
+}
+
+func (m *MappedHNSW) Search(query VectorNode, ef int, accept func(docID int) bool) []Match {
+	layers := make([]layer, len(m.layers))
+	for i, graph := range m.layers {
+		layers[i] = graph
+	}
+
+	return searchLayers(layers, query, ef, accept)
+}
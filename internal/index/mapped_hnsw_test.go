@@ -0,0 +1,57 @@
+package index
+
+import (
+	"testing"
+)
+
+func TestMappedHNSWMatchesHNSW(t *testing.T) {
+	vectors := []VectorNode{}
+	for i := 1; i <= 500; i++ {
+		v := randomPoint()
+		v.ID = i
+		vectors = append(vectors, v)
+	}
+
+	hnsw := NewHNSW(5, 0.62, 8, 16)
+	hnsw.Create(vectors)
+
+	mapped, err := OpenHNSW(hnsw.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 20; i++ {
+		query := randomPoint()
+
+		expected := hnsw.Search(query, 10, nil)
+		got := mapped.Search(query, 10, nil)
+
+		if len(expected) != len(got) {
+			t.Fatalf("expected %d matches, got %d", len(expected), len(got))
+		}
+
+		for j := range expected {
+			if expected[j].Offsets[0] != got[j].Offsets[0] || expected[j].Score != got[j].Score {
+				t.Fatalf("expected %v, got %v", expected[j], got[j])
+			}
+		}
+	}
+
+	decoded := hnsw.Decode(hnsw.Encode())
+	if len(decoded.Index[len(decoded.Index)-1].Elements) != len(vectors) {
+		t.Fatalf("expected %d decoded vectors, got %d", len(vectors), len(decoded.Index[len(decoded.Index)-1].Elements))
+	}
+}
+
+func TestMappedHNSWEmpty(t *testing.T) {
+	hnsw := NewHNSW(5, 0.62, 8, 16)
+
+	mapped, err := OpenHNSW(hnsw.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := mapped.Search(randomPoint(), 10, nil); len(got) != 0 {
+		t.Fatalf("expected no matches, got %v", got)
+	}
+}
@@ -0,0 +1,257 @@
+package index
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/farouqzaib/fast-search/internal/analyzer"
+)
+
+const (
+	invertedIndexMagic      = "FSII"
+	segmentVersion          = 8
+	invertedIndexHeaderSize = 40
+)
+
+var ErrCorruptSegment = errors.New("index: corrupt segment")
+
+// ErrSegmentVersion is returned for a segment written in another format than
+// segmentVersion, including the gzip-compressed segments written before
+// segments were versioned. There is no migration: the documents of such a
+// segment must be indexed again.
+var ErrSegmentVersion = errors.New("index: unsupported segment version")
+
+// checkSegmentHeader reports whether b starts with magic and a header of
+// headerSize bytes in the current segment format.
+func checkSegmentHeader(b []byte, magic string, headerSize int) error {
+	if len(b) >= 2 && b[0] == 0x1f && b[1] == 0x8b {
+		return fmt.Errorf("%w: segment predates versioned segments, this build reads version %d", ErrSegmentVersion, segmentVersion)
+	}
+
+	if len(b) < headerSize || string(b[:4]) != magic {
+		return ErrCorruptSegment
+	}
+
+	if version := binary.LittleEndian.Uint32(b[4:8]); version != segmentVersion {
+		return fmt.Errorf("%w: segment is version %d, this build reads version %d", ErrSegmentVersion, version, segmentVersion)
+	}
+	return nil
+}
+
+type postingsRef struct {
+	offset int
+	length int
+}
+
+// MappedInvertedIndex serves queries straight from an encoded inverted index,
+// usually a memory-mapped segment file. Opening one decodes nothing: terms are
+// found through the sorted term dictionary and a lookup decodes at most one
+// block of the term's postings.
+type MappedInvertedIndex struct {
+	analyzer     *analyzer.Analyzer
+	schema       *Schema
+	dictionary   *termDictionary
+	completions  *termDictionary
+	docValues    map[string]mappedDocValues
+	postingsData []byte
+}
+
+func OpenInvertedIndex(b []byte) (*MappedInvertedIndex, error) {
+	if err := checkSegmentHeader(b, invertedIndexMagic, invertedIndexHeaderSize); err != nil {
+		return nil, err
+	}
+
+	dictionaryLength := binary.LittleEndian.Uint64(b[8:16])
+	completionsLength := binary.LittleEndian.Uint64(b[16:24])
+	docValuesLength := binary.LittleEndian.Uint64(b[24:32])
+	nameLength := uint64(binary.LittleEndian.Uint32(b[32:36]))
+	schemaLength := uint64(binary.LittleEndian.Uint32(b[36:40]))
+	if invertedIndexHeaderSize+nameLength+schemaLength+dictionaryLength+completionsLength+docValuesLength > uint64(len(b)) {
+		return nil, ErrCorruptSegment
+	}
+
+	schemaStart := invertedIndexHeaderSize + int(nameLength)
+	dictionaryStart := schemaStart + int(schemaLength)
+	completionsStart := dictionaryStart + int(dictionaryLength)
+	docValuesStart := completionsStart + int(completionsLength)
+	postingsStart := docValuesStart + int(docValuesLength)
+
+	a, err := analyzer.Get(string(b[invertedIndexHeaderSize:schemaStart]))
+	if err != nil {
+		return nil, fmt.Errorf("index: opening segment: %w", err)
+	}
+
+	schema, err := decodeSchema(b[schemaStart:dictionaryStart])
+	if err != nil {
+		return nil, fmt.Errorf("index: opening segment: %w", err)
+	}
+
+	dictionary, err := openTermDictionary(b[dictionaryStart:completionsStart])
+	if err != nil {
+		return nil, err
+	}
+
+	completions, err := openTermDictionary(b[completionsStart:docValuesStart])
+	if err != nil {
+		return nil, err
+	}
+
+	docValues, err := openDocValues(b[docValuesStart:postingsStart])
+	if err != nil {
+		return nil, err
+	}
+
+	return &MappedInvertedIndex{analyzer: a, schema: schema, dictionary: dictionary, completions: completions, docValues: docValues, postingsData: b[postingsStart:]}, nil
+}
+
+// Analyzer returns the analyzer the segment was written with.
+func (m *MappedInvertedIndex) Analyzer() *analyzer.Analyzer {
+	return m.analyzer
+}
+
+// UseAnalyzer has queries on the segment analyzed with a in place of the
+// analyzer of the same name it was opened with, such as a copy with the word
+// lists of its collection. It must be called before the segment is searched.
+func (m *MappedInvertedIndex) UseAnalyzer(a *analyzer.Analyzer) {
+	if a.Name == m.analyzer.Name {
+		m.analyzer = a
+	}
+}
+
+// Schema returns the schema the segment was written with.
+func (m *MappedInvertedIndex) Schema() *Schema {
+	return m.schema
+}
+
+// Complete returns the words starting with prefix and their document counts.
+func (m *MappedInvertedIndex) Complete(prefix string) []Completion {
+	completions := []Completion{}
+	for it := m.completions.prefix(prefix); it.Next(); {
+		completions = append(completions, Completion{Text: it.Term(), Frequency: it.current.ref.offset})
+	}
+	return completions
+}
+
+// DocValues returns the values of field by document, the document text for
+// DocumentText.
+func (m *MappedInvertedIndex) DocValues(field string) DocValues {
+	return m.docValues[field]
+}
+
+// CompletionTerms returns the words starting with prefix in sorted order.
+func (m *MappedInvertedIndex) CompletionTerms(prefix string) TermIterator {
+	return m.completions.prefix(prefix)
+}
+
+func (m *MappedInvertedIndex) postings(token string) (postings, bool) {
+	ref, ok := m.dictionary.lookup(token)
+	if !ok || ref.offset+ref.length > len(m.postingsData) {
+		return postings{}, false
+	}
+
+	p, err := openPostings(m.postingsData[ref.offset : ref.offset+ref.length])
+	if err != nil {
+		return postings{}, false
+	}
+	return p, true
+}
+
+// Terms returns the terms in [lower, upper) in sorted order. An empty upper
+// bound is unbounded.
+func (m *MappedInvertedIndex) Terms(lower, upper string) TermIterator {
+	return m.dictionary.terms(lower, upper)
+}
+
+// PrefixTerms returns the terms starting with prefix in sorted order.
+func (m *MappedInvertedIndex) PrefixTerms(prefix string) TermIterator {
+	return m.dictionary.prefix(prefix)
+}
+
+// DocumentFrequency returns the number of documents term occurs in, which is
+// stored up front in its postings.
+func (m *MappedInvertedIndex) DocumentFrequency(term string) int {
+	p, ok := m.postings(term)
+	if !ok {
+		return 0
+	}
+	return p.df
+}
+
+func (m *MappedInvertedIndex) First(token string) (Position, error) {
+	p, ok := m.postings(token)
+	if !ok {
+		return Position{DocumentID: EOF, Offset: EOF}, errors.New("no list exists for token")
+	}
+
+	return p.first(), nil
+}
+
+func (m *MappedInvertedIndex) Last(token string) (Position, error) {
+	p, ok := m.postings(token)
+	if !ok {
+		return Position{DocumentID: EOF, Offset: EOF}, errors.New("no list exists for token")
+	}
+
+	return p.lastPosition(), nil
+}
+
+func (m *MappedInvertedIndex) Next(token string, offset Position) (Position, error) {
+	if offset.Offset == BOF {
+		return m.First(token)
+	}
+
+	if offset.Offset == EOF {
+		return Position{DocumentID: EOF, Offset: EOF}, nil
+	}
+
+	p, ok := m.postings(token)
+	if !ok {
+		return Position{DocumentID: EOF, Offset: EOF}, errors.New("no list exists for token")
+	}
+
+	next, ok := p.next(offset)
+	if !ok {
+		return next, errors.New("no element found")
+	}
+
+	return next, nil
+}
+
+func (m *MappedInvertedIndex) Previous(token string, offset Position) (Position, error) {
+	if offset.Offset == EOF {
+		return m.Last(token)
+	}
+
+	if offset.Offset == BOF {
+		return Position{DocumentID: BOF, Offset: BOF}, nil
+	}
+
+	p, ok := m.postings(token)
+	if !ok {
+		return Position{DocumentID: BOF, Offset: BOF}, errors.New("no list exists for token")
+	}
+
+	previous, ok := p.previous(offset)
+	if !ok {
+		return previous, errors.New("no element found")
+	}
+
+	return previous, nil
+}
+
+func (m *MappedInvertedIndex) NextPhrase(query string, offset Position) []Position {
+	return nextPhrase(m, query, offset)
+}
+
+func (m *MappedInvertedIndex) NextCover(tokens []string, offset Position) []Position {
+	return nextCover(m, tokens, offset)
+}
+
+func (m *MappedInvertedIndex) RankProximity(query string, k int) []Match {
+	return rankProximity(m, query, k)
+}
+
+func (m *MappedInvertedIndex) Rank(q Query, k int) []Match {
+	return rankQuery(m, q, k)
+}
@@ -0,0 +1,119 @@
+package index
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/farouqzaib/fast-search/internal/analyzer"
+)
+
+func TestMappedInvertedIndexMatchesSkipList(t *testing.T) {
+	index := NewInvertedIndex()
+
+	index.Index(1, "raft replicates the log and raft elects a leader, then the leader replicates the log again")
+
+	mapped, err := OpenInvertedIndex(index.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	probes := []Position{
+		{DocumentID: BOF, Offset: BOF},
+		{DocumentID: 1, Offset: 0},
+		{DocumentID: 1, Offset: 3},
+		{DocumentID: 1, Offset: 5},
+		{DocumentID: 1, Offset: 100},
+		{DocumentID: 2, Offset: 0},
+		{DocumentID: EOF, Offset: EOF},
+	}
+
+	for token := range index.PostingsList {
+		for _, probe := range probes {
+			expected, _ := index.Next(token, probe)
+			got, _ := mapped.Next(token, probe)
+			if expected != got {
+				t.Fatalf("next %s after %v: expected %v, got %v", token, probe, expected, got)
+			}
+
+			expected, _ = index.Previous(token, probe)
+			got, _ = mapped.Previous(token, probe)
+			if expected != got {
+				t.Fatalf("previous %s before %v: expected %v, got %v", token, probe, expected, got)
+			}
+		}
+	}
+
+	expected := index.RankProximity("leader log", 10)
+	got := mapped.RankProximity("leader log", 10)
+	if len(expected) != len(got) || expected[0].Score != got[0].Score {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestMappedInvertedIndexMissingToken(t *testing.T) {
+	index := NewInvertedIndex()
+	index.Index(1, "hello batman")
+
+	mapped, err := OpenInvertedIndex(index.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := mapped.Next("joker", Position{DocumentID: BOF, Offset: BOF})
+	if err == nil || got.DocumentID != EOF {
+		t.Fatalf("expected EOF and an error, got %v, %v", got, err)
+	}
+}
+
+func TestOpenInvertedIndexRejectsGarbage(t *testing.T) {
+	_, err := OpenInvertedIndex([]byte("not a segment at all"))
+	if err == nil {
+		t.Fatalf("expected an error for a corrupt segment")
+	}
+}
+
+func TestOpenInvertedIndexRejectsOtherVersions(t *testing.T) {
+	index := NewInvertedIndex()
+	index.Index(1, "raft")
+
+	old := index.Encode()
+	binary.LittleEndian.PutUint32(old[4:8], segmentVersion-1)
+
+	// segments from before versioning were gzip streams
+	for _, b := range [][]byte{old, {0x1f, 0x8b, 8, 0}} {
+		if _, err := OpenInvertedIndex(b); !errors.Is(err, ErrSegmentVersion) {
+			t.Fatalf("expected ErrSegmentVersion, got %v", err)
+		}
+	}
+}
+
+func TestMappedInvertedIndexKeepsAnalyzer(t *testing.T) {
+	keyword, err := analyzer.Get("keyword")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idx := NewInvertedIndexWithAnalyzer(keyword)
+	idx.Index(1, "Raft")
+
+	mapped, err := OpenInvertedIndex(idx.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if mapped.Analyzer().Name != "keyword" {
+		t.Fatalf("expected the keyword analyzer, got %s", mapped.Analyzer().Name)
+	}
+
+	// queries go through the same analyzer, which does not lowercase
+	for _, r := range []TermReader{idx, mapped} {
+		if matches := rankQuery(r, Query{Text: "Raft"}, 10); len(matches) != 1 {
+			t.Fatalf("expected Raft to match, got %v", matches)
+		}
+
+		if matches := rankQuery(r, Query{Text: "raft"}, 10); len(matches) != 0 {
+			t.Fatalf("expected raft not to match, got %v", matches)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package index
+
+import (
+	"encoding/binary"
+	"sort"
+)
+
+// Postings are written in blocks of up to postingsBlockSize positions:
+//
+//	header: document frequency | total positions | block count    (uvarints)
+//	skips:  last position: document ID | offset | start | end |
+//	        block start, per block                                   (uint32s)
+//	blocks: runs of document delta | positions in run |
+//	        offset delta | start delta | length, per position         (uvarints)
+//
+// A run with a document delta of 0 continues the previous document, and its
+// first offset and start are deltas from the previous position rather than
+// from zero. The skip entries are fixed width so Next and Previous can binary
+// search them and decode a single block.
+const (
+	postingsBlockSize = 128
+	skipEntrySize     = 20
+)
+
+func encodePostings(positions []Position) []byte {
+	blocks := []byte{}
+	skips := []byte{}
+
+	df := 0
+	prev := Position{DocumentID: -1}
+	for start := 0; start < len(positions); start += postingsBlockSize {
+		end := start + postingsBlockSize
+		if end > len(positions) {
+			end = len(positions)
+		}
+
+		last := positions[end-1]
+		skips = binary.LittleEndian.AppendUint32(skips, uint32(last.DocumentID))
+		skips = binary.LittleEndian.AppendUint32(skips, uint32(last.Offset))
+		skips = binary.LittleEndian.AppendUint32(skips, uint32(last.Start))
+		skips = binary.LittleEndian.AppendUint32(skips, uint32(last.End))
+		skips = binary.LittleEndian.AppendUint32(skips, uint32(len(blocks)))
+
+		for r := start; r < end; {
+			run := r
+			for run < end && positions[run].DocumentID == positions[r].DocumentID {
+				run++
+			}
+
+			base, byteBase := 0.0, 0
+			if positions[r].DocumentID == prev.DocumentID {
+				base, byteBase = prev.Offset, prev.Start
+			} else {
+				df++
+			}
+
+			docDelta := positions[r].DocumentID - prev.DocumentID
+			if prev.DocumentID < 0 {
+				docDelta = positions[r].DocumentID
+			}
+
+			blocks = binary.AppendUvarint(blocks, uint64(docDelta))
+			blocks = binary.AppendUvarint(blocks, uint64(run-r))
+			for ; r < run; r++ {
+				blocks = binary.AppendUvarint(blocks, uint64(positions[r].Offset-base))
+				blocks = binary.AppendUvarint(blocks, uint64(positions[r].Start-byteBase))
+				blocks = binary.AppendUvarint(blocks, uint64(positions[r].End-positions[r].Start))
+				base, byteBase = positions[r].Offset, positions[r].Start
+			}
+			prev = positions[run-1]
+		}
+	}
+
+	b := binary.AppendUvarint(nil, uint64(df))
+	b = binary.AppendUvarint(b, uint64(len(positions)))
+	b = binary.AppendUvarint(b, uint64((len(positions)+postingsBlockSize-1)/postingsBlockSize))
+	b = append(b, skips...)
+	return append(b, blocks...)
+}
+
+// postings reads one term's encoded postings in place.
+type postings struct {
+	df     int
+	count  int
+	skips  []byte
+	blocks []byte
+	nblock int
+}
+
+func openPostings(b []byte) (postings, error) {
+	p := postings{}
+	header := [3]uint64{}
+	offset := 0
+	for i := range header {
+		v, n := binary.Uvarint(b[offset:])
+		if n <= 0 {
+			return p, ErrCorruptSegment
+		}
+		header[i] = v
+		offset += n
+	}
+
+	p.df, p.count, p.nblock = int(header[0]), int(header[1]), int(header[2])
+	if p.nblock == 0 || offset+p.nblock*skipEntrySize > len(b) {
+		return p, ErrCorruptSegment
+	}
+
+	p.skips = b[offset : offset+p.nblock*skipEntrySize]
+	p.blocks = b[offset+p.nblock*skipEntrySize:]
+	return p, nil
+}
+
+// last returns the final position of block n.
+func (p postings) last(n int) Position {
+	s := p.skips[n*skipEntrySize:]
+	return Position{
+		DocumentID: float64(binary.LittleEndian.Uint32(s[0:4])),
+		Offset:     float64(binary.LittleEndian.Uint32(s[4:8])),
+		Start:      int(binary.LittleEndian.Uint32(s[8:12])),
+		End:        int(binary.LittleEndian.Uint32(s[12:16])),
+	}
+}
+
+// block decodes the positions of block n.
+func (p postings) block(n int) []Position {
+	start := int(binary.LittleEndian.Uint32(p.skips[n*skipEntrySize+16:]))
+	size := p.count - n*postingsBlockSize
+	if size > postingsBlockSize {
+		size = postingsBlockSize
+	}
+
+	prev := Position{DocumentID: 0, Offset: 0}
+	if n > 0 {
+		prev = p.last(n - 1)
+	}
+
+	b := p.blocks[start:]
+	positions := make([]Position, 0, size)
+	for len(positions) < size {
+		docDelta, k := binary.Uvarint(b)
+		b = b[k:]
+		runLength, k := binary.Uvarint(b)
+		b = b[k:]
+
+		doc := prev.DocumentID + float64(docDelta)
+		base, byteBase := 0.0, 0
+		if docDelta == 0 {
+			base, byteBase = prev.Offset, prev.Start
+		}
+
+		for r := uint64(0); r < runLength; r++ {
+			delta, k := binary.Uvarint(b)
+			b = b[k:]
+			startDelta, k := binary.Uvarint(b)
+			b = b[k:]
+			length, k := binary.Uvarint(b)
+			b = b[k:]
+
+			base += float64(delta)
+			byteBase += int(startDelta)
+			prev = Position{DocumentID: doc, Offset: base, Start: byteBase, End: byteBase + int(length)}
+			positions = append(positions, prev)
+		}
+	}
+
+	return positions
+}
+
+func (p postings) all() []Position {
+	positions := make([]Position, 0, p.count)
+	for n := 0; n < p.nblock; n++ {
+		positions = append(positions, p.block(n)...)
+	}
+	return positions
+}
+
+func (p postings) first() Position {
+	return p.block(0)[0]
+}
+
+func (p postings) lastPosition() Position {
+	return p.last(p.nblock - 1)
+}
+
+func positionLess(a, b Position) bool {
+	return a.DocumentID < b.DocumentID || (a.DocumentID == b.DocumentID && a.Offset < b.Offset)
+}
+
+// next returns the first position greater than key.
+func (p postings) next(key Position) (Position, bool) {
+	n := sort.Search(p.nblock, func(i int) bool { return positionLess(key, p.last(i)) })
+	if n == p.nblock {
+		return Position{DocumentID: EOF, Offset: EOF}, false
+	}
+
+	block := p.block(n)
+	i := sort.Search(len(block), func(i int) bool { return positionLess(key, block[i]) })
+	return block[i], true
+}
+
+// previous returns the last position less than key.
+func (p postings) previous(key Position) (Position, bool) {
+	n := sort.Search(p.nblock, func(i int) bool { return !positionLess(p.last(i), key) })
+	if n == p.nblock {
+		return p.lastPosition(), true
+	}
+
+	block := p.block(n)
+	i := sort.Search(len(block), func(i int) bool { return !positionLess(block[i], key) })
+	if i > 0 {
+		return block[i-1], true
+	}
+
+	if n == 0 {
+		return Position{DocumentID: BOF, Offset: BOF}, false
+	}
+	return p.last(n - 1), true
+}
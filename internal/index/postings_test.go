@@ -0,0 +1,91 @@
+package index
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func randomPositions(n int) []Position {
+	seen := map[Position]bool{}
+	positions := []Position{}
+	for len(positions) < n {
+		p := Position{DocumentID: float64(rand.Intn(n/20 + 1)), Offset: float64(rand.Intn(5000))}
+		if !seen[p] {
+			seen[p] = true
+			positions = append(positions, p)
+		}
+	}
+
+	sort.Slice(positions, func(i, j int) bool { return positionLess(positions[i], positions[j]) })
+
+	// byte spans grow with the offset, as they do for analyzed text
+	for i := range positions {
+		positions[i].Start = int(positions[i].Offset) * 8
+		positions[i].End = positions[i].Start + 1 + rand.Intn(7)
+	}
+	return positions
+}
+
+func TestPostingsRoundTrip(t *testing.T) {
+	// more postings than fit in a uint16, which the skip list towers used to be limited to
+	positions := randomPositions(70000)
+
+	p, err := openPostings(encodePostings(positions))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := p.all()
+	if len(got) != len(positions) {
+		t.Fatalf("expected %d positions, got %d", len(positions), len(got))
+	}
+
+	df := 0
+	for i := range positions {
+		if got[i] != positions[i] {
+			t.Fatalf("position %d: expected %v, got %v", i, positions[i], got[i])
+		}
+		if i == 0 || positions[i].DocumentID != positions[i-1].DocumentID {
+			df++
+		}
+	}
+
+	if p.df != df {
+		t.Fatalf("expected document frequency %d, got %d", df, p.df)
+	}
+}
+
+func TestPostingsNextPrevious(t *testing.T) {
+	positions := randomPositions(3000)
+
+	p, err := openPostings(encodePostings(positions))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2000; i++ {
+		key := Position{DocumentID: float64(rand.Intn(160)), Offset: float64(rand.Intn(5000))}
+		if i%2 == 0 {
+			key = positions[rand.Intn(len(positions))]
+		}
+
+		n := sort.Search(len(positions), func(j int) bool { return positionLess(key, positions[j]) })
+		expected := Position{DocumentID: EOF, Offset: EOF}
+		if n < len(positions) {
+			expected = positions[n]
+		}
+		if got, _ := p.next(key); got != expected {
+			t.Fatalf("next after %v: expected %v, got %v", key, expected, got)
+		}
+
+		n = sort.Search(len(positions), func(j int) bool { return !positionLess(positions[j], key) })
+		expected = Position{DocumentID: BOF, Offset: BOF}
+		if n > 0 {
+			expected = positions[n-1]
+		}
+		if got, _ := p.previous(key); got != expected {
+			t.Fatalf("previous before %v: expected %v, got %v", key, expected, got)
+		}
+	}
+}
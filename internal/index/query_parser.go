@@ -1 +1,381 @@
 package index
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/farouqzaib/fast-search/internal/analyzer"
+)
+
+const DefaultMaxExpansions = 128
+
+var ErrInvalidQuery = errors.New("index: invalid query")
+
+// Query is a search request against the full-text index. Text is free text
+// in which a word may also be
+//
+//	distrib*   a prefix query
+//	ra?t, r*t  a wildcard query, ? matches one character and * any number
+//	/ra.t/     a regular expression query, matched against the whole term
+//	rafy~1     a fuzzy query, matching terms within 1 (or, with ~ or ~2, 2) edits
+//
+// and any word may be scoped to a field and boosted, as in
+//
+//	title:raft^3  raft searched in the title only, its score multiplied by 3
+//	text:raft     raft searched in the document text only
+//
+// Each of these multi-term clauses expands to at most MaxExpansions matching
+// terms in every memtable and segment. Fuzzy makes every plain word fuzzy,
+// allowing more edits the longer the word is, and PrefixLength is the number
+// of leading characters a fuzzy match must share with the word exactly.
+// Language analyzes the words as that language, to match documents indexed
+// in it; empty uses the index's own analyzer.
+//
+// Words not scoped to a field are searched in Fields, each optionally boosted
+// as in "title^3", or else in the document text and every indexed text field.
+// Mode is how the scores of a document in several of them combine.
+//
+// Filter restricts full-text and semantic matches alike to the documents
+// whose keyword, number and date fields hold the values it asks for, such as
+// "tenant_id = 42 AND created_at > 2026-01-01"; see parseFilter.
+// Aggregations summarize fields over the documents the query matches, by
+// name.
+//
+// Sort orders matches by fields or score rather than taking the best scores,
+// and SearchAfter, the Cursor of the last match of a page, pages on from it.
+type Query struct {
+	Text          string
+	MaxExpansions int
+	Fuzzy         bool
+	PrefixLength  int
+	Language      string
+	Fields        []string
+	Mode          string
+	Filter        string
+	Aggregations  map[string]Aggregation
+	Sort          []SortField
+	SearchAfter   string
+
+	// passing is Filter resolved against the reader the query runs
+	// against by withFilter.
+	passing *filter
+}
+
+const (
+	// MostFields scores a document the sum of its scores in every field it
+	// matches in, favouring documents matching in many fields. It is the
+	// default.
+	MostFields = "most_fields"
+	// BestFields scores a document its score in the field it matches best
+	// in, favouring documents matching the whole query in one field.
+	BestFields = "best_fields"
+)
+
+// DocumentText is the name the document text goes by in queries, which no
+// field may take.
+const DocumentText = "text"
+
+type clauseKind int
+
+const (
+	textClause clauseKind = iota
+	prefixClause
+	patternClause
+	fuzzyClause
+)
+
+// queryClause is one whitespace-separated part of a query. Text clauses are
+// analyzed like documents are; the others are matched against the term
+// dictionary as is.
+type queryClause struct {
+	kind    clauseKind
+	text    string
+	prefix  string
+	pattern *regexp.Regexp
+	edits   int
+	// field is the field the clause is scoped to, empty for none.
+	field string
+	boost float64
+}
+
+// Validate reports whether the query can be parsed.
+func (q Query) Validate() error {
+	if q.Language != "" {
+		if _, err := analyzer.Language(q.Language); err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidQuery, err)
+		}
+	}
+
+	if q.Mode != "" && q.Mode != MostFields && q.Mode != BestFields {
+		return fmt.Errorf("%w: mode must be %q or %q, got %q", ErrInvalidQuery, MostFields, BestFields, q.Mode)
+	}
+
+	for _, f := range q.Fields {
+		if _, _, err := parseBoost(f); err != nil {
+			return err
+		}
+	}
+
+	if _, err := parseFilter(q.Filter); err != nil {
+		return err
+	}
+
+	for name, a := range q.Aggregations {
+		switch a.Type {
+		case TermsAggregation, RangeAggregation, HistogramAggregation, StatsAggregation:
+		default:
+			return fmt.Errorf("%w: aggregation %q: unknown type %q", ErrInvalidQuery, name, a.Type)
+		}
+	}
+
+	if err := q.checkSort(); err != nil {
+		return err
+	}
+
+	_, err := parseQuery(q.Text, nil)
+	return err
+}
+
+// ValidateFields reports whether every field the query's fields, filter,
+// aggregations and sort name is the document text or a field s declares, and
+// suits what it is used for. Words are only scoped to the fields s declares,
+// so they need no checking.
+func (q Query) ValidateFields(s *Schema) error {
+	filters, err := parseFilter(q.Filter)
+	if err != nil {
+		return err
+	}
+	for _, f := range filters {
+		if err := f.check(s); err != nil {
+			return err
+		}
+	}
+
+	for _, a := range q.Aggregations {
+		if err := a.check(s); err != nil {
+			return err
+		}
+	}
+
+	if err := q.checkSortFields(s); err != nil {
+		return err
+	}
+
+	if _, err := parseQuery(q.Text, s); err != nil {
+		return err
+	}
+
+	for _, f := range q.Fields {
+		name, _, _ := parseBoost(f)
+		if _, ok := s.Field(name); !ok && name != DocumentText {
+			return fmt.Errorf("%w: unknown field %q", ErrInvalidQuery, name)
+		}
+	}
+	return nil
+}
+
+// searchFields returns the fields of r the words of q not scoped to a field
+// are searched in.
+func (q Query) searchFields(r TermReader) []*fieldReader {
+	if len(q.Fields) == 0 {
+		return searchFields(r)
+	}
+
+	fields := []*fieldReader{}
+	for _, f := range q.Fields {
+		name, boost, _ := parseBoost(f)
+		if field := scopedReader(r, name); field != nil {
+			field.boost = boost
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// combine returns the score of a document scoring score in one more field.
+func (q Query) combine(total, score float64) float64 {
+	if q.Mode == BestFields {
+		return math.Max(total, score)
+	}
+	return total + score
+}
+
+// analyzer returns the analyzer for the query's words against r. Fields with
+// an analyzer of their own keep it whatever the query's language.
+func (q Query) analyzer(r TermReader) (*analyzer.Analyzer, error) {
+	if f, ok := r.(*fieldReader); q.Language == "" || ok && f.ownAnalyzer {
+		return r.Analyzer(), nil
+	}
+
+	a, err := r.Analyzer().ForLanguage(q.Language)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidQuery, err)
+	}
+	return a, nil
+}
+
+func (q Query) maxExpansions() int {
+	if q.MaxExpansions <= 0 {
+		return DefaultMaxExpansions
+	}
+	return q.MaxExpansions
+}
+
+// autoFuzziness is the number of edits a fuzzy match of token may take when
+// Query.Fuzzy picks it, growing with the length of the token.
+func autoFuzziness(token string) int {
+	switch n := utf8.RuneCountInString(token); {
+	case n <= 2:
+		return 0
+	case n <= 5:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// parseQuery splits text into clauses, rejecting malformed patterns and
+// boosts. A word is scoped to a field only if what comes before its colon is
+// the document text or a field s declares, so that words such as
+// "http://example.com" or "error:timeout" are searched as written. Runs of
+// plain words scoped to the same field with the same boost make a single
+// text clause.
+func parseQuery(text string, s *Schema) ([]queryClause, error) {
+	clauses := []queryClause{}
+
+	for _, word := range strings.Fields(text) {
+		word, boost, err := parseBoost(word)
+		if err != nil {
+			return nil, err
+		}
+
+		field := ""
+		if colon := strings.Index(word, ":"); colon > 0 && colon < len(word)-1 && isField(s, word[:colon]) {
+			field, word = word[:colon], word[colon+1:]
+		}
+
+		c, err := parseWord(word)
+		if err != nil {
+			return nil, err
+		}
+		c.field, c.boost = field, boost
+
+		// consecutive words are analyzed together, so that the analyzer
+		// sees phrases such as multi-word synonyms whole
+		if last := len(clauses) - 1; c.kind == textClause && last >= 0 {
+			if p := clauses[last]; p.kind == textClause && p.field == field && p.boost == boost {
+				clauses[last].text += " " + c.text
+				continue
+			}
+		}
+		clauses = append(clauses, c)
+	}
+
+	return clauses, nil
+}
+
+// parseWord parses a word of a query, less its field and boost.
+func parseWord(word string) (queryClause, error) {
+	switch {
+	case len(word) > 2 && strings.HasPrefix(word, "/") && strings.HasSuffix(word, "/"):
+		expr := word[1 : len(word)-1]
+		pattern, err := regexp.Compile("^(?:" + expr + ")$")
+		if err != nil {
+			return queryClause{}, fmt.Errorf("%w: regular expression %q: %s", ErrInvalidQuery, expr, err)
+		}
+
+		prefix, _ := pattern.LiteralPrefix()
+		return queryClause{kind: patternClause, text: word, prefix: prefix, pattern: pattern}, nil
+	case isFuzzy(word):
+		tilde := strings.LastIndex(word, "~")
+
+		edits := 2
+		switch word[tilde+1:] {
+		case "", "2":
+		case "1":
+			edits = 1
+		default:
+			return queryClause{}, fmt.Errorf("%w: fuzzy edit distance in %q must be 1 or 2", ErrInvalidQuery, word)
+		}
+
+		return queryClause{kind: fuzzyClause, text: word[:tilde], edits: edits}, nil
+	case strings.ContainsAny(word, "*?"):
+		word = strings.ToLower(word)
+		meta := strings.IndexAny(word, "*?")
+
+		if meta == len(word)-1 && word[meta] == '*' {
+			return queryClause{kind: prefixClause, text: word, prefix: word[:meta]}, nil
+		}
+
+		return queryClause{kind: patternClause, text: word, prefix: word[:meta], pattern: wildcardPattern(word)}, nil
+	}
+	return queryClause{kind: textClause, text: word}, nil
+}
+
+// isFuzzy reports whether word is a term followed by ~ and at most an edit
+// distance, such as raft~ or raft~1. Other words holding a ~ are plain terms.
+func isFuzzy(word string) bool {
+	tilde := strings.LastIndex(word, "~")
+	return tilde > 0 && strings.Trim(word[tilde+1:], "0123456789") == ""
+}
+
+// parseBoost splits a trailing boost such as ^3 or ^0.5 off word. Words with
+// no number after their last ^ have no boost, and keep the ^.
+func parseBoost(word string) (string, float64, error) {
+	caret := strings.LastIndex(word, "^")
+	if caret <= 0 || strings.Trim(word[caret+1:], "0123456789.") != "" || word[caret+1:] == "" {
+		return word, 1, nil
+	}
+
+	boost, err := strconv.ParseFloat(word[caret+1:], 64)
+	if err != nil || boost <= 0 {
+		return "", 0, fmt.Errorf("%w: boost in %q must be a positive number", ErrInvalidQuery, word)
+	}
+	return word[:caret], boost, nil
+}
+
+// isField reports whether name is the document text or a field s declares.
+func isField(s *Schema, name string) bool {
+	if name == DocumentText {
+		return true
+	}
+	_, ok := s.Field(name)
+	return ok
+}
+
+// isFieldName reports whether name can be the name of a field, which starts
+// with a letter or underscore.
+func isFieldName(name string) bool {
+	for i, r := range name {
+		switch {
+		case r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z':
+		case i > 0 && (r >= '0' && r <= '9' || r == '.' || r == '-'):
+		default:
+			return false
+		}
+	}
+	return name != ""
+}
+
+func wildcardPattern(word string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+	for _, r := range word {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+
+	return regexp.MustCompile(b.String())
+}
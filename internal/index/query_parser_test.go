@@ -0,0 +1,150 @@
+package index
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseQuery(t *testing.T) {
+	clauses, err := parseQuery("Distrib* ra?t /lo.+/ consensus", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []clauseKind{prefixClause, patternClause, patternClause, textClause}
+	if len(clauses) != len(expected) {
+		t.Fatalf("expected %d clauses, got %d", len(expected), len(clauses))
+	}
+
+	for i, kind := range expected {
+		if clauses[i].kind != kind {
+			t.Fatalf("clause %d: expected kind %v, got %v", i, kind, clauses[i].kind)
+		}
+	}
+
+	if clauses[0].prefix != "distrib" || clauses[1].prefix != "ra" || clauses[2].prefix != "lo" {
+		t.Fatalf("unexpected literal prefixes %q, %q, %q", clauses[0].prefix, clauses[1].prefix, clauses[2].prefix)
+	}
+
+	if !clauses[1].pattern.MatchString("raft") || clauses[1].pattern.MatchString("rafts") {
+		t.Fatalf("expected ra?t to match exactly one character")
+	}
+
+	err = Query{Text: "/[a-/"}.Validate()
+	if !errors.Is(err, ErrInvalidQuery) {
+		t.Fatalf("expected an invalid query error, got %v", err)
+	}
+
+	// only a trailing ~ with at most an edit distance makes a word fuzzy
+	for word, kind := range map[string]clauseKind{"raft~": fuzzyClause, "raft~1": fuzzyClause, "a~b": textClause, "~": textClause, "~2": textClause} {
+		c, err := parseWord(word)
+		if err != nil {
+			t.Fatalf("%q: %v", word, err)
+		}
+		if c.kind != kind {
+			t.Fatalf("%q: expected kind %v, got %v", word, kind, c.kind)
+		}
+	}
+}
+
+func TestRankMultiTermQueries(t *testing.T) {
+	index := NewInvertedIndex()
+	index.Index(1, "distributed consensus with raft keeps replicated logs consistent")
+
+	mapped, err := OpenInvertedIndex(index.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, text := range []string{"distrib*", "ra?t", "r*t consensus", "/replic.*/", "distrib* /log.?/"} {
+		for _, r := range []TextIndex{index, mapped} {
+			got := r.Rank(Query{Text: text}, 10)
+			if len(got) != 1 || got[0].Offsets[0].GetDocumentID() != 1 {
+				t.Fatalf("%q: expected document 1, got %v", text, got)
+			}
+		}
+	}
+
+	for _, text := range []string{"paxos*", "ra?", "/x.*/", "raft paxos*"} {
+		if got := index.Rank(Query{Text: text}, 10); len(got) != 0 {
+			t.Fatalf("%q: expected no matches, got %v", text, got)
+		}
+	}
+}
+
+func TestExpandClauseLimit(t *testing.T) {
+	index := NewInvertedIndex()
+	index.Index(1, "cat catalog catapult category cattle caterpillar")
+
+	rewrites, err := expandQuery(index, Query{Text: "cat*", MaxExpansions: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rewrites) != 1 {
+		t.Fatalf("expected the query as written only, got %v", rewrites)
+	}
+
+	if clauses := rewrites[0]; len(clauses) != 1 || len(clauses[0]) != 2 {
+		t.Fatalf("expected one clause expanded to 2 terms, got %v", clauses)
+	}
+}
+
+func TestQueryLanguage(t *testing.T) {
+	index := NewInvertedIndex()
+	if err := index.IndexLanguage(1, "Les imprimantes ne fonctionnent plus", "fr"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the english stemmer leaves "imprimante" alone, so only a french query
+	// reduces it to the indexed stem
+	if matches := index.Rank(Query{Text: "imprimante"}, 10); len(matches) != 0 {
+		t.Fatalf("expected no english match, got %v", matches)
+	}
+
+	if matches := index.Rank(Query{Text: "imprimante", Language: "french"}, 10); len(matches) != 1 {
+		t.Fatalf("expected a french match, got %v", matches)
+	}
+
+	if err := (Query{Text: "imprimante", Language: "klingon"}).Validate(); !errors.Is(err, ErrInvalidQuery) {
+		t.Fatalf("expected ErrInvalidQuery, got %v", err)
+	}
+}
+
+func TestParseFieldsAndBoosts(t *testing.T) {
+	s, err := ParseSchema(strings.NewReader(`{"fields": {"title": {"type": "text"}, "body": {"type": "text"}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clauses, err := parseQuery("title:raft^3 title:paper body:consensus leader^0.5 /^ra/ 10:30 error:timeout", s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []queryClause{
+		{kind: textClause, text: "raft", field: "title", boost: 3},
+		{kind: textClause, text: "paper", field: "title", boost: 1},
+		{kind: textClause, text: "consensus", field: "body", boost: 1},
+		{kind: textClause, text: "leader", boost: 0.5},
+		{kind: patternClause, text: "/^ra/", boost: 1},
+		{kind: textClause, text: "10:30 error:timeout", boost: 1},
+	}
+	if len(clauses) != len(expected) {
+		t.Fatalf("expected %d clauses, got %v", len(expected), clauses)
+	}
+
+	for i, e := range expected {
+		c := clauses[i]
+		if c.kind != e.kind || c.text != e.text || c.field != e.field || c.boost != e.boost {
+			t.Fatalf("clause %d: expected %+v, got %+v", i, e, c)
+		}
+	}
+
+	for _, q := range []Query{{Text: "raft^0"}, {Text: "raft", Fields: []string{"title^0"}}, {Text: "raft", Mode: "all_fields"}} {
+		if err := q.Validate(); !errors.Is(err, ErrInvalidQuery) {
+			t.Fatalf("expected %+v to be invalid, got %v", q, err)
+		}
+	}
+}
@@ -1 +1,597 @@
 package index
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"sort"
+
+	"github.com/farouqzaib/fast-search/internal/analyzer"
+)
+
+// PostingsReader is the read side of an inverted index. The ranking functions
+// below only need these four primitives, so they work the same over the
+// in-memory skip lists and over a memory-mapped segment.
+type PostingsReader interface {
+	First(token string) (Position, error)
+	Last(token string) (Position, error)
+	Next(token string, offset Position) (Position, error)
+	Previous(token string, offset Position) (Position, error)
+}
+
+// TermReader is a PostingsReader whose terms can be enumerated, which is what
+// multi-term queries expand against.
+type TermReader interface {
+	PostingsReader
+	// Analyzer is what the reader's documents were analyzed with, and so
+	// what queries against them must be analyzed with too.
+	Analyzer() *analyzer.Analyzer
+	// Schema declares the fields of the reader's documents.
+	Schema() *Schema
+	Terms(lower, upper string) TermIterator
+	PrefixTerms(prefix string) TermIterator
+	DocumentFrequency(term string) int
+	// DocValues returns the values of a field by document.
+	DocValues(field string) DocValues
+}
+
+type Match struct {
+	Offsets []Position
+	Score   float64
+	// Field is the field Offsets and Highlights are positions in, empty for
+	// the document text.
+	Field string
+	// Highlights are the positions of every query term in the matched
+	// document, in order, for building snippets.
+	Highlights []Position
+	// Sort are the values a sorted query orders the match by, nil where
+	// its document has none.
+	Sort []interface{}
+}
+
+// phraseTerm is a term of a phrase and its position relative to the first.
+type phraseTerm struct {
+	term     string
+	position float64
+}
+
+// analyzePhrase analyzes query into the terms of a phrase, keeping the gaps
+// left by removed stopwords so they must line up with the gaps in a document.
+// A variant replaces the token it is a form of, since documents are indexed
+// under the variant whichever form they use. A query rewritten by its
+// analyzer, such as into its synonyms, is a phrase per rewrite.
+func analyzePhrase(a *analyzer.Analyzer, query string) [][]phraseTerm {
+	phrases := [][]phraseTerm{}
+	for _, tokens := range a.AnalyzeQuery(query) {
+		positions := analyzer.Positions(tokens)
+
+		terms := make([]phraseTerm, 0, len(tokens))
+		for j, token := range tokens {
+			term := phraseTerm{term: token.Term, position: float64(positions[j] - positions[0])}
+			if token.Variant && len(terms) > 0 {
+				terms[len(terms)-1] = term
+				continue
+			}
+			terms = append(terms, term)
+		}
+		phrases = append(phrases, terms)
+	}
+	return phrases
+}
+
+// nextPhrase returns the first and last positions of the next occurrence
+// after offset of any phrase query may be analyzed into.
+func nextPhrase(r TermReader, query string, offset Position) []Position {
+	next := []Position{{DocumentID: EOF, Offset: EOF}, {DocumentID: EOF, Offset: EOF}}
+	for _, terms := range analyzePhrase(r.Analyzer(), query) {
+		if phrase := nextPhraseTerms(r, terms, offset); positionLess(phrase[0], next[0]) {
+			next = phrase
+		}
+	}
+	return next
+}
+
+// nextPhraseTerms returns the first and last positions of the next phrase
+// starting after offset. Every term's positions are shifted back by its
+// position in the phrase, so a phrase is where the shifted positions of all
+// its terms coincide.
+func nextPhraseTerms(p PostingsReader, terms []phraseTerm, offset Position) []Position {
+	if len(terms) == 0 {
+		return []Position{{DocumentID: EOF, Offset: EOF}, {DocumentID: EOF, Offset: EOF}}
+	}
+
+	// the furthest next occurrence; no phrase can start before it
+	v := offset
+	for j, t := range terms {
+		n, _ := p.Next(t.term, Position{DocumentID: offset.DocumentID, Offset: offset.Offset + t.position})
+		if n.DocumentID == EOF {
+			return []Position{{DocumentID: EOF, Offset: EOF}, {DocumentID: EOF, Offset: EOF}}
+		}
+
+		shifted := Position{DocumentID: n.DocumentID, Offset: n.Offset - t.position}
+		if j == 0 || positionLess(v, shifted) {
+			v = shifted
+		}
+	}
+
+	phrase := make([]Position, len(terms))
+	for j, t := range terms {
+		n, _ := p.Next(t.term, Position{DocumentID: v.DocumentID, Offset: v.Offset + t.position - 1})
+		if n.DocumentID != v.DocumentID || n.Offset != v.Offset+t.position {
+			return nextPhraseTerms(p, terms, v)
+		}
+		phrase[j] = n
+	}
+
+	return []Position{phrase[0], phrase[len(phrase)-1]}
+}
+
+func findAllPhrases(r TermReader, query string, offset Position) [][]Position {
+	u := Position{DocumentID: BOF, Offset: BOF}
+
+	positions := [][]Position{}
+
+	for u.DocumentID != EOF {
+		offsets := nextPhrase(r, query, u)
+		u = offsets[0]
+
+		if u.DocumentID != EOF && u.Offset != EOF {
+			positions = append(positions, offsets)
+		}
+	}
+
+	return positions
+}
+
+// variantWeight is the weight of a variant of a query term, such as its
+// accent-folded spelling, so text spelled as the query is spelled ranks
+// higher than text matching only once folded.
+const variantWeight = 0.8
+
+// weightedTerm is one of the terms a query clause matches. Exact terms weigh
+// 1; expansions that only approximate the query, such as fuzzy matches, weigh
+// less so the covers they take part in score less.
+type weightedTerm struct {
+	term   string
+	weight float64
+}
+
+// clause is a disjunction: a position matches it through any of its terms.
+type clause []weightedTerm
+
+func nextCover(p PostingsReader, tokens []string, offset Position) []Position {
+	clauses := make([]clause, len(tokens))
+	for j, token := range tokens {
+		clauses[j] = clause{{term: token, weight: 1}}
+	}
+
+	cover, _ := nextClauseCover(p, clauses, offset)
+	return cover
+}
+
+// clauseNext is Next over a disjunction of terms.
+func clauseNext(p PostingsReader, c clause, offset Position) Position {
+	next := Position{DocumentID: EOF, Offset: EOF}
+	for _, t := range c {
+		n, _ := p.Next(t.term, offset)
+		if positionLess(n, next) {
+			next = n
+		}
+	}
+	return next
+}
+
+// clausePrevious is Previous over a disjunction of terms. It also returns the
+// weight of the term found, preferring the heavier term on a tie.
+func clausePrevious(p PostingsReader, c clause, offset Position) (Position, float64) {
+	previous := Position{DocumentID: BOF, Offset: BOF}
+	weight := 0.0
+	for _, t := range c {
+		n, _ := p.Previous(t.term, offset)
+		if positionLess(previous, n) || (!positionLess(n, previous) && t.weight > weight) {
+			previous = n
+			weight = t.weight
+		}
+	}
+	return previous, weight
+}
+
+// nextClauseCover returns the next cover of the clauses after offset, where a
+// clause is covered by any one of its terms, along with the product of the
+// weights of the terms that make up the cover.
+func nextClauseCover(p PostingsReader, clauses []clause, offset Position) ([]Position, float64) {
+	v := offset
+
+	for j, c := range clauses {
+		localMax := clauseNext(p, c, offset)
+
+		//break if localMax is ever EOF
+		if localMax.DocumentID == EOF {
+			v = localMax
+			break
+		}
+
+		if j == 0 {
+			v = localMax
+			continue
+		}
+
+		if localMax.DocumentID > v.DocumentID || (localMax.DocumentID == v.DocumentID && localMax.Offset > v.Offset) {
+			v = localMax
+		}
+	}
+
+	if v.DocumentID == EOF {
+		return []Position{{DocumentID: EOF, Offset: EOF}, {DocumentID: EOF, Offset: EOF}}, 0
+	}
+
+	u := Position{DocumentID: BOF, Offset: BOF}
+	weight := 1.0
+
+	for j, c := range clauses {
+		localMin, w := clausePrevious(p, c, Position{DocumentID: v.DocumentID, Offset: v.Offset + 1})
+		weight *= w
+
+		if j == 0 {
+			u = localMin
+			continue
+		}
+
+		if localMin.DocumentID < u.DocumentID || (localMin.Offset == u.Offset && localMin.Offset < u.Offset) {
+			u = localMin
+		}
+	}
+
+	if u.DocumentID == v.DocumentID {
+		return []Position{u, v}, weight
+	}
+
+	return nextClauseCover(p, clauses, u)
+}
+
+// expandQuery turns the clauses of q into the terms they match in r.
+func expandQuery(r TermReader, q Query) ([][]clause, error) {
+	clauses, err := parseQuery(q.Text, r.Schema())
+	if err != nil {
+		return nil, err
+	}
+	return expandClauses(r, q, clauses)
+}
+
+// expandClauses turns query clauses into the terms they match in r. Text
+// clauses are analyzed, and each resulting token must match on its own or
+// through its variants, or approximately when q.Fuzzy is set; the other
+// clauses match any of up to q.MaxExpansions terms. A query the analyzer
+// rewrites, such as into its synonyms, expands into the clauses of each
+// rewrite, the query as written first. A boosted query clause multiplies
+// the weight of its first term clause, and so of every cover it is part of.
+func expandClauses(r TermReader, q Query, clauses []queryClause) ([][]clause, error) {
+	a, err := q.analyzer(r)
+	if err != nil {
+		return nil, err
+	}
+
+	rewrites := [][]clause{{}}
+	for _, c := range clauses {
+		alternatives := [][]clause{}
+		switch c.kind {
+		case textClause:
+			text := c.text
+			if f, ok := r.(*fieldReader); ok {
+				text = f.queryText(text)
+			}
+
+			for _, tokens := range a.AnalyzeQuery(text) {
+				alternatives = append(alternatives, tokenClauses(r, q, tokens))
+			}
+		case fuzzyClause:
+			for _, tokens := range a.AnalyzeQuery(c.text) {
+				expanded := []clause{}
+				for _, token := range tokens {
+					if token.Variant {
+						continue
+					}
+					expanded = append(expanded, fuzzyTerms(r, token.Term, c.edits, q.PrefixLength, q.maxExpansions()))
+				}
+				alternatives = append(alternatives, expanded)
+			}
+		case prefixClause, patternClause:
+			alternatives = append(alternatives, []clause{expandClause(r, c, q.maxExpansions())})
+		}
+
+		if c.boost != 0 && c.boost != 1 {
+			for _, alternative := range alternatives {
+				if len(alternative) > 0 {
+					alternative[0] = boostClause(alternative[0], c.boost)
+				}
+			}
+		}
+
+		next := [][]clause{}
+		for _, rewrite := range rewrites {
+			for _, alternative := range alternatives {
+				if len(next) < analyzer.MaxQueryRewrites {
+					next = append(next, append(append([]clause{}, rewrite...), alternative...))
+				}
+			}
+		}
+		rewrites = next
+	}
+
+	return rewrites, nil
+}
+
+func boostClause(c clause, boost float64) clause {
+	boosted := make(clause, len(c))
+	for i, t := range c {
+		boosted[i] = weightedTerm{term: t.term, weight: t.weight * boost}
+	}
+	return boosted
+}
+
+// tokenClauses turns the analyzed tokens of a text clause into a clause per
+// token, with the variants of a token among its terms.
+func tokenClauses(r TermReader, q Query, tokens []analyzer.Token) []clause {
+	expanded := []clause{}
+	for _, token := range tokens {
+		switch {
+		case token.Variant && q.Fuzzy:
+			// fuzzy matching already reaches the variants
+		case token.Variant && len(expanded) > 0:
+			last := len(expanded) - 1
+			expanded[last] = append(expanded[last], weightedTerm{term: token.Term, weight: variantWeight})
+		case q.Fuzzy:
+			expanded = append(expanded, fuzzyTerms(r, token.Term, autoFuzziness(token.Term), q.PrefixLength, q.maxExpansions()))
+		default:
+			expanded = append(expanded, clause{{term: token.Term, weight: 1}})
+		}
+	}
+	return expanded
+}
+
+func expandClause(r TermReader, c queryClause, limit int) clause {
+	terms := clause{}
+	for it := r.PrefixTerms(c.prefix); it.Next() && len(terms) < limit; {
+		if c.pattern == nil || c.pattern.MatchString(it.Term()) {
+			terms = append(terms, weightedTerm{term: it.Term(), weight: 1})
+		}
+	}
+	return terms
+}
+
+func rankProximity(r TermReader, query string, k int) []Match {
+	return rankQuery(r, Query{Text: query}, k)
+}
+
+// rankQuery ranks the documents matching q. The words of q scoped to a field
+// are searched in that field, and the others in every field q searches, which
+// score a document as q.Mode combines its scores in them. A document scores
+// the sum of its scores for each field scoped to and for the other words, and
+// its offsets and highlights are those of the field it scores best in.
+func rankQuery(r TermReader, q Query, k int) []Match {
+	slog.Info("index: proximity ranking")
+
+	return rankMatches(q, matchQuery(r, q), k)
+}
+
+// rankMatches returns the first k of the matches of q, with their highlights,
+// or every one of them if q is sorted.
+func rankMatches(q Query, ranked []fieldMatch, k int) []Match {
+	if q.Sorted() {
+		// sorted matches are cut once they are in order, after merging
+		k = len(ranked)
+	}
+	sort.SliceStable(ranked, func(a, b int) bool {
+		return ranked[a].Offsets[0].DocumentID < ranked[b].Offsets[0].DocumentID
+	})
+
+	top := make([]Match, 0, len(ranked))
+	for _, m := range ranked[:int(math.Min(float64(k), float64(len(ranked))))] {
+		m.Highlights = termPositions(m.reader, m.clauses, m.Offsets[0].DocumentID)
+		top = append(top, m.Match)
+	}
+
+	return top
+}
+
+// matchQuery returns a match for every document of r matching q that passes
+// its filter, unordered.
+func matchQuery(r TermReader, q Query) []fieldMatch {
+	q = q.withFilter(r)
+	clauses, err := parseQuery(q.Text, r.Schema())
+	if err != nil {
+		slog.Error("index: parsing query", slog.String("error", err.Error()))
+		return []fieldMatch{}
+	}
+
+	unscoped := []queryClause{}
+	scoped := map[string][]queryClause{}
+	names := []string{}
+	for _, c := range clauses {
+		if c.field == "" {
+			unscoped = append(unscoped, c)
+			continue
+		}
+
+		if _, ok := scoped[c.field]; !ok {
+			names = append(names, c.field)
+		}
+		scoped[c.field] = append(scoped[c.field], c)
+	}
+
+	add := func(total float64, score float64) float64 { return total + score }
+	results := newMatchSet()
+	if len(unscoped) > 0 {
+		fields := newMatchSet()
+		for _, f := range q.searchFields(r) {
+			matches, err := rankField(f, q, unscoped)
+			if err != nil {
+				slog.Error("index: parsing query", slog.String("error", err.Error()))
+				return []fieldMatch{}
+			}
+			fields.addAll(matches, q.combine)
+		}
+		results.addAll(fields.matches, add)
+	}
+
+	for _, name := range names {
+		f := scopedReader(r, name)
+		if f == nil {
+			continue
+		}
+
+		matches, err := rankField(f, q, scoped[name])
+		if err != nil {
+			slog.Error("index: parsing query", slog.String("error", err.Error()))
+			return []fieldMatch{}
+		}
+		results.addAll(matches, add)
+	}
+
+	// filtering before the cut leaves k matches whenever k pass the filter
+	matched := []fieldMatch{}
+	for _, m := range results.matches {
+		if q.accepts(m.Offsets[0].DocumentID) {
+			matched = append(matched, m)
+		}
+	}
+	return matched
+}
+
+// fieldMatch is a match in a field, along with what its highlights are drawn
+// from.
+type fieldMatch struct {
+	Match
+	reader  *fieldReader
+	clauses []clause
+	// best is the score of the match on its own, before combining it with
+	// other matches of its document.
+	best float64
+}
+
+// matchSet keeps a match per document, the one scoring best on its own, with
+// the combined score of every match of the document added to it.
+type matchSet struct {
+	documents map[float64]int
+	matches   []fieldMatch
+}
+
+func newMatchSet() *matchSet {
+	return &matchSet{documents: map[float64]int{}}
+}
+
+func (s *matchSet) addAll(matches []fieldMatch, combine func(total, score float64) float64) {
+	for _, m := range matches {
+		doc := m.Offsets[0].DocumentID
+		j, ok := s.documents[doc]
+		if !ok {
+			m.best = m.Score
+			s.documents[doc] = len(s.matches)
+			s.matches = append(s.matches, m)
+			continue
+		}
+
+		score := combine(s.matches[j].Score, m.Score)
+		if m.Score > s.matches[j].best {
+			m.best = m.Score
+			s.matches[j] = m
+		}
+		s.matches[j].Score = score
+	}
+}
+
+// rankField scores every document matching clauses in field f, in document
+// order, multiplied by the field's boost. A document matching several
+// rewrites of the clauses scores as its best match.
+func rankField(f *fieldReader, q Query, queryClauses []queryClause) ([]fieldMatch, error) {
+	rewrites, err := expandClauses(f, q, queryClauses)
+	if err != nil {
+		return nil, err
+	}
+
+	best := map[float64]int{}
+	results := []fieldMatch{}
+	for _, clauses := range rewrites {
+		for _, m := range rankClauses(f, clauses) {
+			m.Field = f.field
+			m.Score *= f.boost
+			doc := m.Offsets[0].DocumentID
+			j, ok := best[doc]
+			if !ok {
+				best[doc] = len(results)
+				results = append(results, fieldMatch{Match: m, reader: f, clauses: clauses})
+				continue
+			}
+
+			if m.Score > results[j].Score {
+				results[j] = fieldMatch{Match: m, reader: f, clauses: clauses}
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// rankClauses scores every document covering all the clauses, in document
+// order.
+func rankClauses(r TermReader, clauses []clause) []Match {
+	slog.Info("index: search tokens", slog.String("tokens", fmt.Sprintf("%v", clauses)))
+	if len(clauses) == 0 {
+		return []Match{}
+	}
+
+	for _, c := range clauses {
+		// a clause that matched no terms cannot be covered
+		if len(c) == 0 {
+			return []Match{}
+		}
+	}
+
+	offsets, weight := nextClauseCover(r, clauses, Position{DocumentID: BOF, Offset: BOF})
+	u, v := offsets[0], offsets[1]
+	candidate := []Position{u, v}
+	score := 0.0
+	results := []Match{}
+
+	for u.DocumentID < EOF {
+		if candidate[0].DocumentID < u.DocumentID {
+			results = append(results, Match{Offsets: candidate, Score: score})
+			candidate = []Position{u, v}
+			score = 0
+		}
+
+		score = score + weight/(v.Offset-u.Offset+1)
+
+		offsets, weight = nextClauseCover(r, clauses, u)
+		u, v = offsets[0], offsets[1]
+	}
+
+	if candidate[0].DocumentID < EOF {
+		results = append(results, Match{Offsets: candidate, Score: score})
+	}
+
+	return results
+}
+
+// termPositions returns the positions in document doc of every term of the
+// clauses, in order.
+func termPositions(p PostingsReader, clauses []clause, doc float64) []Position {
+	seen := map[float64]bool{}
+	positions := []Position{}
+
+	for _, c := range clauses {
+		for _, t := range c {
+			for n, err := p.Next(t.term, Position{DocumentID: doc, Offset: -1}); err == nil && n.DocumentID == doc; n, err = p.Next(t.term, n) {
+				if !seen[n.Offset] {
+					seen[n.Offset] = true
+					positions = append(positions, n)
+				}
+			}
+		}
+	}
+
+	sort.Slice(positions, func(i, j int) bool {
+		return positions[i].Offset < positions[j].Offset
+	})
+	return positions
+}
@@ -0,0 +1,183 @@
+package index
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/farouqzaib/fast-search/internal/analyzer"
+)
+
+func TestNextPhraseRespectsRemovedStopwords(t *testing.T) {
+	idx := NewInvertedIndex()
+	idx.Index(1, "Raft is a consensus algorithm for managing a replicated log, an algorithm managing nothing in particular")
+
+	mapped, err := OpenInvertedIndex(idx.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query    string
+		expected []Position
+	}{
+		{"consensus algorithm", []Position{{DocumentID: 1, Offset: 3}, {DocumentID: 1, Offset: 4}}},
+		// "for" is removed from both, leaving the same gap
+		{"algorithm for managing", []Position{{DocumentID: 1, Offset: 4}, {DocumentID: 1, Offset: 6}}},
+		{"algorithm managing", []Position{{DocumentID: 1, Offset: 11}, {DocumentID: 1, Offset: 12}}},
+		{"consensus is algorithm", []Position{{DocumentID: EOF, Offset: EOF}, {DocumentID: EOF, Offset: EOF}}},
+	}
+
+	for _, r := range []TermReader{idx, mapped} {
+		for _, tt := range tests {
+			got := nextPhrase(r, tt.query, Position{DocumentID: BOF, Offset: BOF})
+			for j := range tt.expected {
+				if got[j].DocumentID != tt.expected[j].DocumentID || got[j].Offset != tt.expected[j].Offset {
+					t.Fatalf("%q: expected %v, got %v", tt.query, tt.expected, got)
+				}
+			}
+		}
+	}
+}
+
+func TestIndexPositionsSkipStopwords(t *testing.T) {
+	text := "Raft is a consensus algorithm"
+	idx := NewInvertedIndex()
+	idx.Index(1, text)
+
+	got, err := idx.First("consensus")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Offset != 3 || text[got.Start:got.End] != "consensus" {
+		t.Fatalf("expected consensus at offset 3, got %v", got)
+	}
+}
+
+func TestSubstringQueryWithNgrams(t *testing.T) {
+	if err := analyzer.DefineTokenizer("test_trigrams", analyzer.ComponentDefinition{Type: "ngram", MinGram: 3, MaxGram: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if err := analyzer.Define("test_substring", analyzer.Definition{Tokenizer: "test_trigrams", Filters: []string{"lowercase"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := analyzer.Get("test_substring")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idx := NewInvertedIndexWithAnalyzer(a)
+	idx.Index(1, "Replicated logs")
+
+	mapped, err := OpenInvertedIndex(idx.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, r := range []TermReader{idx, mapped} {
+		if matches := rankQuery(r, Query{Text: "PLICA"}, 10); len(matches) != 1 {
+			t.Fatalf("expected a substring match, got %v", matches)
+		}
+
+		// the grams of the query line up in the text
+		phrase := nextPhrase(r, "ted lo", Position{DocumentID: BOF, Offset: BOF})
+		if phrase[0].Start != 7 || phrase[1].End != 13 {
+			t.Fatalf("expected the phrase to span bytes 7 to 13, got %v", phrase)
+		}
+
+		if matches := rankQuery(r, Query{Text: "plicz"}, 10); len(matches) != 0 {
+			t.Fatalf("expected no match, got %v", matches)
+		}
+	}
+}
+
+func TestFoldedQueryRanksExactSpellingFirst(t *testing.T) {
+	a, err := analyzer.Get("folding")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	readers := func(document string) []TermReader {
+		idx := NewInvertedIndexWithAnalyzer(a)
+		idx.Index(1, document)
+
+		mapped, err := OpenInvertedIndex(idx.Encode())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return []TermReader{idx, mapped}
+	}
+
+	folded, exact := readers("a cafe by the river"), readers("a café by the river")
+	for j := range folded {
+		for _, tt := range []struct {
+			query   string
+			ordered bool
+		}{
+			{query: "CAFÉ", ordered: true},
+			{query: "cafe"},
+		} {
+			f, e := rankQuery(folded[j], Query{Text: tt.query}, 10), rankQuery(exact[j], Query{Text: tt.query}, 10)
+			if len(f) != 1 || len(e) != 1 {
+				t.Fatalf("%q: expected both spellings to match, got %v and %v", tt.query, f, e)
+			}
+			if tt.ordered != (e[0].Score > f[0].Score) {
+				t.Fatalf("%q: unexpected scores %v and %v", tt.query, f[0].Score, e[0].Score)
+			}
+		}
+
+		for _, r := range []TermReader{folded[j], exact[j]} {
+			if phrases := findAllPhrases(r, "café by", Position{DocumentID: BOF, Offset: BOF}); len(phrases) != 1 {
+				t.Fatalf("expected the phrase to match, got %v", phrases)
+			}
+		}
+	}
+}
+
+func TestMultiWordSynonyms(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "synonyms.txt")
+	if err := os.WriteFile(path, []byte("ny, new york\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expand := range []string{analyzer.ExpandAtQuery, analyzer.ExpandAtIndex} {
+		name := "test_synonyms_" + expand
+		if err := analyzer.DefineFilter(name, analyzer.ComponentDefinition{Type: "synonym", SynonymsPath: path, Expand: expand}); err != nil {
+			t.Fatal(err)
+		}
+		if err := analyzer.Define(name, analyzer.Definition{Tokenizer: "standard", Filters: []string{"lowercase", name}}); err != nil {
+			t.Fatal(err)
+		}
+
+		a, err := analyzer.Get(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, document := range []string{"pizza in new york tonight", "pizza in ny tonight"} {
+			idx := NewInvertedIndexWithAnalyzer(a)
+			idx.Index(1, document)
+
+			for _, query := range []string{"ny", "new york", "NY tonight"} {
+				if matches := rankQuery(idx, Query{Text: query}, 10); len(matches) != 1 {
+					t.Fatalf("%s: expected %q to match %q, got %v", expand, query, document, matches)
+				}
+			}
+
+			for _, phrase := range []string{"in ny", "in new york", "ny tonight", "new york tonight"} {
+				if expand == analyzer.ExpandAtIndex && strings.HasSuffix(phrase, "tonight") && !strings.Contains(document, phrase) {
+					// tonight keeps its position after the form the document
+					// uses, so it only follows that form
+					continue
+				}
+
+				if got := nextPhrase(idx, phrase, Position{DocumentID: BOF, Offset: BOF}); got[0].DocumentID != 1 {
+					t.Fatalf("%s: expected phrase %q in %q, got %v", expand, phrase, document, got)
+				}
+			}
+		}
+	}
+}
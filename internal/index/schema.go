@@ -0,0 +1,340 @@
+package index
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/farouqzaib/fast-search/internal/analyzer"
+)
+
+var ErrInvalidDocument = errors.New("index: invalid document")
+
+type FieldType string
+
+const (
+	// TextField is analyzed into terms, like a document's text.
+	TextField FieldType = "text"
+	// KeywordField is indexed as is, a term per value, for tags, IDs and
+	// the like.
+	KeywordField FieldType = "keyword"
+	// NumberField is indexed as a term per value, written the shortest way
+	// that reads back as the same number.
+	NumberField FieldType = "number"
+	// DateField takes RFC 3339 timestamps or dates such as 2026-01-02, and
+	// is indexed as a term per value, the timestamp in UTC.
+	DateField FieldType = "date"
+)
+
+// Field declares how a field of the documents of a collection is indexed.
+// Fields are indexed and stored unless declared otherwise; Embed adds a text
+// or keyword field to the text semantic search embeds.
+type Field struct {
+	Type FieldType `json:"type"`
+	// Analyzer analyzes a text field, which otherwise goes through the
+	// analyzer of the document's language or of the index.
+	Analyzer string `json:"analyzer,omitempty"`
+	Index    *bool  `json:"index,omitempty"`
+	Store    *bool  `json:"store,omitempty"`
+	Embed    bool   `json:"embed,omitempty"`
+}
+
+func (f Field) Indexed() bool {
+	return f.Index == nil || *f.Index
+}
+
+func (f Field) Stored() bool {
+	return f.Store == nil || *f.Store
+}
+
+// Schema declares the fields of the documents of a collection, e.g.
+//
+//	{
+//	  "fields": {
+//	    "title": {"type": "text", "embed": true},
+//	    "body": {"type": "text", "embed": true},
+//	    "tags": {"type": "keyword"},
+//	    "created_at": {"type": "date"},
+//	    "views": {"type": "number", "store": false}
+//	  }
+//	}
+//
+// A nil Schema declares no fields, leaving documents only their text.
+type Schema struct {
+	Fields map[string]Field `json:"fields"`
+}
+
+// ParseSchema reads a JSON Schema and checks its fields.
+func ParseSchema(r io.Reader) (*Schema, error) {
+	var s Schema
+	if err := json.NewDecoder(r).Decode(&s); err != nil {
+		return nil, fmt.Errorf("index: reading schema: %w", err)
+	}
+
+	if err := s.Check(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Check reports the first field with a malformed name, an unknown type or
+// analyzer, or options its type does not take.
+func (s *Schema) Check() error {
+	for _, name := range s.names() {
+		f := s.Fields[name]
+		if !isFieldName(name) || name == DocumentText {
+			return fmt.Errorf("index: schema: invalid field name %q", name)
+		}
+
+		switch f.Type {
+		case TextField:
+			if f.Analyzer != "" {
+				if _, err := analyzer.Get(f.Analyzer); err != nil {
+					return fmt.Errorf("index: schema: field %q: %w", name, err)
+				}
+			}
+		case KeywordField, NumberField, DateField:
+			if f.Analyzer != "" {
+				return fmt.Errorf("index: schema: field %q: only text fields take an analyzer", name)
+			}
+			if f.Embed && f.Type != KeywordField {
+				return fmt.Errorf("index: schema: field %q: only text and keyword fields can be embedded", name)
+			}
+		default:
+			return fmt.Errorf("index: schema: field %q: unknown type %q", name, f.Type)
+		}
+	}
+	return nil
+}
+
+// names returns the names of the fields in order.
+func (s *Schema) names() []string {
+	if s == nil {
+		return nil
+	}
+
+	names := make([]string, 0, len(s.Fields))
+	for name := range s.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Field returns the declaration of the field called name.
+func (s *Schema) Field(name string) (Field, bool) {
+	if s == nil {
+		return Field{}, false
+	}
+
+	f, ok := s.Fields[name]
+	return f, ok
+}
+
+// Validate reports whether fields only has fields the schema declares, each
+// holding a value of its type. Keyword, number and date fields may also hold
+// a list of values.
+func (s *Schema) Validate(fields map[string]interface{}) error {
+	for name, value := range fields {
+		f, ok := s.Field(name)
+		if !ok {
+			return fmt.Errorf("%w: unknown field %q", ErrInvalidDocument, name)
+		}
+
+		if f.Type == TextField {
+			if _, ok := value.(string); !ok {
+				return fmt.Errorf("%w: field %q must be a string", ErrInvalidDocument, name)
+			}
+			continue
+		}
+
+		if _, err := f.terms(value); err != nil {
+			return fmt.Errorf("%w: field %q: %s", ErrInvalidDocument, name, err)
+		}
+	}
+	return nil
+}
+
+// Stored returns the fields the schema stores.
+func (s *Schema) Stored(fields map[string]interface{}) map[string]interface{} {
+	stored := map[string]interface{}{}
+	for name, value := range fields {
+		if f, ok := s.Field(name); ok && f.Stored() {
+			stored[name] = value
+		}
+	}
+	return stored
+}
+
+// EmbeddingText is the text of d semantic search embeds: its text, then the
+// values of its embedded fields in field order.
+func (s *Schema) EmbeddingText(d Document) string {
+	parts := []string{}
+	if d.Text != "" {
+		parts = append(parts, d.Text)
+	}
+
+	for _, name := range s.names() {
+		value, ok := d.Fields[name]
+		if !ok || !s.Fields[name].Embed {
+			continue
+		}
+		parts = append(parts, fieldStrings(value)...)
+	}
+	return strings.Join(parts, "\n")
+}
+
+// FullText is the text of d and of its text fields, in field order, such as
+// for detecting the language it is written in.
+func (s *Schema) FullText(d Document) string {
+	parts := []string{}
+	if d.Text != "" {
+		parts = append(parts, d.Text)
+	}
+
+	for _, name := range s.names() {
+		if text, ok := d.Fields[name].(string); ok && s.Fields[name].Type == TextField {
+			parts = append(parts, text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// textFields returns the names of the indexed text fields, which queries
+// search unless told which fields to search.
+func (s *Schema) textFields() []string {
+	names := []string{}
+	for _, name := range s.names() {
+		if f := s.Fields[name]; f.Type == TextField && f.Indexed() {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// encode returns the schema as JSON, or nothing for a nil schema.
+func (s *Schema) encode() []byte {
+	if s == nil {
+		return nil
+	}
+
+	b, err := json.Marshal(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func decodeSchema(b []byte) (*Schema, error) {
+	if len(b) == 0 {
+		return nil, nil
+	}
+
+	var s Schema
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, fmt.Errorf("index: reading schema: %w", err)
+	}
+
+	if err := s.Check(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Document is what is indexed under a document ID: free text, which predates
+// fields and is searched like a text field of its own, and fields declared by
+// the index's schema. Language is the language of the text and of the text
+// fields without an analyzer of their own.
+type Document struct {
+	Text     string
+	Fields   map[string]interface{}
+	Language string
+}
+
+// terms returns the terms a keyword, number or date field indexes value
+// under, a term per value.
+func (f Field) terms(value interface{}) ([]string, error) {
+	values, ok := value.([]interface{})
+	if !ok {
+		values = []interface{}{value}
+	}
+
+	terms := make([]string, 0, len(values))
+	for _, v := range values {
+		term, err := f.term(v)
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, term)
+	}
+	return terms, nil
+}
+
+func (f Field) term(value interface{}) (string, error) {
+	switch f.Type {
+	case KeywordField:
+		if s, ok := value.(string); ok {
+			return s, nil
+		}
+		return "", errors.New("must be a string or a list of strings")
+	case NumberField:
+		switch n := value.(type) {
+		case float64:
+			return strconv.FormatFloat(n, 'f', -1, 64), nil
+		case int:
+			return strconv.Itoa(n), nil
+		case json.Number:
+			v, err := n.Float64()
+			if err != nil {
+				return "", err
+			}
+			return strconv.FormatFloat(v, 'f', -1, 64), nil
+		}
+		return "", errors.New("must be a number or a list of numbers")
+	case DateField:
+		s, ok := value.(string)
+		if !ok {
+			return "", errors.New("must be a date or a list of dates")
+		}
+		t, err := parseDate(s)
+		if err != nil {
+			return "", err
+		}
+		return t.UTC().Format(time.RFC3339Nano), nil
+	}
+	return "", fmt.Errorf("%s fields are analyzed", f.Type)
+}
+
+// parseDate parses an RFC 3339 timestamp or a date, taken as midnight UTC.
+func parseDate(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not an RFC 3339 timestamp or a date", s)
+	}
+	return t, nil
+}
+
+// fieldStrings returns the text of a field value, or of each of its values.
+func fieldStrings(value interface{}) []string {
+	values, ok := value.([]interface{})
+	if !ok {
+		values = []interface{}{value}
+	}
+
+	strs := []string{}
+	for _, v := range values {
+		if s, ok := v.(string); ok {
+			strs = append(strs, s)
+		}
+	}
+	return strs
+}
@@ -0,0 +1,236 @@
+package index
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/farouqzaib/fast-search/internal/analyzer"
+)
+
+const testSchema = `{
+  "fields": {
+    "title": {"type": "text", "analyzer": "standard", "embed": true},
+    "body": {"type": "text"},
+    "tags": {"type": "keyword"},
+    "created_at": {"type": "date"},
+    "views": {"type": "number", "store": false}
+  }
+}`
+
+func TestParseSchema(t *testing.T) {
+	s, err := ParseSchema(strings.NewReader(testSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := s.textFields(); len(got) != 2 || got[0] != "body" || got[1] != "title" {
+		t.Fatalf("expected the text fields body and title, got %v", got)
+	}
+
+	for _, bad := range []string{
+		`{"fields": {"title": {"type": "blob"}}}`,
+		`{"fields": {"title": {"type": "text", "analyzer": "missing"}}}`,
+		`{"fields": {"views": {"type": "number", "analyzer": "standard"}}}`,
+		`{"fields": {"views": {"type": "number", "embed": true}}}`,
+		`{"fields": {"a:b": {"type": "keyword"}}}`,
+	} {
+		if _, err := ParseSchema(strings.NewReader(bad)); err == nil {
+			t.Fatalf("expected %s to be rejected", bad)
+		}
+	}
+}
+
+func TestSchemaValidate(t *testing.T) {
+	s, err := ParseSchema(strings.NewReader(testSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	valid := map[string]interface{}{
+		"title":      "Raft",
+		"tags":       []interface{}{"consensus", "logs"},
+		"created_at": "2026-01-02",
+		"views":      42.0,
+	}
+	if err := s.Validate(valid); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, fields := range []map[string]interface{}{
+		{"author": "Ongaro"},
+		{"title": 3.0},
+		{"tags": []interface{}{"consensus", 1.0}},
+		{"created_at": "yesterday"},
+		{"views": "many"},
+	} {
+		if err := s.Validate(fields); !errors.Is(err, ErrInvalidDocument) {
+			t.Fatalf("expected %v to be invalid, got %v", fields, err)
+		}
+	}
+
+	if stored := s.Stored(valid); len(stored) != 3 || stored["views"] != nil {
+		t.Fatalf("expected every field but views stored, got %v", stored)
+	}
+
+	d := Document{Text: "notes", Fields: map[string]interface{}{"title": "Raft", "body": "leader election"}}
+	if got := s.EmbeddingText(d); got != "notes\nRaft" {
+		t.Fatalf("expected the text and title embedded, got %q", got)
+	}
+}
+
+func TestIndexDocumentFields(t *testing.T) {
+	s, err := ParseSchema(strings.NewReader(testSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idx := NewInvertedIndexWithSchema(analyzer.Default(), s)
+	err = idx.IndexDocument(1, Document{Fields: map[string]interface{}{
+		"title":      "The Raft Paper",
+		"body":       "Electing a leader",
+		"tags":       []interface{}{"Consensus"},
+		"created_at": "2026-01-02T03:04:05+01:00",
+		"views":      42.0,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mapped, err := OpenInvertedIndex(idx.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := mapped.Schema().Field("tags"); !ok {
+		t.Fatal("expected the segment to keep its schema")
+	}
+
+	for _, r := range []TermReader{idx, mapped} {
+		// the title keeps its own analyzer, which does not remove "the"
+		if df := r.DocumentFrequency(fieldTerm("title", "the")); df != 1 {
+			t.Fatalf("expected the title indexed with its analyzer, got df %d", df)
+		}
+		if df := r.DocumentFrequency(fieldTerm("body", "elect")); df != 1 {
+			t.Fatalf("expected the body stemmed, got df %d", df)
+		}
+		if df := r.DocumentFrequency(fieldTerm("tags", "Consensus")); df != 1 {
+			t.Fatalf("expected the tag indexed as is, got df %d", df)
+		}
+		if df := r.DocumentFrequency(fieldTerm("created_at", "2026-01-02T02:04:05Z")); df != 1 {
+			t.Fatalf("expected the date indexed in UTC, got df %d", df)
+		}
+		if df := r.DocumentFrequency(fieldTerm("views", "42")); df != 1 {
+			t.Fatalf("expected the number indexed, got df %d", df)
+		}
+
+		matches := rankQuery(r, Query{Text: "leader"}, 10)
+		if len(matches) != 1 || matches[0].Field != "body" || matches[0].Highlights[0].Start != 11 {
+			t.Fatalf("expected a match in the body, got %v", matches)
+		}
+
+		// keyword fields are not searched as text, and the text field is
+		// not searched through the terms of other fields
+		if matches := rankQuery(r, Query{Text: "Consensus"}, 10); len(matches) != 0 {
+			t.Fatalf("expected no match, got %v", matches)
+		}
+		if matches := rankQuery(r, Query{Text: "t*"}, 10); len(matches) != 1 || matches[0].Field != "title" {
+			t.Fatalf("expected the prefix to match the title only, got %v", matches)
+		}
+	}
+}
+
+func TestFieldScoresAdd(t *testing.T) {
+	s, err := ParseSchema(strings.NewReader(`{"fields": {"title": {"type": "text"}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	both := NewInvertedIndexWithSchema(analyzer.Default(), s)
+	both.IndexDocument(1, Document{Text: "raft consensus", Fields: map[string]interface{}{"title": "raft"}})
+
+	text := NewInvertedIndexWithSchema(analyzer.Default(), s)
+	text.IndexDocument(1, Document{Text: "raft consensus"})
+
+	inBoth, inText := rankQuery(both, Query{Text: "raft"}, 10), rankQuery(text, Query{Text: "raft"}, 10)
+	if len(inBoth) != 1 || len(inText) != 1 || inBoth[0].Score <= inText[0].Score {
+		t.Fatalf("expected a match in two fields to score higher, got %v and %v", inBoth, inText)
+	}
+}
+
+func TestFieldScopedQueries(t *testing.T) {
+	s, err := ParseSchema(strings.NewReader(testSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idx := NewInvertedIndexWithSchema(analyzer.Default(), s)
+	err = idx.IndexDocument(1, Document{Text: "raft", Fields: map[string]interface{}{
+		"title":      "Raft consensus",
+		"body":       "Electing a leader with raft",
+		"tags":       []interface{}{"consensus"},
+		"created_at": "2026-01-02",
+		"views":      42.0,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mapped, err := OpenInvertedIndex(idx.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, r := range []TermReader{idx, mapped} {
+		for _, text := range []string{"title:consensus", "tags:consensus", "views:42.0", "created_at:2026-01-02", "text:raft", "title:raft body:paxos"} {
+			if matches := rankQuery(r, Query{Text: text}, 10); len(matches) != 1 {
+				t.Fatalf("%q: expected a match, got %v", text, matches)
+			}
+		}
+
+		for _, text := range []string{"body:consensus", "tags:Consensus", "views:43", "missing:raft"} {
+			if matches := rankQuery(r, Query{Text: text}, 10); len(matches) != 0 {
+				t.Fatalf("%q: expected no match, got %v", text, matches)
+			}
+		}
+
+		once := rankQuery(r, Query{Text: "title:raft"}, 10)
+		boosted := rankQuery(r, Query{Text: "title:raft^3"}, 10)
+		if len(once) != 1 || len(boosted) != 1 || boosted[0].Score != 3*once[0].Score || boosted[0].Field != "title" {
+			t.Fatalf("expected the boost to triple the score, got %v and %v", once, boosted)
+		}
+
+		most := rankQuery(r, Query{Text: "raft"}, 10)
+		best := rankQuery(r, Query{Text: "raft", Mode: BestFields}, 10)
+		title := rankQuery(r, Query{Text: "raft", Fields: []string{"title"}}, 10)
+		if len(most) != 1 || len(best) != 1 || most[0].Score <= best[0].Score {
+			t.Fatalf("expected most_fields to add up three fields, got %v and %v", most, best)
+		}
+		if len(title) != 1 || title[0].Field != "title" || title[0].Score > best[0].Score {
+			t.Fatalf("expected a title match, got %v", title)
+		}
+
+		body := rankQuery(r, Query{Text: "raft", Fields: []string{"title", "body^10"}, Mode: BestFields}, 10)
+		if len(body) != 1 || body[0].Field != "body" {
+			t.Fatalf("expected the boosted body to score best, got %v", body)
+		}
+	}
+
+	logs := NewInvertedIndexWithSchema(analyzer.Default(), s)
+	logs.IndexDocument(2, Document{Text: "connection error: timeout fetching http://example.com"})
+	for _, text := range []string{"error:timeout", "http://example.com"} {
+		if matches := rankQuery(logs, Query{Text: text}, 10); len(matches) != 1 {
+			t.Fatalf("%q: expected a match as plain text, got %v", text, matches)
+		}
+	}
+
+	// words are only scoped to declared fields, but fields to search must be
+	if err := (Query{Text: "missing:raft http://example.com"}).ValidateFields(s); err != nil {
+		t.Fatalf("expected an undeclared prefix to be plain text, got %v", err)
+	}
+	if err := (Query{Text: "raft", Fields: []string{"missing"}}).ValidateFields(s); !errors.Is(err, ErrInvalidQuery) {
+		t.Fatalf("expected an unknown field to be invalid, got %v", err)
+	}
+	if err := (Query{Text: "title:raft text:raft", Fields: []string{"body^2"}}).ValidateFields(s); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -4,7 +4,7 @@ import (
 	"errors"
 	"math"
 	"math/rand"
-	"time"
+	"sync"
 )
 
 const (
@@ -18,6 +18,10 @@ var BOF = math.Inf(-1)
 type Position struct {
 	DocumentID float64
 	Offset     float64
+	// Start and End are the byte offsets in the document of the text the
+	// term at Offset was analyzed from.
+	Start int
+	End   int
 }
 
 func (d *Position) GetDocumentID() int {
@@ -36,7 +40,13 @@ type Node struct {
 	Tower [MaxHeight]*Node
 }
 
+// SkipList holds the postings of a term in order. Inserts and deletes take
+// it exclusively and lookups share it, so readers may search a list that is
+// being written to. Walking Head directly is left to callers that keep
+// writers out themselves. Lookups in a nil list, the postings of a term never
+// indexed, find nothing.
 type SkipList struct {
+	mu     sync.RWMutex
 	Head   *Node
 	Height int
 }
@@ -49,6 +59,17 @@ func NewSkipList() *SkipList {
 }
 
 func (s *SkipList) Search(key Position) (*Node, [MaxHeight]*Node) {
+	if s == nil {
+		return nil, [MaxHeight]*Node{}
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.search(key)
+}
+
+func (s *SkipList) search(key Position) (*Node, [MaxHeight]*Node) {
 	var next *Node
 	var journey [MaxHeight]*Node
 
@@ -106,7 +127,14 @@ func (s *SkipList) Search(key Position) (*Node, [MaxHeight]*Node) {
 }
 
 func (s *SkipList) Find(key Position) (Position, error) {
-	found, _ := s.Search(key)
+	if s == nil {
+		return Position{DocumentID: EOF, Offset: EOF}, errors.New("key not found")
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	found, _ := s.search(key)
 
 	if found == nil {
 		return Position{DocumentID: EOF, Offset: EOF}, errors.New("key not found")
@@ -115,7 +143,14 @@ func (s *SkipList) Find(key Position) (Position, error) {
 }
 
 func (s *SkipList) FindLessThan(key Position) (Position, error) {
-	_, journey := s.Search(key)
+	if s == nil {
+		return Position{DocumentID: BOF, Offset: BOF}, errors.New("no element found")
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, journey := s.search(key)
 
 	if journey[0] == nil {
 		return Position{DocumentID: BOF, Offset: BOF}, errors.New("key not found")
@@ -129,7 +164,14 @@ func (s *SkipList) FindLessThan(key Position) (Position, error) {
 }
 
 func (s *SkipList) FindGreaterThan(key Position) (Position, error) {
-	found, journey := s.Search(key)
+	if s == nil {
+		return Position{DocumentID: EOF, Offset: EOF}, errors.New("no element found")
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	found, journey := s.search(key)
 
 	//if the key exists then move the found key forward
 	if found != nil {
@@ -150,15 +192,18 @@ func (s *SkipList) FindGreaterThan(key Position) (Position, error) {
 }
 
 func (s *SkipList) Insert(key Position) {
-	found, journey := s.Search(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	found, journey := s.search(key)
 
 	if found != nil {
-		found.Key = Position{DocumentID: key.DocumentID, Offset: key.Offset}
+		found.Key = key
 		return
 	}
 
 	height := s.randomHeight()
-	node := &Node{Key: Position{DocumentID: key.DocumentID, Offset: key.Offset}}
+	node := &Node{Key: key}
 
 	for level := 0; level < height; level++ {
 		prev := journey[level]
@@ -178,7 +223,10 @@ func (s *SkipList) Insert(key Position) {
 }
 
 func (s *SkipList) Delete(key Position) bool {
-	found, journey := s.Search(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	found, journey := s.search(key)
 
 	if found != nil {
 		found.Key = Position{DocumentID: -1, Offset: -1}
@@ -195,11 +243,14 @@ func (s *SkipList) Delete(key Position) bool {
 	}
 
 	found = nil
-	s.Shrink()
+	s.shrink()
 	return true
 }
 
 func (s *SkipList) Last() Position {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
 	var next *Node
 	prev := s.Head
 
@@ -211,6 +262,13 @@ func (s *SkipList) Last() Position {
 }
 
 func (s *SkipList) Shrink() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.shrink()
+}
+
+func (s *SkipList) shrink() {
 	for level := s.Height - 1; level >= 0; level-- {
 		if s.Head.Tower[level] == nil {
 			s.Height--
@@ -220,26 +278,38 @@ func (s *SkipList) Shrink() {
 
 func (s *SkipList) randomHeight() int {
 	l := 1
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
-	for r.Float64() < 0.5 && l < MaxHeight {
+	// the package source is safe for concurrent inserts into different lists
+	for rand.Float64() < 0.5 && l < MaxHeight {
 		l++
 	}
 	return l
 }
 
+// Iterator walks a list, sharing it for each step so inserts may land
+// between steps.
 type Iterator struct {
+	list    *SkipList
 	current *Node
 }
 
 func (s *SkipList) Iterator() *Iterator {
-	return &Iterator{s.Head.Tower[0]}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return &Iterator{list: s, current: s.Head.Tower[0]}
 }
 
 func (i *Iterator) HasNext() bool {
+	i.list.mu.RLock()
+	defer i.list.mu.RUnlock()
+
 	return i.current.Tower[0] != nil
 }
 
 func (i *Iterator) Next() Position {
+	i.list.mu.RLock()
+	defer i.list.mu.RUnlock()
+
 	i.current = i.current.Tower[0]
 
 	if i.current == nil {
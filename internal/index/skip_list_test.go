@@ -1,6 +1,7 @@
 package index
 
 import (
+	"sync"
 	"testing"
 )
 
@@ -144,3 +145,41 @@ func TestSkipListFindLessThanOneRecord(t *testing.T) {
 	}
 
 }
+
+// TestSkipListConcurrent inserts and looks up from several goroutines. Run
+// with -race.
+func TestSkipListConcurrent(t *testing.T) {
+	skipList := NewSkipList()
+
+	const writers, inserts = 4, 200
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(2)
+		go func(w int) {
+			defer wg.Done()
+			for n := 0; n < inserts; n++ {
+				skipList.Insert(Position{DocumentID: float64(n), Offset: float64(w)})
+			}
+		}(w)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < inserts; n++ {
+				skipList.FindGreaterThan(Position{DocumentID: float64(n), Offset: 0})
+				skipList.Last()
+			}
+		}()
+	}
+	wg.Wait()
+
+	count := 0
+	for it := skipList.Iterator(); ; count++ {
+		if !it.HasNext() {
+			break
+		}
+		it.Next()
+	}
+	// the iterator starts on the first position
+	if count+1 != writers*inserts {
+		t.Fatalf("expected %d positions, got %d", writers*inserts, count+1)
+	}
+}
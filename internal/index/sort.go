@@ -0,0 +1,277 @@
+package index
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// ScoreField sorts matches by score, which Sort takes like a field.
+const ScoreField = "_score"
+
+const (
+	Ascending  = "asc"
+	Descending = "desc"
+)
+
+// SortField orders matches by a keyword, number or date field, in
+// ascending order unless told otherwise, or by score, in descending order
+// unless told otherwise. Documents with several values of a field sort by
+// the smallest ascending and the largest descending; those with none sort
+// last.
+type SortField struct {
+	Field string `json:"field"`
+	Order string `json:"order,omitempty"`
+}
+
+func (f SortField) descending() bool {
+	if f.Order == "" {
+		return f.Field == ScoreField
+	}
+	return f.Order == Descending
+}
+
+// sortDateLayout writes dates at a fixed width, so they sort as strings in
+// time order.
+const sortDateLayout = "2006-01-02T15:04:05.000000000Z"
+
+// sortFields returns the order of q's matches, by score if it names none.
+func (q Query) sortFields() []SortField {
+	if len(q.Sort) == 0 {
+		return []SortField{{Field: ScoreField}}
+	}
+	return q.Sort
+}
+
+// Sorted reports whether q's matches are ordered by Sort and paged by
+// SearchAfter rather than cut to the best scores of each memtable and
+// segment.
+func (q Query) Sorted() bool {
+	return len(q.Sort) > 0 || q.SearchAfter != ""
+}
+
+// cursor is where a page of sorted matches ends: the sort values of its last
+// match, and its document ID, which breaks ties.
+type cursor struct {
+	Values     []interface{} `json:"v"`
+	DocumentID float64       `json:"d"`
+}
+
+// Cursor returns the opaque token that pages on from m, the last match of a
+// sorted page. Documents indexed later that sort after m are in later pages,
+// while the matches up to m are never repeated.
+func Cursor(m Match) string {
+	b, err := json.Marshal(cursor{Values: m.Sort, DocumentID: m.Offsets[0].DocumentID})
+	if err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(token string) (cursor, error) {
+	var c cursor
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err == nil {
+		err = json.Unmarshal(b, &c)
+	}
+	if err != nil {
+		return cursor{}, fmt.Errorf("%w: malformed search_after", ErrInvalidQuery)
+	}
+
+	for _, v := range c.Values {
+		switch v.(type) {
+		case nil, float64, string:
+		default:
+			return cursor{}, fmt.Errorf("%w: malformed search_after", ErrInvalidQuery)
+		}
+	}
+	return c, nil
+}
+
+// checkSort reports whether q's sort orders are known and its cursor holds a
+// value for each of its sort fields.
+func (q Query) checkSort() error {
+	for _, f := range q.Sort {
+		if f.Order != "" && f.Order != Ascending && f.Order != Descending {
+			return fmt.Errorf("%w: sort: order must be %q or %q, got %q", ErrInvalidQuery, Ascending, Descending, f.Order)
+		}
+	}
+
+	if q.SearchAfter == "" {
+		return nil
+	}
+
+	c, err := decodeCursor(q.SearchAfter)
+	if err != nil {
+		return err
+	}
+	if len(c.Values) != len(q.sortFields()) {
+		return fmt.Errorf("%w: search_after does not match the sort", ErrInvalidQuery)
+	}
+	return nil
+}
+
+// checkSortFields reports whether q sorts by score or by indexed keyword,
+// number and date fields of s.
+func (q Query) checkSortFields(s *Schema) error {
+	for _, f := range q.Sort {
+		if f.Field == ScoreField {
+			continue
+		}
+
+		decl, ok := s.Field(f.Field)
+		if !ok {
+			return fmt.Errorf("%w: sort: unknown field %q", ErrInvalidQuery, f.Field)
+		}
+		if !decl.Indexed() || decl.Type == TextField {
+			return fmt.Errorf("%w: sort: field %q is not an indexed keyword, number or date field", ErrInvalidQuery, f.Field)
+		}
+	}
+	return nil
+}
+
+// pageMatches gives each match its sort values in r, drops those up to q's
+// cursor, and returns the first k of the rest in order.
+func pageMatches(r TermReader, q Query, matches []Match, k int) []Match {
+	fields := q.sortFields()
+	values := make([]DocValues, len(fields))
+	types := make([]FieldType, len(fields))
+	for i, f := range fields {
+		if f.Field != ScoreField {
+			values[i] = r.DocValues(f.Field)
+			decl, _ := r.Schema().Field(f.Field)
+			types[i] = decl.Type
+		}
+	}
+
+	var after *cursor
+	if q.SearchAfter != "" {
+		if c, err := decodeCursor(q.SearchAfter); err == nil && len(c.Values) == len(fields) {
+			after = &c
+		}
+	}
+
+	page := []Match{}
+	for _, m := range matches {
+		doc := m.Offsets[0].DocumentID
+		m.Sort = make([]interface{}, len(fields))
+		for i, f := range fields {
+			if f.Field == ScoreField {
+				m.Sort[i] = m.Score
+			} else if v, ok := sortValue(values[i], types[i], f, doc); ok {
+				m.Sort[i] = v
+			}
+		}
+
+		if after != nil && compareSort(fields, m.Sort, doc, after.Values, after.DocumentID) <= 0 {
+			continue
+		}
+		page = append(page, m)
+	}
+
+	SortMatches(q, page)
+	return page[:int(math.Min(float64(k), float64(len(page))))]
+}
+
+// SortMatches orders matches given their sort values by pageMatches, ties
+// going to the lower document ID.
+func SortMatches(q Query, matches []Match) {
+	fields := q.sortFields()
+	sort.SliceStable(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		return compareSort(fields, a.Sort, a.Offsets[0].DocumentID, b.Sort, b.Offsets[0].DocumentID) < 0
+	})
+}
+
+// compareSort compares two matches by their sort values, then by document
+// ID, returning -1 if a comes first.
+func compareSort(fields []SortField, a []interface{}, aDoc float64, b []interface{}, bDoc float64) int {
+	for i, f := range fields {
+		var x, y interface{}
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+
+		// missing values sort last whichever the order
+		switch {
+		case x == nil && y == nil:
+			continue
+		case x == nil:
+			return 1
+		case y == nil:
+			return -1
+		}
+
+		cmp := compareSortValues(x, y)
+		if f.descending() {
+			cmp = -cmp
+		}
+		if cmp != 0 {
+			return cmp
+		}
+	}
+	return compareFloats(aDoc, bDoc)
+}
+
+func compareSortValues(x, y interface{}) int {
+	xn, xNumber := x.(float64)
+	yn, yNumber := y.(float64)
+	switch {
+	case xNumber && yNumber:
+		return compareFloats(xn, yn)
+	case xNumber:
+		return -1
+	case yNumber:
+		return 1
+	}
+
+	xs, _ := x.(string)
+	ys, _ := y.(string)
+	switch {
+	case xs < ys:
+		return -1
+	case xs > ys:
+		return 1
+	}
+	return 0
+}
+
+// sortValue returns the value of the field f sorts doc by, read from its doc
+// values: numbers as numbers, dates as fixed-width strings and keywords as
+// they are.
+func sortValue(values DocValues, t FieldType, f SortField, doc float64) (interface{}, bool) {
+	terms, _ := values.Values(doc)
+
+	var value interface{}
+	for _, term := range terms {
+		var v interface{} = term
+		switch t {
+		case NumberField:
+			n, err := strconv.ParseFloat(term, 64)
+			if err != nil {
+				continue
+			}
+			v = n
+		case DateField:
+			d, err := parseDate(term)
+			if err != nil {
+				continue
+			}
+			v = d.UTC().Format(sortDateLayout)
+		}
+
+		// several values: keep the one that sorts first
+		if value == nil {
+			value = v
+		} else if cmp := compareSortValues(v, value); cmp != 0 && (cmp < 0) != f.descending() {
+			value = v
+		}
+	}
+	return value, value != nil
+}
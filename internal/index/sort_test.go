@@ -0,0 +1,136 @@
+package index
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/farouqzaib/fast-search/internal/analyzer"
+)
+
+func TestSort(t *testing.T) {
+	s, err := ParseSchema(strings.NewReader(`{"fields": {
+		"title": {"type": "text"},
+		"views": {"type": "number"},
+		"created_at": {"type": "date"}
+	}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	memtable := NewInvertedIndexWithSchema(analyzer.Default(), s)
+	memtable.IndexDocument(1, Document{Text: "raftone", Fields: map[string]interface{}{"views": 10.0, "created_at": "2026-01-01"}})
+	memtable.IndexDocument(2, Document{Text: "rafttwo", Fields: map[string]interface{}{"views": 25.0, "created_at": "2026-01-20T10:00:00Z"}})
+
+	flushed := NewInvertedIndexWithSchema(analyzer.Default(), s)
+	flushed.IndexDocument(3, Document{Text: "raftthree", Fields: map[string]interface{}{"views": 10.0, "created_at": "2025-12-31"}})
+	segment, err := OpenInvertedIndex(flushed.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// search pages each reader, then merges their pages as IndexStorage does
+	search := func(q Query, k int) []Match {
+		if err := q.Validate(); err != nil {
+			t.Fatal(err)
+		}
+		if err := q.ValidateFields(s); err != nil {
+			t.Fatal(err)
+		}
+
+		matches := []Match{}
+		for _, r := range []TermReader{memtable, segment} {
+			matches = append(matches, pageMatches(r, q, rankQuery(r, q, k), k)...)
+		}
+		SortMatches(q, matches)
+		return matches[:int(math.Min(float64(k), float64(len(matches))))]
+	}
+
+	documents := func(matches []Match) []float64 {
+		docs := []float64{}
+		for _, m := range matches {
+			docs = append(docs, m.Offsets[0].DocumentID)
+		}
+		return docs
+	}
+
+	// ties go to the lower document ID
+	q := Query{Text: "raft*", Sort: []SortField{{Field: "views", Order: Descending}}}
+	page := search(q, 2)
+	if got := documents(page); !reflect.DeepEqual(got, []float64{2, 1}) {
+		t.Fatalf("expected documents [2 1], got %v", got)
+	}
+	if !reflect.DeepEqual(page[0].Sort, []interface{}{25.0}) {
+		t.Fatalf("expected sort values [25], got %v", page[0].Sort)
+	}
+
+	// documents indexed while paging show up only if they sort after the
+	// cursor, and nothing before it is repeated; one without the field sorts
+	// last
+	memtable.IndexDocument(4, Document{Text: "raftfour", Fields: map[string]interface{}{"views": 30.0}})
+	memtable.IndexDocument(5, Document{Text: "raftfive", Fields: map[string]interface{}{"views": 5.0}})
+	memtable.IndexDocument(6, Document{Text: "raftsix"})
+
+	q.SearchAfter = Cursor(page[len(page)-1])
+	page = search(q, 2)
+	if got := documents(page); !reflect.DeepEqual(got, []float64{3, 5}) {
+		t.Fatalf("expected documents [3 5], got %v", got)
+	}
+
+	q.SearchAfter = Cursor(page[len(page)-1])
+	if got := documents(search(q, 2)); !reflect.DeepEqual(got, []float64{6}) {
+		t.Fatalf("expected documents [6], got %v", got)
+	}
+
+	dates := search(Query{Text: "raft*", Sort: []SortField{{Field: "created_at"}}}, 3)
+	if got := documents(dates); !reflect.DeepEqual(got, []float64{3, 1, 2}) {
+		t.Fatalf("expected documents [3 1 2], got %v", got)
+	}
+
+	// by score, with ties broken by a field
+	scored := search(Query{Text: "raft*", Sort: []SortField{{Field: ScoreField}, {Field: "views"}}}, 6)
+	for i := 1; i < len(scored); i++ {
+		if scored[i].Score > scored[i-1].Score {
+			t.Fatalf("expected scores in descending order, got %v", scored)
+		}
+	}
+
+	// sort values are read for the matched documents from their doc values,
+	// without walking the terms of the field; a document with several values
+	// sorts by the smallest ascending and the largest descending
+	multi := NewInvertedIndexWithSchema(analyzer.Default(), s)
+	multi.IndexDocument(1, Document{Text: "raft", Fields: map[string]interface{}{"views": []interface{}{3.0, 40.0}}})
+	multi.IndexDocument(2, Document{Text: "raft", Fields: map[string]interface{}{"views": 20.0}})
+	walking := &walkingReader{TermReader: multi}
+	for order, expected := range map[string]float64{Ascending: 3, Descending: 40} {
+		q := Query{Text: "raft", Sort: []SortField{{Field: "views", Order: order}}}
+		page := pageMatches(walking, q, rankQuery(multi, q, 10), 10)
+		if got := documents(page); !reflect.DeepEqual(got, []float64{1, 2}) {
+			t.Fatalf("%s: expected documents [1 2], got %v", order, got)
+		}
+		if !reflect.DeepEqual(page[0].Sort, []interface{}{expected}) {
+			t.Fatalf("%s: expected sort values [%v], got %v", order, expected, page[0].Sort)
+		}
+	}
+	if walking.walked > 0 {
+		t.Fatalf("expected sorting not to walk terms, walked %d times", walking.walked)
+	}
+
+	for _, bad := range []Query{
+		{Sort: []SortField{{Field: "views", Order: "up"}}},
+		{Sort: []SortField{{Field: "missing"}}},
+		{Sort: []SortField{{Field: "title"}}},
+		{Sort: []SortField{{Field: "views"}}, SearchAfter: "not a cursor"},
+		{Sort: []SortField{{Field: "views"}, {Field: ScoreField}}, SearchAfter: Cursor(page[0])},
+	} {
+		err := bad.Validate()
+		if err == nil {
+			err = bad.ValidateFields(s)
+		}
+		if !errors.Is(err, ErrInvalidQuery) {
+			t.Fatalf("expected %+v to be invalid, got %v", bad, err)
+		}
+	}
+}
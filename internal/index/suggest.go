@@ -0,0 +1,222 @@
+package index
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/farouqzaib/fast-search/internal/analyzer"
+)
+
+const (
+	maxSuggestionEdits      = 2
+	maxCorrectionsPerWord   = 3
+	maxCorrectedWords       = 3
+	maxSuggestionCandidates = 32
+)
+
+// Suggester proposes "did you mean" corrections for queries, drawing on the
+// vocabulary and document frequencies of every memtable and segment.
+// Corrections are words as documents spell them, taken from the completion
+// vocabulary, rather than the stems the term dictionary holds.
+type Suggester struct {
+	analyzer   *analyzer.Analyzer
+	readers    []TermReader
+	vocabulary []CompletionReader
+}
+
+// NewSuggester returns a suggester over readers whose documents were analyzed
+// with a, and the completion vocabularies of their indexes.
+func NewSuggester(a *analyzer.Analyzer, readers []TermReader, vocabulary []CompletionReader) *Suggester {
+	return &Suggester{analyzer: a, readers: readers, vocabulary: vocabulary}
+}
+
+func (s *Suggester) documentFrequency(term string) int {
+	df := 0
+	for _, r := range s.readers {
+		df += r.DocumentFrequency(term)
+	}
+	return df
+}
+
+// correction is a word to write in place of another, and the term it is
+// indexed under.
+type correction struct {
+	text   string
+	term   string
+	weight float64
+	df     int
+}
+
+// completionTerms reads the completion vocabulary of an index as terms.
+type completionTerms struct {
+	CompletionReader
+}
+
+func (c completionTerms) PrefixTerms(prefix string) TermIterator {
+	return c.CompletionTerms(prefix)
+}
+
+// corrections returns the words of the vocabulary closest to word, one per
+// term they are indexed under, preferring fewer edits and then more frequent
+// terms.
+func (s *Suggester) corrections(word string) []correction {
+	best := map[string]correction{}
+	for _, r := range s.vocabulary {
+		for _, t := range fuzzyTerms(completionTerms{r}, word, maxSuggestionEdits, 0, maxSuggestionCandidates) {
+			if t.term == word {
+				continue
+			}
+
+			// stopwords have no term, and phrases are not corrections
+			terms := s.analyzer.Terms(t.term)
+			if len(terms) != 1 {
+				continue
+			}
+
+			c := correction{text: t.term, term: terms[0], weight: t.weight}
+			if b, ok := best[c.term]; !ok || c.weight > b.weight || (c.weight == b.weight && c.text < b.text) {
+				best[c.term] = c
+			}
+		}
+	}
+
+	corrections := []correction{}
+	for _, c := range best {
+		c.df = s.documentFrequency(c.term)
+		if c.df > 0 {
+			corrections = append(corrections, c)
+		}
+	}
+
+	sort.Slice(corrections, func(i, j int) bool {
+		if corrections[i].weight != corrections[j].weight {
+			return corrections[i].weight > corrections[j].weight
+		}
+		if corrections[i].df != corrections[j].df {
+			return corrections[i].df > corrections[j].df
+		}
+		return corrections[i].text < corrections[j].text
+	})
+
+	if len(corrections) > maxCorrectionsPerWord {
+		corrections = corrections[:maxCorrectionsPerWord]
+	}
+	return corrections
+}
+
+// cooccur reports whether some document of some reader contains every term.
+func (s *Suggester) cooccur(terms []string) bool {
+	for _, r := range s.readers {
+		cover := nextCover(r, terms, Position{DocumentID: BOF, Offset: BOF})
+		if cover[0].DocumentID != EOF {
+			return true
+		}
+	}
+	return false
+}
+
+type suggestionWord struct {
+	original    string
+	tokens      []analyzer.Token
+	corrections [][]correction
+	misspelled  bool
+}
+
+// Suggest returns up to n rewrites of text in which words matching no term
+// are replaced by close, frequent terms. Only rewrites whose terms all occur
+// together in at least one document are returned, best first.
+func (s *Suggester) Suggest(text string, n int) []string {
+	words := []suggestionWord{}
+	misspelled := 0
+
+	for _, word := range strings.Fields(text) {
+		w := suggestionWord{original: word}
+
+		// multi-term clauses are left as the user wrote them
+		if !strings.ContainsAny(word, "*?~/") {
+			w.tokens = s.analyzer.Analyze(word)
+		}
+
+		for _, token := range w.tokens {
+			text := token.Term
+			if token.End > token.Start && token.End <= len(word) {
+				text = strings.ToLower(word[token.Start:token.End])
+			}
+
+			if s.documentFrequency(token.Term) > 0 || misspelled == maxCorrectedWords {
+				w.corrections = append(w.corrections, []correction{{text: text, term: token.Term, weight: 1}})
+				continue
+			}
+
+			corrections := s.corrections(text)
+			if len(corrections) == 0 {
+				// nothing is close enough, so no rewrite can match
+				return []string{}
+			}
+
+			w.corrections = append(w.corrections, corrections)
+			w.misspelled = true
+			misspelled++
+		}
+
+		words = append(words, w)
+	}
+
+	if misspelled == 0 {
+		return []string{}
+	}
+
+	type candidate struct {
+		text  string
+		terms []string
+		score float64
+	}
+	candidates := []candidate{{}}
+
+	for _, w := range words {
+		// words that need no correction are kept as the user wrote them
+		if !w.misspelled {
+			for i := range candidates {
+				candidates[i].text += " " + w.original
+				terms := append([]string{}, candidates[i].terms...)
+				for _, token := range w.tokens {
+					terms = append(terms, token.Term)
+				}
+				candidates[i].terms = terms
+			}
+			continue
+		}
+
+		for _, options := range w.corrections {
+			next := []candidate{}
+			for _, c := range candidates {
+				for _, o := range options {
+					next = append(next, candidate{
+						text:  c.text + " " + o.text,
+						terms: append(append([]string{}, c.terms...), o.term),
+						score: c.score + o.weight + math.Log1p(float64(o.df))/100,
+					})
+				}
+			}
+			candidates = next
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score > candidates[j].score
+	})
+
+	suggestions := []string{}
+	for _, c := range candidates {
+		if len(suggestions) == n {
+			break
+		}
+
+		if s.cooccur(c.terms) {
+			suggestions = append(suggestions, strings.TrimSpace(c.text))
+		}
+	}
+
+	return suggestions
+}
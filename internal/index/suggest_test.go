@@ -0,0 +1,48 @@
+package index
+
+import (
+	"testing"
+
+	"github.com/farouqzaib/fast-search/internal/analyzer"
+)
+
+func TestSuggest(t *testing.T) {
+	first := NewInvertedIndex()
+	first.Index(1, "raft consensus keeps replicated logs consistent")
+
+	second := NewInvertedIndex()
+	second.Index(2, "draft documents about paxos")
+
+	mapped, err := OpenInvertedIndex(second.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewSuggester(analyzer.Default(), []TermReader{first, mapped}, []CompletionReader{first, mapped})
+
+	got := s.Suggest("rafy consensus", 3)
+	if len(got) == 0 || got[0] != "raft consensus" {
+		t.Fatalf("expected raft consensus first, got %v", got)
+	}
+
+	// draft is as close to drafy as raft is, but never occurs with consensus
+	for _, suggestion := range s.Suggest("drafy consensus", 3) {
+		if suggestion == "draft consensus" {
+			t.Fatalf("expected terms that never occur together not to be suggested, got %v", suggestion)
+		}
+	}
+
+	if got := s.Suggest("raft consensus", 3); len(got) != 0 {
+		t.Fatalf("expected no suggestions for a correctly spelled query, got %v", got)
+	}
+
+	if got := s.Suggest("zzzzzzzz", 3); len(got) != 0 {
+		t.Fatalf("expected no suggestions without close terms, got %v", got)
+	}
+
+	// corrections are words as documents spell them, not their stems
+	first.Index(3, "distributed systems experience")
+	if got := s.Suggest("distributed expirience", 3); len(got) == 0 || got[0] != "distributed experience" {
+		t.Fatalf("expected distributed experience first, got %v", got)
+	}
+}
@@ -0,0 +1,268 @@
+package index
+
+import (
+	"encoding/binary"
+	"sort"
+	"strings"
+)
+
+// The term dictionary stores terms in sorted order, in blocks of
+// termBlockSize entries:
+//
+//	term count | block count                          (uint32s)
+//	block index: block start, per block               (uint32s)
+//	blocks:      shared prefix length | suffix length | suffix |
+//	             postings offset | postings length    (uvarints), per term
+//
+// Terms are front coded against the previous term in their block, and the
+// first term of every block is stored whole so the block index can be binary
+// searched without decoding anything else.
+const termBlockSize = 32
+
+type termEntry struct {
+	term string
+	ref  postingsRef
+}
+
+func encodeTermDictionary(entries []termEntry) []byte {
+	index := []byte{}
+	blocks := []byte{}
+
+	prev := ""
+	for i, e := range entries {
+		shared := 0
+		if i%termBlockSize == 0 {
+			index = binary.LittleEndian.AppendUint32(index, uint32(len(blocks)))
+		} else {
+			for shared < len(prev) && shared < len(e.term) && prev[shared] == e.term[shared] {
+				shared++
+			}
+		}
+
+		blocks = binary.AppendUvarint(blocks, uint64(shared))
+		blocks = binary.AppendUvarint(blocks, uint64(len(e.term)-shared))
+		blocks = append(blocks, e.term[shared:]...)
+		blocks = binary.AppendUvarint(blocks, uint64(e.ref.offset))
+		blocks = binary.AppendUvarint(blocks, uint64(e.ref.length))
+		prev = e.term
+	}
+
+	b := binary.LittleEndian.AppendUint32(nil, uint32(len(entries)))
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(index)/4))
+	b = append(b, index...)
+	return append(b, blocks...)
+}
+
+type termDictionary struct {
+	count  int
+	nblock int
+	index  []byte
+	blocks []byte
+}
+
+func openTermDictionary(b []byte) (*termDictionary, error) {
+	if len(b) < 8 {
+		return nil, ErrCorruptSegment
+	}
+
+	d := &termDictionary{
+		count:  int(binary.LittleEndian.Uint32(b[0:4])),
+		nblock: int(binary.LittleEndian.Uint32(b[4:8])),
+	}
+
+	if d.nblock != (d.count+termBlockSize-1)/termBlockSize || 8+d.nblock*4 > len(b) {
+		return nil, ErrCorruptSegment
+	}
+
+	d.index = b[8 : 8+d.nblock*4]
+	d.blocks = b[8+d.nblock*4:]
+	return d, nil
+}
+
+func (d *termDictionary) blockStart(n int) int {
+	return int(binary.LittleEndian.Uint32(d.index[n*4:]))
+}
+
+// firstTerm returns the term every block starts with, stored unshared.
+func (d *termDictionary) firstTerm(n int) string {
+	b := d.blocks[d.blockStart(n):]
+	_, k := binary.Uvarint(b)
+	b = b[k:]
+	length, k := binary.Uvarint(b)
+	return string(b[k : k+int(length)])
+}
+
+// seek returns an iterator positioned just before the first term that is not
+// less than term.
+func (d *termDictionary) seek(term string) *dictionaryIterator {
+	if d.count == 0 {
+		return &dictionaryIterator{d: d}
+	}
+
+	// the last block whose first term is not greater than term
+	n := sort.Search(d.nblock, func(i int) bool { return d.firstTerm(i) > term }) - 1
+	if n < 0 {
+		n = 0
+	}
+
+	it := &dictionaryIterator{d: d, entry: n * termBlockSize, offset: d.blockStart(n)}
+	for it.advance() {
+		if it.current.term >= term {
+			it.pending = true
+			break
+		}
+	}
+
+	return it
+}
+
+func (d *termDictionary) lookup(term string) (postingsRef, bool) {
+	it := d.seek(term)
+	if !it.Next() || it.Term() != term {
+		return postingsRef{}, false
+	}
+	return it.current.ref, true
+}
+
+// terms returns the terms in [lower, upper). An empty upper bound is unbounded.
+func (d *termDictionary) terms(lower, upper string) *dictionaryIterator {
+	it := d.seek(lower)
+	it.upper, it.bounded = upper, upper != ""
+	return it
+}
+
+func (d *termDictionary) prefix(prefix string) *dictionaryIterator {
+	it := d.seek(prefix)
+	it.prefix = prefix
+	return it
+}
+
+// TermIterator walks terms in sorted order. Seek skips ahead, so that Next
+// moves to the first term not less than the one given, within the iterator's
+// bounds; seeking to a term not after the current one has no effect.
+type TermIterator interface {
+	Next() bool
+	Term() string
+	Seek(term string)
+}
+
+type dictionaryIterator struct {
+	d       *termDictionary
+	entry   int
+	offset  int
+	prev    []byte
+	current termEntry
+	pending bool
+	upper   string
+	prefix  string
+	bounded bool
+}
+
+// advance decodes the next entry into current, ignoring the bounds.
+func (it *dictionaryIterator) advance() bool {
+	if it.entry >= it.d.count {
+		return false
+	}
+
+	b := it.d.blocks[it.offset:]
+	shared, k1 := binary.Uvarint(b)
+	b = b[k1:]
+	length, k2 := binary.Uvarint(b)
+	b = b[k2:]
+	suffix := b[:length]
+	b = b[length:]
+	offset, k3 := binary.Uvarint(b)
+	b = b[k3:]
+	size, k4 := binary.Uvarint(b)
+
+	if it.entry%termBlockSize == 0 {
+		shared = 0
+	}
+
+	it.prev = append(it.prev[:shared], suffix...)
+	it.current = termEntry{term: string(it.prev), ref: postingsRef{offset: int(offset), length: int(size)}}
+	it.offset += k1 + k2 + int(length) + k3 + k4
+	it.entry++
+	return true
+}
+
+func (it *dictionaryIterator) Next() bool {
+	if it.pending {
+		it.pending = false
+	} else if !it.advance() {
+		return false
+	}
+
+	if it.bounded && it.current.term >= it.upper {
+		it.entry = it.d.count
+		return false
+	}
+
+	if !strings.HasPrefix(it.current.term, it.prefix) {
+		it.entry = it.d.count
+		return false
+	}
+
+	return true
+}
+
+func (it *dictionaryIterator) Term() string {
+	return it.current.term
+}
+
+func (it *dictionaryIterator) Seek(term string) {
+	if term <= it.current.term || it.entry >= it.d.count && !it.pending {
+		return
+	}
+
+	next := it.d.seek(term)
+	it.entry, it.offset, it.prev, it.current, it.pending = next.entry, next.offset, next.prev, next.current, next.pending
+}
+
+type sliceIterator struct {
+	terms []string
+	n     int
+}
+
+func (it *sliceIterator) Next() bool {
+	it.n++
+	return it.n <= len(it.terms)
+}
+
+func (it *sliceIterator) Term() string {
+	return it.terms[it.n-1]
+}
+
+func (it *sliceIterator) Seek(term string) {
+	// terms[n] is the next term, terms[n-1] the current one
+	if it.n >= len(it.terms) {
+		return
+	}
+	if next := it.n + sort.SearchStrings(it.terms[it.n:], term); next > it.n {
+		it.n = next
+	}
+}
+
+// termRange returns the terms of a sorted slice in [lower, upper). An empty
+// upper bound is unbounded.
+func termRange(sorted []string, lower, upper string) []string {
+	start := sort.SearchStrings(sorted, lower)
+	end := len(sorted)
+	if upper != "" {
+		end = sort.SearchStrings(sorted, upper)
+	}
+
+	if end < start {
+		end = start
+	}
+	return sorted[start:end]
+}
+
+func termPrefix(sorted []string, prefix string) []string {
+	start := sort.SearchStrings(sorted, prefix)
+	end := start
+	for end < len(sorted) && strings.HasPrefix(sorted[end], prefix) {
+		end++
+	}
+	return sorted[start:end]
+}
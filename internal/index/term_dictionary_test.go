@@ -0,0 +1,160 @@
+package index
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func randomTerms(n int) []string {
+	letters := "abcdefgh"
+	seen := map[string]bool{}
+	terms := []string{}
+	for len(terms) < n {
+		b := make([]byte, 1+rand.Intn(7))
+		for i := range b {
+			b[i] = letters[rand.Intn(len(letters))]
+		}
+		if !seen[string(b)] {
+			seen[string(b)] = true
+			terms = append(terms, string(b))
+		}
+	}
+	sort.Strings(terms)
+	return terms
+}
+
+func collect(it TermIterator) []string {
+	terms := []string{}
+	for it.Next() {
+		terms = append(terms, it.Term())
+	}
+	return terms
+}
+
+func TestTermDictionaryLookup(t *testing.T) {
+	terms := randomTerms(1000)
+
+	entries := []termEntry{}
+	for i, term := range terms {
+		entries = append(entries, termEntry{term: term, ref: postingsRef{offset: i * 10, length: i}})
+	}
+
+	d, err := openTermDictionary(encodeTermDictionary(entries))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, term := range terms {
+		ref, ok := d.lookup(term)
+		if !ok || ref.offset != i*10 || ref.length != i {
+			t.Fatalf("lookup %s: expected %v, got %v, %v", term, entries[i].ref, ref, ok)
+		}
+	}
+
+	for _, missing := range []string{"", "z", "aaaaaaaaa", "hhhhhhhhz"} {
+		if _, ok := d.lookup(missing); ok {
+			t.Fatalf("expected %q to be missing", missing)
+		}
+	}
+}
+
+func TestTermDictionaryPrefixAndRange(t *testing.T) {
+	terms := randomTerms(1000)
+
+	entries := []termEntry{}
+	for _, term := range terms {
+		entries = append(entries, termEntry{term: term})
+	}
+
+	d, err := openTermDictionary(encodeTermDictionary(entries))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, prefix := range []string{"", "a", "bc", "hhh", "z"} {
+		expected := []string{}
+		for _, term := range terms {
+			if strings.HasPrefix(term, prefix) {
+				expected = append(expected, term)
+			}
+		}
+
+		got := collect(d.prefix(prefix))
+		if fmt.Sprint(expected) != fmt.Sprint(got) {
+			t.Fatalf("prefix %q: expected %d terms, got %d", prefix, len(expected), len(got))
+		}
+	}
+
+	for _, bounds := range [][2]string{{"", ""}, {"b", "c"}, {"cab", "cah"}, {"g", ""}, {"d", "a"}} {
+		expected := []string{}
+		for _, term := range terms {
+			if term >= bounds[0] && (bounds[1] == "" || term < bounds[1]) {
+				expected = append(expected, term)
+			}
+		}
+
+		got := collect(d.terms(bounds[0], bounds[1]))
+		if fmt.Sprint(expected) != fmt.Sprint(got) {
+			t.Fatalf("range %v: expected %d terms, got %d", bounds, len(expected), len(got))
+		}
+	}
+}
+
+func TestMappedInvertedIndexTerms(t *testing.T) {
+	index := NewInvertedIndex()
+	index.Index(1, "distributed systems distribute replicated logs to distant replicas")
+
+	mapped, err := OpenInvertedIndex(index.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := collect(index.PrefixTerms("dist"))
+	got := collect(mapped.PrefixTerms("dist"))
+	if len(expected) == 0 || fmt.Sprint(expected) != fmt.Sprint(got) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+
+	expected = collect(index.Terms("", ""))
+	got = collect(mapped.Terms("", ""))
+	if !sort.StringsAreSorted(got) || fmt.Sprint(expected) != fmt.Sprint(got) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestTermIteratorSeek(t *testing.T) {
+	index := NewInvertedIndex()
+	index.Index(1, "distributed systems distribute replicated logs to distant replicas")
+
+	mapped, err := OpenInvertedIndex(index.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{}
+	for _, term := range collect(index.Terms("", "")) {
+		if term >= "dist" {
+			expected = append(expected, term)
+		}
+	}
+
+	for _, r := range []TermReader{index, mapped} {
+		it := r.Terms("", "")
+		it.Seek("dist")
+		if got := collect(it); len(expected) == 0 || fmt.Sprint(got) != fmt.Sprint(expected) {
+			t.Fatalf("expected %v, got %v", expected, got)
+		}
+
+		// seeking back has no effect, and a prefix iterator stays in its prefix
+		it = r.PrefixTerms("dist")
+		it.Next()
+		it.Seek("a")
+		it.Seek("distr")
+		if got := collect(it); fmt.Sprint(got) != "[distribut]" {
+			t.Fatalf("expected the terms from distr, got %v", got)
+		}
+	}
+}
@@ -4,9 +4,13 @@ import (
 	"encoding/binary"
 	"encoding/json"
 	"errors"
+	"io"
 	"log/slog"
 	"net/http"
+	"strconv"
 
+	"github.com/farouqzaib/fast-search/internal/analyzer"
+	"github.com/farouqzaib/fast-search/internal/index"
 	"github.com/farouqzaib/fast-search/internal/storage"
 	"github.com/gorilla/mux"
 	"go.etcd.io/bbolt"
@@ -16,9 +20,26 @@ func NewHttpServer(index *storage.DistributedDB, metadataStorage *bbolt.DB, logg
 	srv := newHttpServer(index, metadataStorage, logger)
 	r := mux.NewRouter()
 	r.HandleFunc("/search", srv.handleSearch).Methods("GET")
+	r.HandleFunc("/suggest", srv.handleSuggest).Methods("GET")
+	r.HandleFunc("/analyze", srv.handleAnalyze).Methods("POST")
 	r.HandleFunc("/index", srv.handleIndex).Methods("POST")
 	r.HandleFunc("/join", srv.handleJoin).Methods("POST")
 	r.HandleFunc("/bulkIndex", srv.handleBulkIndex).Methods("POST")
+	r.HandleFunc("/export", srv.handleExport).Methods("GET")
+	r.HandleFunc("/collections", srv.handleListCollections).Methods("GET")
+	r.HandleFunc("/collections", srv.handleCreateCollection).Methods("POST")
+	r.HandleFunc("/collections/{collection}", srv.handleDeleteCollection).Methods("DELETE")
+	r.HandleFunc("/collections/{collection}/search", srv.handleSearch).Methods("GET")
+	r.HandleFunc("/collections/{collection}/suggest", srv.handleSuggest).Methods("GET")
+	r.HandleFunc("/collections/{collection}/analyze", srv.handleAnalyze).Methods("POST")
+	r.HandleFunc("/collections/{collection}/index", srv.handleIndex).Methods("POST")
+	r.HandleFunc("/collections/{collection}/bulkIndex", srv.handleBulkIndex).Methods("POST")
+	r.HandleFunc("/collections/{collection}/export", srv.handleExport).Methods("GET")
+	r.HandleFunc("/collections/{collection}/filters/{filter}/words", srv.handleGetWords).Methods("GET")
+	r.HandleFunc("/collections/{collection}/filters/{filter}/words", srv.handleSetWords).Methods("PUT")
+	r.HandleFunc("/synonyms/reload", srv.handleReloadSynonyms).Methods("POST")
+	r.HandleFunc("/filters/{filter}/words", srv.handleGetWords).Methods("GET")
+	r.HandleFunc("/filters/{filter}/words", srv.handleSetWords).Methods("PUT")
 
 	return &http.Server{
 		Addr:    addr,
@@ -42,25 +63,108 @@ func newHttpServer(index *storage.DistributedDB, metadataStorage *bbolt.DB, logg
 
 type SearchRequest struct {
 	Query string `json:"query"`
+	// MaxExpansions caps how many terms each prefix, wildcard or regular
+	// expression clause may expand to.
+	MaxExpansions int `json:"max_expansions"`
+	// Fuzzy matches every word of the query within an edit distance that
+	// grows with its length; PrefixLength leading characters must match exactly.
+	Fuzzy        bool `json:"fuzzy"`
+	PrefixLength int  `json:"prefix_length"`
+	// Highlight asks for snippets of every hit with the query terms marked.
+	Highlight *HighlightRequest `json:"highlight"`
+	// Language analyzes the query as that language, to match documents
+	// indexed in it, or detects it when "auto".
+	Language string `json:"language"`
+	// Fields are the fields words not scoped to a field are searched in,
+	// such as ["title^3", "body"]; Mode is "most_fields" (the default) to
+	// add up a document's scores in them or "best_fields" to keep its best.
+	Fields []string `json:"fields"`
+	Mode   string   `json:"mode"`
+	// Filter restricts hits to documents whose fields hold the values it
+	// asks for, e.g. "tenant_id = 42 AND created_at > 2026-01-01".
+	Filter string `json:"filter"`
+	// Aggregations summarize fields over the documents the query matches,
+	// by name, e.g. {"by_tag": {"type": "terms", "field": "tags"}}.
+	Aggregations map[string]index.Aggregation `json:"aggregations"`
+	// Sort orders hits by fields or score, e.g. [{"field": "created_at",
+	// "order": "desc"}, {"field": "_score"}], and SearchAfter, the
+	// search_after of the previous response, asks for the page after it.
+	// Size is the number of hits a page holds.
+	Sort        []index.SortField `json:"sort"`
+	SearchAfter string            `json:"search_after"`
+	Size        int               `json:"size"`
+}
+
+const defaultSearchSize = 10
+
+// ExportRequest selects the documents an export streams, every document of
+// the collection when empty.
+type ExportRequest struct {
+	Query    string   `json:"query"`
+	Language string   `json:"language"`
+	Fields   []string `json:"fields"`
+	Mode     string   `json:"mode"`
+	Filter   string   `json:"filter"`
+}
+
+// ExportedDocument is one line of an export.
+type ExportedDocument struct {
+	DocId    int                    `json:"documentID"`
+	Document string                 `json:"document"`
+	Fields   map[string]interface{} `json:"fields,omitempty"`
+	Language string                 `json:"language,omitempty"`
+}
+
+// exportBatchSize is how many documents an export reads from the metadata
+// storage in one transaction, and writes before flushing.
+const exportBatchSize = 500
+
+type HighlightRequest struct {
+	FragmentSize      int    `json:"fragment_size"`
+	NumberOfFragments int    `json:"number_of_fragments"`
+	PreTag            string `json:"pre_tag"`
+	PostTag           string `json:"post_tag"`
 }
 
 type Hit struct {
-	DocId    int     `json:"documentID"`
-	Offset   []int   `json:"offset"`
-	Document string  `json:"document"`
-	Score    float64 `json:"score"`
+	DocId  int   `json:"documentID"`
+	Offset []int `json:"offset"`
+	// ByteOffset is the cover of Offset as a byte range of the text of Field.
+	ByteOffset []int                  `json:"byte_offset,omitempty"`
+	Document   string                 `json:"document"`
+	Fields     map[string]interface{} `json:"fields,omitempty"`
+	Score      float64                `json:"score"`
+	// Field is the field Offset, ByteOffset and Highlights are in, empty for
+	// the document text.
+	Field      string   `json:"field,omitempty"`
+	Highlights []string `json:"highlights,omitempty"`
+	Language   string   `json:"language,omitempty"`
+	// Sort are the values a sorted search ordered the hit by.
+	Sort []interface{} `json:"sort,omitempty"`
 }
 
 type SearchResponse struct {
 	Hits []Hit `json:"hits"`
+	// Suggestions are corrected queries, offered when no hit matched the
+	// query's terms.
+	Suggestions  []string           `json:"suggestions,omitempty"`
+	Aggregations index.Aggregations `json:"aggregations,omitempty"`
+	// SearchAfter pages on from the last hit of a sorted search.
+	SearchAfter string `json:"search_after,omitempty"`
 }
 
 func (s *httpServer) handleSearch(w http.ResponseWriter, r *http.Request) {
 	s.logger.Info("http: search")
 
+	name, db, err := s.collection(r)
+	if err != nil {
+		collectionError(w, err)
+		return
+	}
+
 	var req SearchRequest
 
-	err := json.NewDecoder(r.Body).Decode(&req)
+	err = json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -68,7 +172,40 @@ func (s *httpServer) handleSearch(w http.ResponseWriter, r *http.Request) {
 
 	s.logger.Info("query term", slog.String("query", req.Query))
 
-	matches, err := s.index.Search(req.Query, 10)
+	language, err := resolveLanguage(req.Language, req.Query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	size := req.Size
+	if size <= 0 {
+		size = defaultSearchSize
+	}
+
+	matches, aggs, err := s.index.Search(name, index.Query{
+		Text:          req.Query,
+		MaxExpansions: req.MaxExpansions,
+		Fuzzy:         req.Fuzzy,
+		PrefixLength:  req.PrefixLength,
+		Language:      language,
+		Fields:        req.Fields,
+		Mode:          req.Mode,
+		Filter:        req.Filter,
+		Aggregations:  req.Aggregations,
+		Sort:          req.Sort,
+		SearchAfter:   req.SearchAfter,
+	}, size)
+
+	if errors.Is(err, index.ErrInvalidQuery) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if errors.Is(err, storage.ErrCollectionNotFound) {
+		collectionError(w, err)
+		return
+	}
 
 	if err != nil {
 		slog.Error("http: search", slog.String("error", err.Error()))
@@ -76,25 +213,50 @@ func (s *httpServer) handleSearch(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	res := SearchResponse{}
+	res := SearchResponse{Aggregations: aggs}
+	if len(matches) > 0 && (len(req.Sort) > 0 || req.SearchAfter != "") {
+		res.SearchAfter = index.Cursor(matches[len(matches)-1])
+	}
 
 	err = s.metadataStorage.View(func(tx *bbolt.Tx) error {
-		b := tx.Bucket([]byte(storage.DocumentMetadataBucket))
+		b := tx.Bucket([]byte(storage.DocumentBucket(name)))
 		if b == nil {
 			return errors.New("bucket does not exist")
 		}
 
 		for _, match := range matches {
+			document := storage.DecodeDocument(b.Get(itob(int(match.Offsets[0].DocumentID))))
 			hit := Hit{
 				DocId:    int(match.Offsets[0].DocumentID),
-				Document: string(b.Get(itob(int(match.Offsets[0].DocumentID)))),
+				Document: document.Text,
+				Fields:   document.Fields,
 				Offset:   []int{},
 				Score:    match.Score,
+				Field:    match.Field,
+				Language: document.Language,
+				Sort:     match.Sort,
+			}
+
+			// highlights are drawn from the text of the field matched, which
+			// is only at hand if the field is stored
+			text := document.Text
+			if match.Field != "" {
+				text, _ = document.Fields[match.Field].(string)
 			}
 
 			//only FTS records term offsets
 			if len(match.Offsets) == 2 {
 				hit.Offset = []int{int(match.Offsets[0].Offset), int(match.Offsets[1].Offset)}
+				hit.ByteOffset = []int{match.Offsets[0].Start, match.Offsets[1].End}
+			}
+
+			if req.Highlight != nil && text != "" {
+				hit.Highlights = index.Highlight(text, match.Highlights, index.HighlightOptions{
+					FragmentSize:      req.Highlight.FragmentSize,
+					NumberOfFragments: req.Highlight.NumberOfFragments,
+					PreTag:            req.Highlight.PreTag,
+					PostTag:           req.Highlight.PostTag,
+				})
 			}
 
 			res.Hits = append(res.Hits, hit)
@@ -108,6 +270,10 @@ func (s *httpServer) handleSearch(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !hasTermMatch(res.Hits) {
+		res.Suggestions = db.Suggest(req.Query, maxSuggestions)
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	err = json.NewEncoder(w).Encode(res)
 	if err != nil {
@@ -117,19 +283,307 @@ func (s *httpServer) handleSearch(w http.ResponseWriter, r *http.Request) {
 	return
 }
 
+// handleExport streams the stored documents matching a query, or all of
+// them, as newline-delimited JSON in ascending document order per memtable
+// and segment.
+func (s *httpServer) handleExport(w http.ResponseWriter, r *http.Request) {
+	s.logger.Info("http: export")
+
+	name, _, err := s.collection(r)
+	if err != nil {
+		collectionError(w, err)
+		return
+	}
+
+	var req ExportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	language, err := resolveLanguage(req.Language, req.Query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+	started := false
+	batch := []int{}
+
+	write := func() error {
+		if !started {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			started = true
+		}
+
+		err := s.metadataStorage.View(func(tx *bbolt.Tx) error {
+			b := tx.Bucket([]byte(storage.DocumentBucket(name)))
+			if b == nil {
+				return errors.New("bucket does not exist")
+			}
+
+			for _, id := range batch {
+				raw := b.Get(itob(id))
+				if raw == nil {
+					continue
+				}
+
+				document := storage.DecodeDocument(raw)
+				if err := enc.Encode(ExportedDocument{DocId: id, Document: document.Text, Fields: document.Fields, Language: document.Language}); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		batch = batch[:0]
+
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return err
+	}
+
+	err = s.index.Scroll(name, index.Query{
+		Text:     req.Query,
+		Language: language,
+		Fields:   req.Fields,
+		Mode:     req.Mode,
+		Filter:   req.Filter,
+	}, func(docID int) error {
+		batch = append(batch, docID)
+		if len(batch) < exportBatchSize {
+			return nil
+		}
+		return write()
+	})
+	if err == nil && (len(batch) > 0 || !started) {
+		err = write()
+	}
+
+	switch {
+	case err == nil:
+	case started:
+		// the status is sent, so all that is left is to cut the stream short
+		slog.Error("http: export", slog.String("error", err.Error()))
+	case errors.Is(err, index.ErrInvalidQuery):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, storage.ErrCollectionNotFound):
+		collectionError(w, err)
+	default:
+		slog.Error("http: export", slog.String("error", err.Error()))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+const maxSuggestions = 3
+
+// hasTermMatch reports whether any hit came from the full-text index rather
+// than from semantic search alone, which always finds neighbours.
+func hasTermMatch(hits []Hit) bool {
+	for _, hit := range hits {
+		if len(hit.Offset) == 2 {
+			return true
+		}
+	}
+	return false
+}
+
+const defaultCompletions = 10
+
+type SuggestResponse struct {
+	Completions []index.Completion `json:"completions"`
+}
+
+func (s *httpServer) handleSuggest(w http.ResponseWriter, r *http.Request) {
+	s.logger.Info("http: suggest")
+
+	_, db, err := s.collection(r)
+	if err != nil {
+		collectionError(w, err)
+		return
+	}
+
+	size := defaultCompletions
+	if raw := r.URL.Query().Get("size"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			http.Error(w, "size must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		size = n
+	}
+
+	res := SuggestResponse{Completions: db.Complete(r.URL.Query().Get("prefix"), size)}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(res)
+	if err != nil {
+		slog.Error("http: suggest", slog.String("error", err.Error()))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+type AnalyzeRequest struct {
+	Text string `json:"text"`
+	// Analyzer defaults to the index's.
+	Analyzer string `json:"analyzer"`
+	// Explain asks whether each term the text analyzes to as a query is in
+	// the index, and in how many documents.
+	Explain bool `json:"explain"`
+}
+
+type AnalyzedToken struct {
+	Term     string `json:"term"`
+	Position int    `json:"position"`
+	Start    int    `json:"start"`
+	End      int    `json:"end"`
+	Variant  bool   `json:"variant,omitempty"`
+	Keyword  bool   `json:"keyword,omitempty"`
+}
+
+type AnalyzeStage struct {
+	Name   string          `json:"name"`
+	Tokens []AnalyzedToken `json:"tokens"`
+}
+
+type TermExplanation struct {
+	Term              string `json:"term"`
+	Exists            bool   `json:"exists"`
+	DocumentFrequency int    `json:"document_frequency"`
+}
+
+type AnalyzeResponse struct {
+	Analyzer string         `json:"analyzer"`
+	Stages   []AnalyzeStage `json:"stages"`
+	// Query is the terms of every rewrite of the text as a query, which
+	// differ from the last stage when filters such as synonyms rewrite
+	// queries.
+	Query   [][]string        `json:"query"`
+	Explain []TermExplanation `json:"explain,omitempty"`
+}
+
+func (s *httpServer) handleAnalyze(w http.ResponseWriter, r *http.Request) {
+	s.logger.Info("http: analyze")
+
+	_, db, err := s.collection(r)
+	if err != nil {
+		collectionError(w, err)
+		return
+	}
+
+	var req AnalyzeRequest
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		slog.Error("http: analyze", slog.String("error", err.Error()))
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	a := db.Analyzer()
+	if req.Analyzer != "" {
+		a, err = analyzer.Get(req.Analyzer)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	res := AnalyzeResponse{Analyzer: a.Name, Stages: []AnalyzeStage{}, Query: [][]string{}}
+	for _, stage := range a.Stages(req.Text) {
+		positions := analyzer.Positions(stage.Tokens)
+		tokens := make([]AnalyzedToken, len(stage.Tokens))
+		for i, token := range stage.Tokens {
+			tokens[i] = AnalyzedToken{Term: token.Term, Position: positions[i], Start: token.Start, End: token.End, Variant: token.Variant, Keyword: token.Keyword}
+		}
+		res.Stages = append(res.Stages, AnalyzeStage{Name: stage.Name, Tokens: tokens})
+	}
+
+	seen := map[string]bool{}
+	for _, rewrite := range a.AnalyzeQuery(req.Text) {
+		terms := make([]string, len(rewrite))
+		for i, token := range rewrite {
+			terms[i] = token.Term
+
+			if req.Explain && !seen[token.Term] {
+				seen[token.Term] = true
+				df := db.DocumentFrequency(token.Term)
+				res.Explain = append(res.Explain, TermExplanation{Term: token.Term, Exists: df > 0, DocumentFrequency: df})
+			}
+		}
+		res.Query = append(res.Query, terms)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(res)
+	if err != nil {
+		slog.Error("http: analyze", slog.String("error", err.Error()))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
 type OkResponse struct {
 	Status string `json:"status"`
 }
 
 type Document struct {
 	Text string `json:"text"`
+	// Fields are the values of the fields the schema declares, keyed by
+	// field name.
+	Fields map[string]interface{} `json:"fields"`
+	// Language is the language the document is written in, by name or ISO
+	// 639-1 code, or "auto" to detect it. Empty uses the index's analyzer.
+	Language string `json:"language"`
+}
+
+// indexDocument checks the fields of a document against the schema and
+// resolves its language, detected from its text and text fields for "auto".
+func indexDocument(schema *index.Schema, d Document) (index.Document, error) {
+	if err := schema.Validate(d.Fields); err != nil {
+		return index.Document{}, err
+	}
+
+	document := index.Document{Text: d.Text, Fields: d.Fields}
+	language, err := resolveLanguage(d.Language, schema.FullText(document))
+	if err != nil {
+		return index.Document{}, err
+	}
+
+	document.Language = language
+	return document, nil
+}
+
+func storedDocument(schema *index.Schema, d index.Document) ([]byte, error) {
+	return storage.EncodeDocument(storage.StoredDocument{Text: d.Text, Fields: schema.Stored(d.Fields), Language: d.Language})
+}
+
+// resolveLanguage returns the supported language asked for, detecting it from
+// text for "auto". Text in no language it can tell gets the index's analyzer.
+func resolveLanguage(language string, text string) (string, error) {
+	switch language {
+	case "":
+		return "", nil
+	case analyzer.AutoLanguage:
+		return analyzer.DetectLanguage(text), nil
+	}
+	return analyzer.Language(language)
 }
 
 func (s *httpServer) handleIndex(w http.ResponseWriter, r *http.Request) {
 	s.logger.Info("http: indexing")
+
+	name, db, err := s.collection(r)
+	if err != nil {
+		collectionError(w, err)
+		return
+	}
+
 	var req Document
 	decoder := json.NewDecoder(r.Body)
-	err := decoder.Decode(&req)
+	err = decoder.Decode(&req)
 
 	if err != nil {
 		slog.Error("http: indexing", slog.String("error", err.Error()))
@@ -137,16 +591,29 @@ func (s *httpServer) handleIndex(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	document, err := indexDocument(db.Schema(), req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	stored, err := storedDocument(db.Schema(), document)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	var docId int
 	err = s.metadataStorage.Update(func(tx *bbolt.Tx) error {
-		b := tx.Bucket([]byte(storage.DocumentMetadataBucket))
-		if b == nil {
-			return errors.New("bucket does not exist")
+		// collections created through another node have no bucket here yet
+		b, err := tx.CreateBucketIfNotExists([]byte(storage.DocumentBucket(name)))
+		if err != nil {
+			return err
 		}
 
 		id, _ := b.NextSequence()
 		docId = int(id)
-		err := b.Put(itob(docId), []byte(req.Text))
+		err = b.Put(itob(docId), stored)
 
 		if err != nil {
 			slog.Error("http: indexing", slog.String("error", err.Error()))
@@ -160,7 +627,7 @@ func (s *httpServer) handleIndex(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 
-	err = s.index.Index(docId, req.Text)
+	err = s.index.Index(name, docId, document)
 	if err != nil {
 		slog.Error("http: indexing", slog.String("error", err.Error()))
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -211,6 +678,87 @@ func (s *httpServer) handleJoin(w http.ResponseWriter, r *http.Request) {
 	return
 }
 
+// handleReloadSynonyms rereads the synonym files of this node's analyzers.
+func (s *httpServer) handleReloadSynonyms(w http.ResponseWriter, r *http.Request) {
+	s.logger.Info("http: reloading synonyms")
+
+	if err := analyzer.ReloadSynonyms(); err != nil {
+		slog.Error("http: reloading synonyms", slog.String("error", err.Error()))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(OkResponse{Status: "OK!"})
+	if err != nil {
+		slog.Error("http: reloading synonyms", slog.String("error", err.Error()))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// WordList is the words of a stop or keyword_marker filter.
+type WordList struct {
+	Words []string `json:"words"`
+}
+
+func (s *httpServer) handleGetWords(w http.ResponseWriter, r *http.Request) {
+	s.logger.Info("http: get words")
+
+	name, _, err := s.collection(r)
+	if err != nil {
+		collectionError(w, err)
+		return
+	}
+
+	words, err := s.index.Words(name, mux.Vars(r)["filter"])
+	if err != nil {
+		collectionError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(WordList{Words: words})
+	if err != nil {
+		slog.Error("http: get words", slog.String("error", err.Error()))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleSetWords replaces the words of a stop or keyword_marker filter of a
+// collection on every node. Documents already indexed keep the terms the old
+// words left them.
+func (s *httpServer) handleSetWords(w http.ResponseWriter, r *http.Request) {
+	s.logger.Info("http: set words")
+
+	name, _, err := s.collection(r)
+	if err != nil {
+		collectionError(w, err)
+		return
+	}
+
+	var req WordList
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.Error("http: set words", slog.String("error", err.Error()))
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.index.SetWords(name, mux.Vars(r)["filter"], req.Words); err != nil {
+		collectionError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(OkResponse{Status: "OK!"})
+	if err != nil {
+		slog.Error("http: set words", slog.String("error", err.Error()))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
 // itob returns an 8-byte big endian representation of v.
 func itob(v int) []byte {
 	b := make([]byte, 8)
@@ -225,9 +773,16 @@ type BulkIndex struct {
 
 func (s *httpServer) handleBulkIndex(w http.ResponseWriter, r *http.Request) {
 	s.logger.Info("http: bulk indexing")
+
+	name, db, err := s.collection(r)
+	if err != nil {
+		collectionError(w, err)
+		return
+	}
+
 	var req BulkIndex
 	decoder := json.NewDecoder(r.Body)
-	err := decoder.Decode(&req)
+	err = decoder.Decode(&req)
 
 	if err != nil {
 		slog.Error("http: bulk indexing", slog.String("error", err.Error()))
@@ -235,25 +790,36 @@ func (s *httpServer) handleBulkIndex(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	documents := make([]index.Document, len(req.Documents))
+	for i, document := range req.Documents {
+		documents[i], err = indexDocument(db.Schema(), document)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
 	docIds := []int{}
-	documents := []string{}
 	err = s.metadataStorage.Update(func(tx *bbolt.Tx) error {
-		b := tx.Bucket([]byte(storage.DocumentMetadataBucket))
-		if b == nil {
-			return errors.New("bucket does not exist")
+		b, err := tx.CreateBucketIfNotExists([]byte(storage.DocumentBucket(name)))
+		if err != nil {
+			return err
 		}
 
-		for i, document := range req.Documents {
+		for i, document := range documents {
+			stored, err := storedDocument(db.Schema(), document)
+			if err != nil {
+				return err
+			}
+
 			id, _ := b.NextSequence()
 			docIds = append(docIds, int(id))
-			err := b.Put(itob(docIds[i]), []byte(document.Text))
+			err = b.Put(itob(docIds[i]), stored)
 
 			if err != nil {
 				slog.Error("http: bulk indexing", slog.String("error", err.Error()))
 				return err
 			}
-
-			documents = append(documents, req.Documents[i].Text)
 		}
 
 		return nil
@@ -265,7 +831,7 @@ func (s *httpServer) handleBulkIndex(w http.ResponseWriter, r *http.Request) {
 	}
 
 	//do bulk index using req
-	err = s.index.BulkIndex(docIds, documents)
+	err = s.index.BulkIndex(name, docIds, documents)
 	if err != nil {
 		slog.Error("http: bulk indexing", slog.String("error", err.Error()))
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -280,3 +846,123 @@ func (s *httpServer) handleBulkIndex(w http.ResponseWriter, r *http.Request) {
 	}
 	return
 }
+
+// collection returns the collection a request is for, named in its route, or
+// the default collection for the routes that predate collections.
+func (s *httpServer) collection(r *http.Request) (string, *storage.IndexStorage, error) {
+	name, ok := mux.Vars(r)["collection"]
+	if !ok {
+		name = storage.DefaultCollection
+	}
+
+	db, err := s.index.Collection(name)
+	return name, db, err
+}
+
+// collectionError writes err with the status of the collection error it is.
+func collectionError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, storage.ErrCollectionNotFound), errors.Is(err, analyzer.ErrUnknownWordList):
+		status = http.StatusNotFound
+	case errors.Is(err, storage.ErrCollectionExists):
+		status = http.StatusConflict
+	case errors.Is(err, storage.ErrInvalidCollection):
+		status = http.StatusBadRequest
+	}
+	http.Error(w, err.Error(), status)
+}
+
+type CollectionsResponse struct {
+	Collections []storage.Collection `json:"collections"`
+}
+
+func (s *httpServer) handleListCollections(w http.ResponseWriter, r *http.Request) {
+	s.logger.Info("http: list collections")
+
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(CollectionsResponse{Collections: s.index.Collections()})
+	if err != nil {
+		slog.Error("http: list collections", slog.String("error", err.Error()))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// CreateCollectionRequest names a new collection and configures it, e.g.
+//
+//	{"name": "articles", "analyzer": "standard", "schema": {"fields": {...}}, "vector": {"m": 16}}
+type CreateCollectionRequest struct {
+	Name string `json:"name"`
+	storage.CollectionConfig
+}
+
+func (s *httpServer) handleCreateCollection(w http.ResponseWriter, r *http.Request) {
+	s.logger.Info("http: create collection")
+
+	var req CreateCollectionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.Error("http: create collection", slog.String("error", err.Error()))
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.index.CreateCollection(req.Name, req.CollectionConfig); err != nil {
+		slog.Error("http: create collection", slog.String("error", err.Error()))
+		collectionError(w, err)
+		return
+	}
+
+	err := s.metadataStorage.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(storage.DocumentBucket(req.Name)))
+		return err
+	})
+	if err != nil {
+		slog.Error("http: create collection", slog.String("error", err.Error()))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(OkResponse{Status: "OK!"})
+	if err != nil {
+		slog.Error("http: create collection", slog.String("error", err.Error()))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleDeleteCollection deletes a collection on every node, and the
+// documents this node stored for it.
+func (s *httpServer) handleDeleteCollection(w http.ResponseWriter, r *http.Request) {
+	s.logger.Info("http: delete collection")
+
+	name := mux.Vars(r)["collection"]
+	if err := s.index.DeleteCollection(name); err != nil {
+		slog.Error("http: delete collection", slog.String("error", err.Error()))
+		collectionError(w, err)
+		return
+	}
+
+	err := s.metadataStorage.Update(func(tx *bbolt.Tx) error {
+		err := tx.DeleteBucket([]byte(storage.DocumentBucket(name)))
+		if errors.Is(err, bbolt.ErrBucketNotFound) {
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		slog.Error("http: delete collection", slog.String("error", err.Error()))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(OkResponse{Status: "OK!"})
+	if err != nil {
+		slog.Error("http: delete collection", slog.String("error", err.Error()))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
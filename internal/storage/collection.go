@@ -0,0 +1,427 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/farouqzaib/fast-search/internal/analyzer"
+	"github.com/farouqzaib/fast-search/internal/index"
+)
+
+const (
+	// DefaultCollection is the collection requests that name none go to. It
+	// is configured at startup and lives at the root of the data directory,
+	// where the only index was kept before there were collections.
+	DefaultCollection = "default"
+	collectionsPath   = "collections"
+	collectionConfig  = "collection.json"
+)
+
+var (
+	ErrCollectionNotFound = errors.New("storage: collection not found")
+	ErrCollectionExists   = errors.New("storage: collection already exists")
+	ErrInvalidCollection  = errors.New("storage: invalid collection")
+)
+
+var collectionName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// VectorConfig shapes the HNSW graph semantic search builds in every memtable
+// of a collection. Zero fields take the value of DefaultVectorConfig.
+type VectorConfig struct {
+	// Layers is the number of layers of the graph.
+	Layers int `json:"layers,omitempty"`
+	// LevelMultiplier normalizes the distribution of nodes over the layers.
+	LevelMultiplier float64 `json:"level_multiplier,omitempty"`
+	// M is the number of neighbours a node is linked to.
+	M int `json:"m,omitempty"`
+	// EfConstruction is the number of candidates considered while linking a
+	// new node.
+	EfConstruction int `json:"ef_construction,omitempty"`
+}
+
+var DefaultVectorConfig = VectorConfig{Layers: 5, LevelMultiplier: 0.62, M: 8, EfConstruction: 16}
+
+func (v VectorConfig) withDefaults() VectorConfig {
+	if v.Layers == 0 {
+		v.Layers = DefaultVectorConfig.Layers
+	}
+	if v.LevelMultiplier == 0 {
+		v.LevelMultiplier = DefaultVectorConfig.LevelMultiplier
+	}
+	if v.M == 0 {
+		v.M = DefaultVectorConfig.M
+	}
+	if v.EfConstruction == 0 {
+		v.EfConstruction = DefaultVectorConfig.EfConstruction
+	}
+	return v
+}
+
+func (v VectorConfig) newHNSW() *index.HNSW {
+	v = v.withDefaults()
+	return index.NewHNSW(v.Layers, v.LevelMultiplier, v.M, v.EfConstruction)
+}
+
+// CollectionConfig is how the documents of a collection are indexed.
+type CollectionConfig struct {
+	// Analyzer names the analyzer documents and queries go through,
+	// analyzer.DefaultAnalyzer if empty.
+	Analyzer string `json:"analyzer,omitempty"`
+	// Schema declares the fields of documents, which have only their text
+	// if nil.
+	Schema *index.Schema `json:"schema,omitempty"`
+	Vector VectorConfig  `json:"vector"`
+	// Words replaces the words of stop and keyword_marker filters of the
+	// analyzer, by filter name, for this collection only.
+	Words map[string][]string `json:"words,omitempty"`
+}
+
+// check reports an unknown analyzer, words for a filter it has no list for,
+// a malformed schema or a negative vector setting.
+func (c CollectionConfig) check() error {
+	if _, _, err := c.analyzer(); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidCollection, err)
+	}
+
+	if err := c.Schema.Check(); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidCollection, err)
+	}
+
+	v := c.Vector
+	if v.Layers < 0 || v.LevelMultiplier < 0 || v.M < 0 || v.EfConstruction < 0 {
+		return fmt.Errorf("%w: vector settings must be positive", ErrInvalidCollection)
+	}
+	return nil
+}
+
+// analyzer returns a copy of the analyzer of the collection with word lists
+// of its own, which are returned by filter name.
+func (c CollectionConfig) analyzer() (*analyzer.Analyzer, map[string]*analyzer.WordList, error) {
+	name := c.Analyzer
+	if name == "" {
+		name = analyzer.DefaultAnalyzer
+	}
+
+	a, err := analyzer.Get(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	return a.WithWords(c.Words)
+}
+
+// Collection describes a collection.
+type Collection struct {
+	Name string `json:"name"`
+	CollectionConfig
+}
+
+// DocumentBucket names the bucket the documents of a collection are stored
+// in; the default collection keeps DocumentMetadataBucket.
+func DocumentBucket(collection string) string {
+	if collection == DefaultCollection {
+		return DocumentMetadataBucket
+	}
+	return DocumentMetadataBucket + "/" + collection
+}
+
+// collections are the collections of a node, each with its own memtables and
+// segments in a directory of its own under collectionsPath. The config of a
+// collection is kept beside its segments so it is reopened on restart. The
+// default collection is configured at startup but for its words, which are
+// kept in the collection.json at the root of the data directory.
+type collections struct {
+	mu      sync.RWMutex
+	dataDir string
+	byName  map[string]*collection
+	logger  *slog.Logger
+}
+
+type collection struct {
+	config CollectionConfig
+	dir    string
+	db     *IndexStorage
+	// words are the word lists of the analyzer of db, by filter name.
+	words map[string]*analyzer.WordList
+}
+
+// openCollections opens the default collection at the root of dataDir and
+// every collection created before under it.
+func openCollections(dataDir string, config CollectionConfig, logger *slog.Logger) (*collections, error) {
+	c := &collections{dataDir: dataDir, byName: map[string]*collection{}, logger: logger}
+
+	saved, err := readCollectionConfig(dataDir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	config.Words = saved.Words
+
+	if err := c.open(DefaultCollection, dataDir, config); err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(filepath.Join(dataDir, collectionsPath))
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() && strings.HasPrefix(entry.Name(), ".") {
+			// a collection deleted before its searches were done
+			if err := os.RemoveAll(filepath.Join(dataDir, collectionsPath, entry.Name())); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if !entry.IsDir() || !collectionName.MatchString(entry.Name()) {
+			continue
+		}
+
+		dir := filepath.Join(dataDir, collectionsPath, entry.Name())
+		config, err := readCollectionConfig(dir)
+		if errors.Is(err, os.ErrNotExist) {
+			// the collection was deleted, or never finished being created
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if err := c.open(entry.Name(), dir, config); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func readCollectionConfig(dir string) (CollectionConfig, error) {
+	var config CollectionConfig
+	b, err := os.ReadFile(filepath.Join(dir, collectionConfig))
+	if err != nil {
+		return config, err
+	}
+
+	if err := json.Unmarshal(b, &config); err != nil {
+		return config, fmt.Errorf("storage: reading %s: %w", filepath.Join(dir, collectionConfig), err)
+	}
+	return config, nil
+}
+
+func writeCollectionConfig(dir string, config CollectionConfig) error {
+	b, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, collectionConfig), b, 0644)
+}
+
+func (c *collections) open(name string, dir string, config CollectionConfig) error {
+	if err := config.check(); err != nil {
+		return err
+	}
+
+	a, words, err := config.analyzer()
+	if err != nil {
+		return err
+	}
+
+	db, err := Open(dir, a, config.Schema, config.Vector, c.logger)
+	if err != nil {
+		return err
+	}
+
+	c.byName[name] = &collection{config: config, dir: dir, db: db, words: words}
+	return nil
+}
+
+// create makes a collection and writes its config, last, so a collection
+// only half created is not reopened.
+func (c *collections) create(name string, config CollectionConfig) error {
+	if !collectionName.MatchString(name) {
+		return fmt.Errorf("%w: %q is not a lowercase name of letters, digits, - and _", ErrInvalidCollection, name)
+	}
+
+	if err := config.check(); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.byName[name]; ok {
+		return fmt.Errorf("%w: %q", ErrCollectionExists, name)
+	}
+
+	dir := filepath.Join(c.dataDir, collectionsPath, name)
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+
+	if err := c.open(name, dir, config); err != nil {
+		return err
+	}
+	return writeCollectionConfig(dir, config)
+}
+
+// delete removes a collection and its config at once, moving its segments
+// aside; they are removed once the searches still reading them are done.
+func (c *collections) delete(name string) error {
+	if name == DefaultCollection {
+		return fmt.Errorf("%w: the default collection cannot be deleted", ErrInvalidCollection)
+	}
+
+	c.mu.Lock()
+	col, ok := c.byName[name]
+	delete(c.byName, name)
+	c.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrCollectionNotFound, name)
+	}
+
+	if err := os.Remove(filepath.Join(col.dir, collectionConfig)); err != nil {
+		return err
+	}
+
+	if err := c.bury(name, col); err != nil {
+		return err
+	}
+	return os.RemoveAll(col.dir)
+}
+
+// bury empties a collection and moves its segments aside, since a collection
+// of the same name may be created before the searches reading them finish.
+// They are removed once those searches are done.
+func (c *collections) bury(name string, col *collection) error {
+	col.db.drop()
+
+	tombstone := filepath.Join(c.dataDir, collectionsPath, fmt.Sprintf(".%s-%d", name, time.Now().UnixNano()))
+	if err := os.MkdirAll(tombstone, 0755); err != nil {
+		return err
+	}
+
+	for _, path := range []string{InvertedIndexSegmentPath, VectorIndexSegmentPath} {
+		err := os.Rename(filepath.Join(col.dir, path), filepath.Join(tombstone, path))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	go func() {
+		if err := col.db.Close(); err != nil {
+			c.logger.Error("storage: closing deleted collection", slog.String("collection", name), slog.String("error", err.Error()))
+		}
+		if err := os.RemoveAll(tombstone); err != nil {
+			c.logger.Error("storage: removing deleted collection", slog.String("collection", name), slog.String("error", err.Error()))
+		}
+	}()
+	return nil
+}
+
+// setWords replaces the words of the stop or keyword_marker filter of a
+// collection, writing them to its config first so they are kept on restart.
+// Documents already indexed keep the terms they were indexed with.
+func (c *collections) setWords(name, filter string, words []string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	col, ok := c.byName[name]
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrCollectionNotFound, name)
+	}
+
+	list, ok := col.words[filter]
+	if !ok {
+		return fmt.Errorf("%w: collection %q has no stop or keyword_marker filter %q", analyzer.ErrUnknownWordList, name, filter)
+	}
+
+	config := col.config
+	config.Words = map[string][]string{}
+	for f, w := range col.config.Words {
+		config.Words[f] = w
+	}
+	config.Words[filter] = words
+
+	saved := config
+	if name == DefaultCollection {
+		// the rest of its config comes from startup
+		saved = CollectionConfig{Words: config.Words}
+	}
+	if err := writeCollectionConfig(col.dir, saved); err != nil {
+		return err
+	}
+
+	col.config = config
+	list.Set(words)
+	return nil
+}
+
+// words returns the words of the stop or keyword_marker filter of a
+// collection.
+func (c *collections) words(name, filter string) ([]string, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	col, ok := c.byName[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrCollectionNotFound, name)
+	}
+
+	list, ok := col.words[filter]
+	if !ok {
+		return nil, fmt.Errorf("%w: collection %q has no stop or keyword_marker filter %q", analyzer.ErrUnknownWordList, name, filter)
+	}
+	return list.Words(), nil
+}
+
+func (c *collections) get(name string) (*IndexStorage, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	col, ok := c.byName[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrCollectionNotFound, name)
+	}
+	return col.db, nil
+}
+
+// list describes the collections in order of name.
+func (c *collections) list() []Collection {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	list := make([]Collection, 0, len(c.byName))
+	for name, col := range c.byName {
+		list = append(list, Collection{Name: name, CollectionConfig: col.config})
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+// each calls fn with the storage of every collection until it returns an
+// error.
+func (c *collections) each(fn func(name string, db *IndexStorage) error) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for name, col := range c.byName {
+		if err := fn(name, col.db); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/farouqzaib/fast-search/internal/analyzer"
+	"github.com/farouqzaib/fast-search/internal/index"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollections(t *testing.T) {
+	dir := t.TempDir()
+
+	c, err := openCollections(dir, CollectionConfig{}, slog.Default())
+	require.NoError(t, err)
+
+	schema := &index.Schema{Fields: map[string]index.Field{"tags": {Type: index.KeywordField}}}
+	config := CollectionConfig{Analyzer: "standard", Schema: schema, Vector: VectorConfig{M: 16}}
+	require.NoError(t, c.create("articles", config))
+
+	for _, bad := range []struct {
+		name   string
+		config CollectionConfig
+		err    error
+	}{
+		{"articles", CollectionConfig{}, ErrCollectionExists},
+		{"default", CollectionConfig{}, ErrCollectionExists},
+		{"Articles", CollectionConfig{}, ErrInvalidCollection},
+		{"../articles", CollectionConfig{}, ErrInvalidCollection},
+		{"notes", CollectionConfig{Analyzer: "missing"}, ErrInvalidCollection},
+		{"notes", CollectionConfig{Vector: VectorConfig{M: -1}}, ErrInvalidCollection},
+		{"notes", CollectionConfig{Schema: &index.Schema{Fields: map[string]index.Field{"text": {Type: index.TextField}}}}, ErrInvalidCollection},
+	} {
+		if err := c.create(bad.name, bad.config); !errors.Is(err, bad.err) {
+			t.Fatalf("%s: expected %v, got %v", bad.name, bad.err, err)
+		}
+	}
+
+	articles, err := c.get("articles")
+	require.NoError(t, err)
+	require.Equal(t, "standard", articles.Analyzer().Name)
+	require.Equal(t, 16, articles.vector.newHNSW().M)
+
+	list := c.list()
+	require.Len(t, list, 2)
+	require.Equal(t, "articles", list[0].Name)
+	require.Equal(t, DefaultCollection, list[1].Name)
+
+	// collections are reopened with their config
+	require.NoError(t, c.each(func(name string, db *IndexStorage) error { return db.Close() }))
+	c, err = openCollections(dir, CollectionConfig{}, slog.Default())
+	require.NoError(t, err)
+
+	articles, err = c.get("articles")
+	require.NoError(t, err)
+	if _, ok := articles.Schema().Field("tags"); !ok {
+		t.Fatal("expected the collection to keep its schema")
+	}
+
+	require.NoError(t, c.delete("articles"))
+	if _, err := c.get("articles"); !errors.Is(err, ErrCollectionNotFound) {
+		t.Fatalf("expected the collection deleted, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, collectionsPath, "articles")); !os.IsNotExist(err) {
+		t.Fatalf("expected the segments removed, got %v", err)
+	}
+
+	require.ErrorIs(t, c.delete("articles"), ErrCollectionNotFound)
+	require.ErrorIs(t, c.delete(DefaultCollection), ErrInvalidCollection)
+}
+
+func TestCollectionWords(t *testing.T) {
+	require.NoError(t, analyzer.DefineFilter("collection_test_stop", analyzer.ComponentDefinition{Type: "stop", Words: []string{"the"}}))
+	require.NoError(t, analyzer.Define("collection_test", analyzer.Definition{Tokenizer: "standard", Filters: []string{"lowercase", "collection_test_stop"}}))
+
+	dir := t.TempDir()
+	config := CollectionConfig{Analyzer: "collection_test"}
+	c, err := openCollections(dir, config, slog.Default())
+	require.NoError(t, err)
+	require.NoError(t, c.create("articles", config))
+	require.NoError(t, c.create("notes", config))
+
+	require.ErrorIs(t, c.create("books", CollectionConfig{Analyzer: "collection_test", Words: map[string][]string{"lowercase": nil}}), ErrInvalidCollection)
+	require.ErrorIs(t, c.setWords("articles", "lowercase", nil), analyzer.ErrUnknownWordList)
+	require.ErrorIs(t, c.setWords("books", "collection_test_stop", nil), ErrCollectionNotFound)
+
+	require.NoError(t, c.setWords("articles", "collection_test_stop", []string{"Raft"}))
+	require.NoError(t, c.setWords(DefaultCollection, "collection_test_stop", []string{"log"}))
+
+	terms := func(name string) []string {
+		db, err := c.get(name)
+		require.NoError(t, err)
+		return db.Analyzer().Terms("the raft log")
+	}
+	check := func() {
+		require.Equal(t, []string{"the", "log"}, terms("articles"))
+		require.Equal(t, []string{"raft", "log"}, terms("notes"))
+		require.Equal(t, []string{"the", "raft"}, terms(DefaultCollection))
+
+		words, err := c.words("articles", "collection_test_stop")
+		require.NoError(t, err)
+		require.Equal(t, []string{"raft"}, words)
+	}
+	check()
+
+	// segments are searched with the words of their collection
+	articles, err := c.get("articles")
+	require.NoError(t, err)
+	require.NoError(t, articles.memtables.mutable.inMemoryInvertedIndex.IndexDocument(1, index.Document{Text: "raft log"}))
+	articles.rotateMemtables()
+	require.NoError(t, articles.FlushMemtables())
+	v := articles.acquire()
+	require.Same(t, articles.Analyzer(), v.segments[0].invertedIndex.Analyzer())
+	v.release()
+
+	// the words are kept on restart, the default collection's too
+	require.NoError(t, c.each(func(name string, db *IndexStorage) error { return db.Close() }))
+	c, err = openCollections(dir, config, slog.Default())
+	require.NoError(t, err)
+	check()
+}
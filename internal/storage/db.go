@@ -2,13 +2,13 @@ package storage
 
 import (
 	"errors"
-	"io"
 	"log"
 	"log/slog"
 	"math"
-	"os"
 	"sort"
+	"sync"
 
+	"github.com/farouqzaib/fast-search/internal/analyzer"
 	"github.com/farouqzaib/fast-search/internal/index"
 )
 
@@ -21,47 +21,121 @@ const (
 )
 
 type IndexStorage struct {
+	analyzer    *analyzer.Analyzer
+	schema      *index.Schema
+	vector      VectorConfig
 	dataStorage *Provider
 	memtables   struct {
 		mutable *Memtable
 		queue   []*Memtable
 	}
-	segments                   []*FileMetadata
-	invertedIndexSegmentReader []*os.File
-	vectorIndexSegmentReader   []*os.File
-	inMemorySegments           []index.InvertedIndex
-	inMemoryVectorSegments     []index.HNSW
-	logger                     *slog.Logger
+	segments []*Segment
+	logger   *slog.Logger
+
+	// mu serializes writers, the only ones to touch memtables and segments
+	// above. They publish what they change as a new view, which searches
+	// acquire under viewMu.
+	mu     sync.Mutex
+	viewMu sync.Mutex
+	view   *view
+
+	// open counts the segments not yet closed, and closeErr is the first
+	// error closing one.
+	open     sync.WaitGroup
+	errMu    sync.Mutex
+	closeErr error
 }
 
-func Open(dirname string, logger *slog.Logger) (*IndexStorage, error) {
+// Open loads the segments under dirname. New documents are analyzed with a,
+// have the fields schema declares and are embedded into graphs shaped by
+// vector; segments keep the analyzer, schema and graphs they were written with.
+func Open(dirname string, a *analyzer.Analyzer, schema *index.Schema, vector VectorConfig, logger *slog.Logger) (*IndexStorage, error) {
 	dataStorage, err := NewProvider(dirname)
 	if err != nil {
 		return nil, err
 	}
 
-	db := &IndexStorage{dataStorage: dataStorage, logger: logger}
+	db := &IndexStorage{analyzer: a, schema: schema, vector: vector, dataStorage: dataStorage, logger: logger}
 	err = db.loadSegments()
 	if err != nil {
 		return nil, err
 	}
-	db.memtables.mutable = NewMemtable(memtableSizeLimit, logger)
+	db.memtables.mutable = NewMemtable(memtableSizeLimit, a, schema, vector, logger)
 	db.memtables.queue = append(db.memtables.queue, db.memtables.mutable)
+	db.publish()
 
 	return db, nil
 }
 
-func (d *IndexStorage) BulkIndex(docIDs []float64, documents []string) error {
+// acquire returns the current view, which the caller must release.
+func (d *IndexStorage) acquire() *view {
+	d.viewMu.Lock()
+	defer d.viewMu.Unlock()
+
+	d.view.ref()
+	return d.view
+}
+
+// publish makes the memtables and segments writers left the view new
+// searches acquire, releasing the previous one. Searches still holding it
+// keep reading what it held.
+func (d *IndexStorage) publish() {
+	v := newView(d.memtables.queue, d.segments)
+
+	d.viewMu.Lock()
+	old := d.view
+	d.view = v
+	d.viewMu.Unlock()
+
+	if old != nil {
+		old.release()
+	}
+}
+
+// addSegment adds a segment opened from disk to the storage, to be searched
+// once published.
+func (d *IndexStorage) addSegment(s *Segment) {
+	d.open.Add(1)
+	s.closed = func(err error) {
+		d.errMu.Lock()
+		if d.closeErr == nil {
+			d.closeErr = err
+		}
+		d.errMu.Unlock()
+		d.open.Done()
+	}
+	d.segments = append(d.segments, s)
+}
+
+func (d *IndexStorage) BulkIndex(docIDs []float64, documents []index.Document) error {
+	for _, document := range documents {
+		if err := d.schema.Validate(document.Fields); err != nil {
+			return err
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
 	//ASSUME MEMTABLE CAN FIT THIS REQUEST
 	m := d.memtables.mutable
 	m.BulkIndex(docIDs, documents)
 	return nil
 }
 
-func (d *IndexStorage) Index(docID int, document string) error {
+// Index indexes a document, its text and text fields written in its
+// language, or in the storage's analyzer when it has none.
+func (d *IndexStorage) Index(docID int, document index.Document) error {
+	if err := d.schema.Validate(document.Fields); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
 	l := d.memtables.mutable.sizeUsed
-	needed := []byte(document)
-	if l+len(needed) > memtableFlushThreshold {
+	needed := documentSize(document)
+	if l+needed > memtableFlushThreshold {
 		return errors.New("file too large to be indexed")
 	}
 
@@ -83,52 +157,189 @@ func (d *IndexStorage) Index(docID int, document string) error {
 			d.memtables.queue = d.memtables.queue[:len(d.memtables.queue)-1]
 		}
 
-		d.memtables.mutable = NewMemtable(memtableSizeLimit, d.logger)
+		d.memtables.mutable = NewMemtable(memtableSizeLimit, d.analyzer, d.schema, d.vector, d.logger)
 		d.memtables.queue = append(d.memtables.queue, d.memtables.mutable)
+		d.publish()
 	}
 
 	return nil
 }
 
 func (d *IndexStorage) rotateMemtables() *Memtable {
-	d.memtables.mutable = NewMemtable(memtableSizeLimit, d.logger)
+	d.memtables.mutable = NewMemtable(memtableSizeLimit, d.analyzer, d.schema, d.vector, d.logger)
 	d.memtables.queue = append(d.memtables.queue, d.memtables.mutable)
+	d.publish()
 	return d.memtables.mutable
 }
 
-func (d *IndexStorage) Get(query string, k int) []index.Match {
+// Get returns the k best matches of q across every memtable and segment, or
+// the first k after its cursor if it is sorted, along with the partial
+// results of its aggregations, computed in each of them from the same
+// matches and merged.
+func (d *IndexStorage) Get(q index.Query, k int) ([]index.Match, index.Aggregations) {
+	type result struct {
+		matches []index.Match
+		aggs    index.Aggregations
+	}
+
+	v := d.acquire()
+	defer v.release()
+
 	matches := []index.Match{}
-	matchesCh := make(chan []index.Match, len(d.segments))
+	aggs := index.Aggregations{}
+	resultsCh := make(chan result, len(v.segments))
 
-	for i := len(d.memtables.queue) - 1; i >= 0; i-- {
-		m := d.memtables.queue[i]
+	for i := len(v.memtables) - 1; i >= 0; i-- {
+		m := v.memtables[i]
 
-		val := m.Get(query, k)
+		val, valAggs := m.Get(q, k)
 
 		matches = append(matches, val...)
+		aggs.Merge(valAggs)
 	}
 
-	for j := len(d.segments) - 1; j >= 0; j-- {
+	for j := len(v.segments) - 1; j >= 0; j-- {
 		go func(j int) {
+			s := v.segments[j]
+			h := index.NewHybridSearch(s.invertedIndex, s.vectorIndex, d.logger, index.GetEmbedding)
 
-			h := index.NewHybridSearch(&d.inMemorySegments[j], &d.inMemoryVectorSegments[j], d.logger, index.GetEmbedding)
-
-			val := h.Search(query, k)
-			matchesCh <- val
+			val, valAggs := h.SearchAggregate(q, k)
+			resultsCh <- result{matches: val, aggs: valAggs}
 		}(j)
 	}
 
-	for j := len(d.segments) - 1; j >= 0; j-- {
-		r := <-matchesCh
-		matches = append(matches, r...)
+	for j := len(v.segments) - 1; j >= 0; j-- {
+		r := <-resultsCh
+		matches = append(matches, r.matches...)
+		aggs.Merge(r.aggs)
 	}
 
-	sort.Slice(matches, func(i, j int) bool {
-		return matches[i].Score > matches[j].Score
-	})
+	if q.Sorted() {
+		index.SortMatches(q, matches)
+	} else {
+		sort.Slice(matches, func(i, j int) bool {
+			return matches[i].Score > matches[j].Score
+		})
+	}
 
 	k = int(math.Min(float64(k), float64(len(matches))))
-	return matches[:k]
+	return matches[:k], aggs
+}
+
+// Scroll calls fn with the ID of every document of q, as index.Documents
+// finds them, across the memtables and segments of the view it acquires when
+// called, so documents indexed or flushed meanwhile are left out. A document
+// held by several of them is visited once. An error from fn stops the scroll
+// and is returned.
+func (d *IndexStorage) Scroll(q index.Query, fn func(docID int) error) error {
+	v := d.acquire()
+	defer v.release()
+
+	// memtables keep changing, so their documents are taken up front; the
+	// view's segments never do
+	docs := [][]float64{}
+	for i := len(v.memtables) - 1; i >= 0; i-- {
+		docs = append(docs, index.Documents(v.memtables[i].inMemoryInvertedIndex, q))
+	}
+	segments := v.segments
+
+	seen := map[float64]bool{}
+	visit := func(ids []float64) error {
+		for _, id := range ids {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+
+			if err := fn(int(id)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, ids := range docs {
+		if err := visit(ids); err != nil {
+			return err
+		}
+	}
+
+	for j := len(segments) - 1; j >= 0; j-- {
+		if err := visit(index.Documents(segments[j].invertedIndex, q)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// termReaders returns the fields queries search of the inverted index of
+// every memtable and segment of v.
+func termReaders(v *view) []index.TermReader {
+	readers := []index.TermReader{}
+	for _, m := range v.memtables {
+		readers = append(readers, index.SearchFields(m.inMemoryInvertedIndex)...)
+	}
+
+	for _, s := range v.segments {
+		readers = append(readers, index.SearchFields(s.invertedIndex)...)
+	}
+	return readers
+}
+
+// completionReaders returns the inverted index of every memtable and segment
+// of v, for their completion vocabularies.
+func completionReaders(v *view) []index.CompletionReader {
+	readers := []index.CompletionReader{}
+	for _, m := range v.memtables {
+		readers = append(readers, m.inMemoryInvertedIndex)
+	}
+
+	for _, s := range v.segments {
+		readers = append(readers, s.invertedIndex)
+	}
+	return readers
+}
+
+// Suggest returns up to n corrections of a query, drawn from the words of
+// every memtable and segment.
+func (d *IndexStorage) Suggest(text string, n int) []string {
+	v := d.acquire()
+	defer v.release()
+
+	return index.NewSuggester(d.analyzer, termReaders(v), completionReaders(v)).Suggest(text, n)
+}
+
+// Analyzer returns the analyzer new documents are analyzed with.
+func (d *IndexStorage) Analyzer() *analyzer.Analyzer {
+	return d.analyzer
+}
+
+// Schema returns the schema of new documents.
+func (d *IndexStorage) Schema() *index.Schema {
+	return d.schema
+}
+
+// DocumentFrequency returns the number of documents term occurs in across
+// every memtable and segment, counting a document once per field it occurs
+// in.
+func (d *IndexStorage) DocumentFrequency(term string) int {
+	v := d.acquire()
+	defer v.release()
+
+	df := 0
+	for _, r := range termReaders(v) {
+		df += r.DocumentFrequency(term)
+	}
+	return df
+}
+
+// Complete returns the n most frequent completions of prefix across every
+// memtable and segment.
+func (d *IndexStorage) Complete(prefix string, n int) []index.Completion {
+	v := d.acquire()
+	defer v.release()
+
+	return index.Complete(completionReaders(v), prefix, n)
 }
 
 func (d *IndexStorage) maybeScheduleFlush() {
@@ -143,14 +354,28 @@ func (d *IndexStorage) maybeScheduleFlush() {
 	}
 
 	slog.Info("total size to flush", slog.Int("size", totalSize))
-	err := d.FlushMemtables()
+	err := d.flushMemtables()
 	if err != nil {
 		log.Fatal(err)
 	}
 }
 
+// FlushMemtables writes every memtable but the mutable one, or the mutable
+// one if it is alone, to a segment, publishing each segment in place of its
+// memtable once it is written.
 func (d *IndexStorage) FlushMemtables() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.flushMemtables()
+}
+
+func (d *IndexStorage) flushMemtables() error {
 	slog.Info("flushing memtables")
+	if len(d.memtables.queue) == 0 {
+		return nil
+	}
+
 	n := len(d.memtables.queue) - 1
 
 	if len(d.memtables.queue) == 1 {
@@ -158,34 +383,30 @@ func (d *IndexStorage) FlushMemtables() error {
 	}
 
 	flushable := d.memtables.queue[:n]
-	d.memtables.queue = d.memtables.queue[n:]
 
 	for i := 0; i < len(flushable); i++ {
 		meta := d.dataStorage.PrepareNewFile()
 
-		err := d.writeSegment(flushable[i].inMemoryInvertedIndex.Encode(), meta, InvertedIndexSegmentPath)
+		err := writeSegment(d.dataStorage, flushable[i].inMemoryInvertedIndex.Encode(), meta, InvertedIndexSegmentPath)
 		if err != nil {
 			return err
 		}
-		err = d.writeSegment(flushable[i].inMemoryVectorIndex.Encode(), meta, VectorIndexSegmentPath)
+		err = writeSegment(d.dataStorage, flushable[i].inMemoryVectorIndex.Encode(), meta, VectorIndexSegmentPath)
 		if err != nil {
 			return err
 		}
 
-		d.segments = append(d.segments, meta)
-	}
-	return nil
-}
+		segment, err := openSegment(d.dataStorage, meta, d.analyzer)
+		if err != nil {
+			return err
+		}
 
-func (d *IndexStorage) Reader() []io.Reader {
-	invertedIndexReaders := make([]io.Reader, len(d.invertedIndexSegmentReader))
-	vectorIndexReaders := make([]io.Reader, len(d.vectorIndexSegmentReader))
-	for i, reader := range d.invertedIndexSegmentReader {
-		invertedIndexReaders = append(invertedIndexReaders, reader)
-		vectorIndexReaders = append(vectorIndexReaders, d.vectorIndexSegmentReader[i])
+		// the memtable and its segment are swapped in the same view
+		d.memtables.queue = d.memtables.queue[1:]
+		d.addSegment(segment)
+		d.publish()
 	}
-
-	return []io.Reader{io.MultiReader(invertedIndexReaders...), io.MultiReader(vectorIndexReaders...)}
+	return nil
 }
 
 func (d *IndexStorage) loadSegments() error {
@@ -200,43 +421,21 @@ func (d *IndexStorage) loadSegments() error {
 			continue
 		}
 
-		reader, err := d.dataStorage.OpenFileForReading(f, InvertedIndexSegmentPath)
+		segment, err := openSegment(d.dataStorage, f, d.analyzer)
 		if err != nil {
 			return err
 		}
-		r := NewReader(reader)
 
-		d.segments = append(d.segments, f)
+		d.addSegment(segment)
 		d.dataStorage.fileNum = f.fileNum
-		d.invertedIndexSegmentReader = append(d.invertedIndexSegmentReader, reader)
-
-		invertedIndex, err := r.loadInvertedIndex()
-		if err != nil {
-			return err
-		}
-		d.inMemorySegments = append(d.inMemorySegments, invertedIndex)
-
-		reader, err = d.dataStorage.OpenFileForReading(f, VectorIndexSegmentPath)
-		if err != nil {
-			return err
-		}
-		r = NewReader(reader)
-
-		d.vectorIndexSegmentReader = append(d.vectorIndexSegmentReader, reader)
-
-		vectorIndex, err := r.loadVectorIndex()
-		if err != nil {
-			return err
-		}
-
-		d.inMemoryVectorSegments = append(d.inMemoryVectorSegments, vectorIndex)
 	}
 
 	return nil
 }
 
-func (d *IndexStorage) writeSegment(b []byte, meta *FileMetadata, indexType string) error {
-	f, err := d.dataStorage.OpenFileForWriting(meta, indexType)
+// writeSegment writes the indexType file of the segment meta.
+func writeSegment(dataStorage *Provider, b []byte, meta *FileMetadata, indexType string) error {
+	f, err := dataStorage.OpenFileForWriting(meta, indexType)
 	if err != nil {
 		return err
 	}
@@ -253,3 +452,27 @@ func (d *IndexStorage) writeSegment(b []byte, meta *FileMetadata, indexType stri
 
 	return err
 }
+
+// Close empties the storage, so searches find nothing from then on, and
+// waits for the searches still reading its segments to release them before
+// returning. The storage must not be written to afterwards.
+func (d *IndexStorage) Close() error {
+	d.drop()
+	d.open.Wait()
+
+	d.errMu.Lock()
+	defer d.errMu.Unlock()
+	return d.closeErr
+}
+
+// drop empties the storage once writers in flight are done, publishing a
+// view of nothing. Its segments are closed as the searches reading them
+// finish.
+func (d *IndexStorage) drop() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.memtables.queue = nil
+	d.segments = nil
+	d.publish()
+}
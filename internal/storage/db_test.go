@@ -5,10 +5,14 @@ import (
 	"log"
 	"log/slog"
 	"testing"
+
+	"github.com/farouqzaib/fast-search/internal/analyzer"
+	"github.com/farouqzaib/fast-search/internal/index"
+	"github.com/stretchr/testify/require"
 )
 
 func TestDB(t *testing.T) {
-	d, err := Open("demo-vector", slog.Default())
+	d, err := Open("demo-vector", analyzer.Default(), nil, DefaultVectorConfig, slog.Default())
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -20,5 +24,49 @@ func TestDB(t *testing.T) {
 
 	// fmt.Println(d.memtables.mutable.sizeUsed)
 
-	fmt.Println(d.Get("years of experience", 10))
+	fmt.Println(d.Get(index.Query{Text: "years of experience"}, 10))
+}
+
+func TestScroll(t *testing.T) {
+	d, err := Open(t.TempDir(), analyzer.Default(), nil, DefaultVectorConfig, slog.Default())
+	require.NoError(t, err)
+	defer d.Close()
+
+	// documents go straight into the inverted indexes, which need no
+	// embeddings
+	add := func(docID int, text string) {
+		require.NoError(t, d.memtables.mutable.inMemoryInvertedIndex.IndexDocument(docID, index.Document{Text: text}))
+	}
+
+	add(1, "raft leader")
+	add(2, "gossip protocol")
+	require.NoError(t, d.FlushMemtables())
+	d.rotateMemtables()
+	add(3, "raft election")
+
+	scroll := func(q index.Query) []int {
+		ids := []int{}
+		require.NoError(t, d.Scroll(q, func(docID int) error {
+			ids = append(ids, docID)
+			return nil
+		}))
+		return ids
+	}
+
+	require.Equal(t, []int{3, 1, 2}, scroll(index.Query{}))
+	require.Equal(t, []int{3, 1}, scroll(index.Query{Text: "raft"}))
+
+	// documents indexed and flushed mid-scroll are left out
+	ids := []int{}
+	require.NoError(t, d.Scroll(index.Query{}, func(docID int) error {
+		if len(ids) == 0 {
+			d.rotateMemtables()
+			add(4, "paxos")
+			require.NoError(t, d.FlushMemtables())
+		}
+		ids = append(ids, docID)
+		return nil
+	}))
+	require.Equal(t, []int{3, 1, 2}, ids)
+	require.Equal(t, []int{4, 3, 1, 2}, scroll(index.Query{}))
 }
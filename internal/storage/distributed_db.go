@@ -3,7 +3,6 @@ package storage
 import (
 	"encoding/json"
 	"fmt"
-	"io"
 	"log/slog"
 	"net"
 	"os"
@@ -16,10 +15,10 @@ import (
 )
 
 type DistributedDB struct {
-	DB     *IndexStorage
-	raft   *raft.Raft
-	config Config
-	logger *slog.Logger
+	collections *collections
+	raft        *raft.Raft
+	config      Config
+	logger      *slog.Logger
 }
 
 func NewDistributedDB(dataDir string, config Config, logger *slog.Logger) (*DistributedDB, error) {
@@ -39,18 +38,22 @@ func NewDistributedDB(dataDir string, config Config, logger *slog.Logger) (*Dist
 }
 
 func (d *DistributedDB) setupIndex(dataDir string) error {
-	db, err := Open(dataDir, d.logger)
+	c, err := openCollections(dataDir, CollectionConfig{
+		Analyzer: d.config.Analyzer,
+		Schema:   d.config.Schema,
+		Vector:   d.config.Vector,
+	}, d.logger)
 	if err != nil {
 		return err
 	}
 
-	d.DB = db
+	d.collections = c
 
-	return err
+	return nil
 }
 
 func (d *DistributedDB) setupRaft(dataDir string) error {
-	fsm := &fsm{db: d.DB}
+	fsm := &fsm{collections: d.collections}
 
 	logDir := filepath.Join(dataDir, "raft", "log")
 	if err := os.MkdirAll(logDir, 0755); err != nil {
@@ -144,14 +147,85 @@ type Config struct {
 	}
 	Addr    string
 	RaftDir string
+	// Analyzer names the analyzer new documents and queries go through,
+	// analyzer.DefaultAnalyzer if empty.
+	Analyzer string
+	// Schema declares the fields of documents, which have only their text
+	// if nil.
+	Schema *index.Schema
+	// Vector shapes the graphs of semantic search.
+	//
+	// Analyzer, Schema and Vector configure the default collection; other
+	// collections are configured when created.
+	Vector VectorConfig
 }
 
-func (d *DistributedDB) Index(docId int, document string) error {
-	c := &command{
+// CreateCollection creates an empty collection on every node.
+func (d *DistributedDB) CreateCollection(name string, config CollectionConfig) error {
+	return d.apply(&command{
+		Op:   "createCollection",
+		Data: map[string]interface{}{"collection": name, "config": config},
+	})
+}
+
+// DeleteCollection deletes a collection and its segments on every node.
+func (d *DistributedDB) DeleteCollection(name string) error {
+	return d.apply(&command{
+		Op:   "deleteCollection",
+		Data: map[string]interface{}{"collection": name},
+	})
+}
+
+// SetWords replaces the words of the stop or keyword_marker filter of a
+// collection on every node.
+func (d *DistributedDB) SetWords(collection, filter string, words []string) error {
+	return d.apply(&command{
+		Op:   "setWords",
+		Data: map[string]interface{}{"collection": collection, "filter": filter, "words": words},
+	})
+}
+
+// Words returns the words of the stop or keyword_marker filter of the local
+// replica of a collection.
+func (d *DistributedDB) Words(collection, filter string) ([]string, error) {
+	return d.collections.words(collection, filter)
+}
+
+// Collections describes the collections of the local replica.
+func (d *DistributedDB) Collections() []Collection {
+	return d.collections.list()
+}
+
+// Collection returns the local replica of a collection, which serves
+// suggestions, completions and the like; completions are built from the
+// documents applied through the index and bulkIndex commands.
+func (d *DistributedDB) Collection(name string) (*IndexStorage, error) {
+	return d.collections.get(name)
+}
+
+func (d *DistributedDB) Index(collection string, docId int, document index.Document) error {
+	return d.apply(&command{
 		Op:   "index",
-		Data: map[string]interface{}{"docId": docId, "document": document},
+		Data: map[string]interface{}{"collection": collection, "docId": docId, "document": document.Text, "fields": document.Fields, "language": document.Language},
+	})
+}
+
+func (d *DistributedDB) BulkIndex(collection string, docIds []int, documents []index.Document) error {
+	texts := make([]string, len(documents))
+	fields := make([]map[string]interface{}, len(documents))
+	languages := make([]string, len(documents))
+	for i, document := range documents {
+		texts[i], fields[i], languages[i] = document.Text, document.Fields, document.Language
 	}
 
+	return d.apply(&command{
+		Op:   "bulkIndex",
+		Data: map[string]interface{}{"collection": collection, "docIds": docIds, "documents": texts, "fields": fields, "languages": languages},
+	})
+}
+
+// apply replicates a command and returns the error applying it returned.
+func (d *DistributedDB) apply(c *command) error {
 	b, err := json.Marshal(c)
 	if err != nil {
 		return err
@@ -172,42 +246,68 @@ func (d *DistributedDB) Index(docId int, document string) error {
 	return nil
 }
 
-func (d *DistributedDB) BulkIndex(docIds []int, documents []string) error {
-	c := &command{
-		Op:   "bulkIndex",
-		Data: map[string]interface{}{"docIds": docIds, "documents": documents},
+// Search returns the best matches of q in a collection and the results of
+// its aggregations. Every node replicates every document, so the local
+// replica's are final; partial aggregations from shards would be merged
+// before they are finished.
+func (d *DistributedDB) Search(collection string, q index.Query, k int) ([]index.Match, index.Aggregations, error) {
+	if err := q.Validate(); err != nil {
+		return nil, nil, err
 	}
 
-	b, err := json.Marshal(c)
+	db, err := d.Collection(collection)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
-	timeout := 10 * time.Second
-	future := d.raft.Apply(b, timeout)
+	if err := q.ValidateFields(db.Schema()); err != nil {
+		return nil, nil, err
+	}
 
-	if future.Error() != nil {
-		return future.Error()
+	res, aggs := db.Get(q, k)
+	aggs.Finish(q)
+
+	return res, aggs, nil
+}
+
+// Scroll calls fn with the ID of every document of a collection that q
+// matches, or of every document for a query without text or filter, as of
+// when it is called.
+func (d *DistributedDB) Scroll(collection string, q index.Query, fn func(docID int) error) error {
+	if err := q.Validate(); err != nil {
+		return err
 	}
 
-	res := future.Response()
-	if err, ok := res.(error); ok {
+	db, err := d.Collection(collection)
+	if err != nil {
 		return err
 	}
 
-	return nil
+	if err := q.ValidateFields(db.Schema()); err != nil {
+		return err
+	}
+
+	return db.Scroll(q, fn)
 }
 
-func (d *DistributedDB) Search(query string, k int) ([]index.Match, error) {
-	res := d.DB.Get(query, 10)
+// Flush flushes the memtables of every collection to segments.
+func (d *DistributedDB) Flush() error {
+	return d.collections.each(func(name string, db *IndexStorage) error {
+		return db.FlushMemtables()
+	})
+}
 
-	return res, nil
+// Close closes every collection. It must not be searched afterwards.
+func (d *DistributedDB) Close() error {
+	return d.collections.each(func(name string, db *IndexStorage) error {
+		return db.Close()
+	})
 }
 
 func (d *DistributedDB) Join(nodeID, addr string) error {
 	configFuture := d.raft.GetConfiguration()
 	if err := configFuture.Error(); err != nil {
-		d.logger.Error("failed to get raft configuration: %v", err)
+		d.logger.Error("failed to get raft configuration", slog.String("error", err.Error()))
 		return err
 	}
 
@@ -256,7 +356,7 @@ func (d *DistributedDB) WaitForLeader(timeout time.Duration) error {
 var _ raft.FSM = (*fsm)(nil)
 
 type fsm struct {
-	db *IndexStorage
+	collections *collections
 }
 
 type command struct {
@@ -264,20 +364,57 @@ type command struct {
 	Data map[string]interface{} `json:"data,omitempty"`
 }
 
+// collection returns the collection a command is for; commands logged before
+// there were collections are for the default collection.
+func (c command) collection() string {
+	if name, ok := c.Data["collection"].(string); ok {
+		return name
+	}
+	return DefaultCollection
+}
+
 func (f *fsm) Apply(b *raft.Log) interface{} {
 	var c command
 	if err := json.Unmarshal(b.Data, &c); err != nil {
 		panic(fmt.Sprintf("failed to unmarshal command: %s", err.Error()))
 	}
 
+	switch c.Op {
+	case "createCollection":
+		var config CollectionConfig
+		raw, _ := json.Marshal(c.Data["config"])
+		if err := json.Unmarshal(raw, &config); err != nil {
+			return err
+		}
+		return f.collections.create(c.collection(), config)
+	case "deleteCollection":
+		return f.collections.delete(c.collection())
+	case "setWords":
+		filter, _ := c.Data["filter"].(string)
+		words := []string{}
+		rawWords, _ := c.Data["words"].([]interface{})
+		for _, w := range rawWords {
+			words = append(words, w.(string))
+		}
+		return f.collections.setWords(c.collection(), filter, words)
+	}
+
+	db, err := f.collections.get(c.collection())
+	if err != nil {
+		return err
+	}
+
 	switch c.Op {
 	case "index":
 		docId := int(c.Data["docId"].(float64))
-		document := c.Data["document"].(string)
-		return f.applyIndex(docId, document)
+		document := index.Document{Text: c.Data["document"].(string)}
+		// entries logged before languages or fields were recorded have none
+		document.Language, _ = c.Data["language"].(string)
+		document.Fields, _ = c.Data["fields"].(map[string]interface{})
+		return applyIndex(db, docId, document)
 	case "search":
 		query := c.Data["query"].(string)
-		return f.applySearch(query)
+		return applySearch(db, query)
 	case "bulkIndex":
 		rawDocIds := c.Data["docIds"].([]interface{})
 
@@ -287,19 +424,31 @@ func (f *fsm) Apply(b *raft.Log) interface{} {
 			docIds = append(docIds, d.(float64))
 		}
 
-		documents := []string{}
+		documents := []index.Document{}
 		rawDocuments := c.Data["documents"].([]interface{})
 		for _, d := range rawDocuments {
-			documents = append(documents, d.(string))
+			documents = append(documents, index.Document{Text: d.(string)})
 		}
-		return f.applyBulkIndex(docIds, documents)
+
+		if rawLanguages, ok := c.Data["languages"].([]interface{}); ok {
+			for i, l := range rawLanguages {
+				documents[i].Language = l.(string)
+			}
+		}
+
+		if rawFields, ok := c.Data["fields"].([]interface{}); ok {
+			for i, fields := range rawFields {
+				documents[i].Fields, _ = fields.(map[string]interface{})
+			}
+		}
+		return applyBulkIndex(db, docIds, documents)
 	default:
 		panic(fmt.Sprintf("unrecognized command op: %s", c.Op))
 	}
 }
 
-func (f *fsm) applyBulkIndex(docIds []float64, documents []string) interface{} {
-	err := f.db.BulkIndex(docIds, documents)
+func applyBulkIndex(db *IndexStorage, docIds []float64, documents []index.Document) interface{} {
+	err := db.BulkIndex(docIds, documents)
 	if err != nil {
 		return err
 	}
@@ -307,8 +456,8 @@ func (f *fsm) applyBulkIndex(docIds []float64, documents []string) interface{} {
 	return nil
 }
 
-func (f *fsm) applyIndex(docId int, document string) interface{} {
-	err := f.db.Index(docId, document)
+func applyIndex(db *IndexStorage, docId int, document index.Document) interface{} {
+	err := db.Index(docId, document)
 	if err != nil {
 		return err
 	}
@@ -316,31 +465,8 @@ func (f *fsm) applyIndex(docId int, document string) interface{} {
 	return nil
 }
 
-func (f *fsm) applySearch(query string) interface{} {
-	res := f.db.Get(query, 10)
+func applySearch(db *IndexStorage, query string) interface{} {
+	res, _ := db.Get(index.Query{Text: query}, 10)
 
 	return res
 }
-
-func (f *fsm) Snapshot() (raft.FSMSnapshot, error) {
-	r := f.db.Reader()
-	return &snapshot{invertedIndexReader: r[0], vectorIndexReader: r[1]}, nil
-}
-
-func (f *fsm) Restore(r io.ReadCloser) error { return nil }
-
-type snapshot struct {
-	invertedIndexReader io.Reader
-	vectorIndexReader   io.Reader
-}
-
-func (s *snapshot) Persist(sink raft.SnapshotSink) error {
-	if _, err := io.Copy(sink, s.vectorIndexReader); err != nil {
-		_ = sink.Cancel()
-		return err
-	}
-
-	return sink.Close()
-}
-
-func (f *snapshot) Release() {}
@@ -8,6 +8,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/farouqzaib/fast-search/internal/index"
 	"github.com/hashicorp/raft"
 	"github.com/stretchr/testify/require"
 )
@@ -58,13 +59,13 @@ func TestDistributedDB(t *testing.T) {
 	documents := map[int]string{1: "still works", 8: "raft can be so much fun!"}
 
 	for k, v := range documents {
-		err := dbs[0].Index(k, v)
+		err := dbs[0].Index(DefaultCollection, k, index.Document{Text: v})
 		require.NoError(t, err)
 	}
 
 	require.Eventually(t, func() bool {
 		for j := 0; j < nodeCount; j++ {
-			got, err := dbs[j].Search("raft", 10)
+			got, _, err := dbs[j].Search(DefaultCollection, index.Query{Text: "raft"}, 10)
 			fmt.Println(got, err)
 		}
 		return true
@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/farouqzaib/fast-search/internal/index"
+)
+
+// StoredDocument is what DocumentMetadataBucket holds for every document ID.
+// Fields are only those the schema stores.
+type StoredDocument struct {
+	Text     string                 `json:"text"`
+	Fields   map[string]interface{} `json:"fields,omitempty"`
+	Language string                 `json:"language,omitempty"`
+}
+
+// storedDocumentMarker starts every document stored as JSON, telling it from
+// the bare text documents were stored as before, which may be valid JSON too.
+// Text does not start with a NUL byte.
+const storedDocumentMarker = "\x00\x01"
+
+func EncodeDocument(d StoredDocument) ([]byte, error) {
+	b, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(storedDocumentMarker), b...), nil
+}
+
+// DecodeDocument reads a stored document. Documents stored before they were
+// kept as JSON are their bare text, as is anything without the marker.
+func DecodeDocument(b []byte) StoredDocument {
+	if !bytes.HasPrefix(b, []byte(storedDocumentMarker)) {
+		return StoredDocument{Text: string(b)}
+	}
+
+	var d StoredDocument
+	if err := json.Unmarshal(b[len(storedDocumentMarker):], &d); err != nil {
+		return StoredDocument{Text: string(b[len(storedDocumentMarker):])}
+	}
+	return d
+}
+
+// documentSize is roughly how much room a document takes in a memtable: its
+// text and its fields as JSON.
+func documentSize(d index.Document) int {
+	n := len(d.Text)
+	if len(d.Fields) > 0 {
+		b, _ := json.Marshal(d.Fields)
+		n += len(b)
+	}
+	return n
+}
@@ -0,0 +1,19 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeDocument(t *testing.T) {
+	d := StoredDocument{Text: "raft leader", Fields: map[string]interface{}{"tags": "consensus"}, Language: "en"}
+	b, err := EncodeDocument(d)
+	require.NoError(t, err)
+	require.Equal(t, d, DecodeDocument(b))
+
+	// documents stored as bare text stay text, even when it is valid JSON
+	for _, legacy := range []string{"raft leader", "null", "123", `{"x":1}`, `{"text":"raft"}`} {
+		require.Equal(t, StoredDocument{Text: legacy}, DecodeDocument([]byte(legacy)))
+	}
+}
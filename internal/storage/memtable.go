@@ -3,6 +3,7 @@ package storage
 import (
 	"log/slog"
 
+	"github.com/farouqzaib/fast-search/internal/analyzer"
 	"github.com/farouqzaib/fast-search/internal/index"
 )
 
@@ -16,10 +17,10 @@ type Memtable struct {
 	logger                *slog.Logger
 }
 
-func NewMemtable(sizeLimit int, logger *slog.Logger) *Memtable {
+func NewMemtable(sizeLimit int, a *analyzer.Analyzer, schema *index.Schema, vector VectorConfig, logger *slog.Logger) *Memtable {
 	m := &Memtable{
-		inMemoryInvertedIndex: index.NewInvertedIndex(),
-		inMemoryVectorIndex:   index.NewHNSW(5, 0.62, 8, 16),
+		inMemoryInvertedIndex: index.NewInvertedIndexWithSchema(a, schema),
+		inMemoryVectorIndex:   vector.newHNSW(),
 		sizeLimit:             sizeLimit,
 		logger:                logger,
 	}
@@ -27,17 +28,19 @@ func NewMemtable(sizeLimit int, logger *slog.Logger) *Memtable {
 	return m
 }
 
-func (m *Memtable) HasRoomForWrite(data []byte) bool {
+// HasRoomForWrite reports whether the memtable has room for a document of
+// size bytes.
+func (m *Memtable) HasRoomForWrite(size int) bool {
 	l := len(m.inMemoryInvertedIndex.Encode())
 	l += len(m.inMemoryVectorIndex.Encode())
 
-	sizeNeeded := l + len(data)
+	sizeNeeded := l + size
 	sizeAvailable := m.sizeLimit - m.sizeUsed
 
 	return sizeNeeded <= sizeAvailable
 }
 
-func (m *Memtable) Index(docID int, document string) {
+func (m *Memtable) Index(docID int, document index.Document) {
 	h := index.NewHybridSearch(m.inMemoryInvertedIndex, m.inMemoryVectorIndex, m.logger, index.GetEmbedding)
 	err := h.Index(docID, document)
 
@@ -45,10 +48,10 @@ func (m *Memtable) Index(docID int, document string) {
 		panic(err)
 	}
 
-	m.sizeUsed = len([]byte(document))
+	m.sizeUsed = documentSize(document)
 }
 
-func (m *Memtable) BulkIndex(docIDs []float64, documents []string) {
+func (m *Memtable) BulkIndex(docIDs []float64, documents []index.Document) {
 	h := index.NewHybridSearch(m.inMemoryInvertedIndex, m.inMemoryVectorIndex, m.logger, index.GetEmbedding)
 	err := h.BulkIndex(docIDs, documents)
 
@@ -58,15 +61,22 @@ func (m *Memtable) BulkIndex(docIDs []float64, documents []string) {
 
 	l := 0
 	for _, document := range documents {
-		l += len([]byte(document))
+		l += documentSize(document)
 	}
 	m.sizeUsed = l
 }
 
-func (m *Memtable) Get(query string, k int) []index.Match {
+// Get returns the matches of q in the memtable as HybridSearch.SearchAggregate
+// does, along with its aggregations.
+func (m *Memtable) Get(q index.Query, k int) ([]index.Match, index.Aggregations) {
 	h := index.NewHybridSearch(m.inMemoryInvertedIndex, m.inMemoryVectorIndex, m.logger, index.GetEmbedding)
 
-	return h.Search(query, k)
+	return h.SearchAggregate(q, k)
+}
+
+// empty reports whether nothing has been indexed into the memtable.
+func (m *Memtable) empty() bool {
+	return !m.inMemoryInvertedIndex.Terms("", "").Next()
 }
 
 func (m *Memtable) Size() int {
@@ -97,6 +97,11 @@ func (s *Provider) generateFileName(fileNumber int) string {
 	return fmt.Sprintf("%06d.segment", fileNumber)
 }
 
+// path returns where the indexType file of the segment meta is kept.
+func (s *Provider) path(meta *FileMetadata, indexType string) string {
+	return filepath.Join(s.dataDir, indexType, s.generateFileName(meta.fileNum))
+}
+
 func (s *Provider) PrepareNewFile() *FileMetadata {
 	return &FileMetadata{
 		fileNum:  s.nextFileNum(),
@@ -106,8 +111,7 @@ func (s *Provider) PrepareNewFile() *FileMetadata {
 
 func (s *Provider) OpenFileForWriting(meta *FileMetadata, indexType string) (*os.File, error) {
 	const openFlags = os.O_RDWR | os.O_CREATE | os.O_EXCL
-	filename := s.generateFileName(meta.fileNum)
-	file, err := os.OpenFile(filepath.Join(s.dataDir, indexType, filename), openFlags, 0644)
+	file, err := os.OpenFile(s.path(meta, indexType), openFlags, 0644)
 	if err != nil {
 		return nil, err
 	}
@@ -117,8 +121,7 @@ func (s *Provider) OpenFileForWriting(meta *FileMetadata, indexType string) (*os
 
 func (s *Provider) OpenFileForReading(meta *FileMetadata, indexType string) (*os.File, error) {
 	const openFlags = os.O_RDONLY
-	filename := s.generateFileName(meta.fileNum)
-	file, err := os.OpenFile(filepath.Join(s.dataDir, indexType, filename), openFlags, 0)
+	file, err := os.OpenFile(s.path(meta, indexType), openFlags, 0)
 
 	if err != nil {
 		return nil, err
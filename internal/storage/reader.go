@@ -1,80 +1,43 @@
 package storage
 
 import (
-	"bufio"
-	"compress/gzip"
-	"io"
+	"os"
 
-	"github.com/farouqzaib/fast-search/internal/index"
+	"github.com/tysonmote/gommap"
 )
 
+// Reader memory-maps a segment file. The indexes loaded from it read straight
+// from the mapping, so they must not be used after the Reader is closed.
 type Reader struct {
-	file io.Closer
-	br   *bufio.Reader
+	file *os.File
+	mmap gommap.MMap
 }
 
-func NewReader(file io.Reader) *Reader {
-	r := &Reader{}
-	r.file, _ = file.(io.Closer)
-	r.br = bufio.NewReader(file)
-	return r
-}
-
-func (r *Reader) loadInvertedIndex() (index.InvertedIndex, error) {
-	reader, err := gzip.NewReader(r.br)
-	if err != nil {
-		if err == io.EOF {
-			panic(err)
-		}
-		panic(err)
-	}
-
-	b, err := io.ReadAll(reader)
+func NewReader(file *os.File) (*Reader, error) {
+	mmap, err := gommap.Map(file.Fd(), gommap.PROT_READ, gommap.MAP_SHARED)
 	if err != nil {
-		if err == io.EOF {
-			panic(err)
-		}
-		panic(err)
+		return nil, err
 	}
 
-	var i index.InvertedIndex
-
-	i = i.Decode(b)
-
-	return i, nil
+	return &Reader{file: file, mmap: mmap}, nil
 }
 
-func (r *Reader) loadVectorIndex() (index.HNSW, error) {
-	reader, err := gzip.NewReader(r.br)
-	if err != nil {
-		if err == io.EOF {
-			panic(err)
-		}
-		panic(err)
-	}
+func (r *Reader) Bytes() []byte {
+	return r.mmap
+}
 
-	b, err := io.ReadAll(reader)
+func (r *Reader) Close() error {
+	err := r.mmap.UnsafeUnmap()
 	if err != nil {
-		if err == io.EOF {
-			panic(err)
-		}
-		panic(err)
+		return err
 	}
 
-	var i index.HNSW
-
-	i = i.Decode(b)
-
-	return i, nil
-}
-
-func (r *Reader) Close() error {
-	err := r.file.Close()
+	err = r.file.Close()
 	if err != nil {
 		return err
 	}
 
 	r.file = nil
-	r.br = nil
+	r.mmap = nil
 	return nil
 }
@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/farouqzaib/fast-search/internal/analyzer"
+	"github.com/farouqzaib/fast-search/internal/index"
+)
+
+// Segment is a flushed memtable. Both of its indexes are served from
+// memory-mapped files, so opening one costs little more than two mmap calls.
+type Segment struct {
+	meta                *FileMetadata
+	invertedIndexReader *Reader
+	vectorIndexReader   *Reader
+	invertedIndex       *index.MappedInvertedIndex
+	vectorIndex         *index.MappedHNSW
+	// refs counts the views holding the segment. The last to release it
+	// closes it, and closed is told how that went.
+	refs   int32
+	closed func(error)
+}
+
+func openSegment(dataStorage *Provider, meta *FileMetadata, a *analyzer.Analyzer) (*Segment, error) {
+	s := &Segment{meta: meta}
+
+	var err error
+	s.invertedIndexReader, err = openReader(dataStorage, meta, InvertedIndexSegmentPath)
+	if err != nil {
+		return nil, err
+	}
+
+	s.invertedIndex, err = index.OpenInvertedIndex(s.invertedIndexReader.Bytes())
+	if err != nil {
+		s.Close()
+		return nil, fmt.Errorf("storage: opening %s: %w", dataStorage.path(meta, InvertedIndexSegmentPath), err)
+	}
+	s.invertedIndex.UseAnalyzer(a)
+
+	s.vectorIndexReader, err = openReader(dataStorage, meta, VectorIndexSegmentPath)
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+
+	s.vectorIndex, err = index.OpenHNSW(s.vectorIndexReader.Bytes())
+	if err != nil {
+		s.Close()
+		return nil, fmt.Errorf("storage: opening %s: %w", dataStorage.path(meta, VectorIndexSegmentPath), err)
+	}
+
+	return s, nil
+}
+
+func openReader(dataStorage *Provider, meta *FileMetadata, indexType string) (*Reader, error) {
+	f, err := dataStorage.OpenFileForReading(meta, indexType)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (s *Segment) ref() {
+	atomic.AddInt32(&s.refs, 1)
+}
+
+func (s *Segment) release() {
+	if atomic.AddInt32(&s.refs, -1) != 0 {
+		return
+	}
+
+	err := s.Close()
+	if s.closed != nil {
+		s.closed(err)
+	}
+}
+
+func (s *Segment) Close() error {
+	var err error
+	if s.invertedIndexReader != nil {
+		err = s.invertedIndexReader.Close()
+		s.invertedIndexReader = nil
+	}
+
+	if s.vectorIndexReader != nil {
+		if vErr := s.vectorIndexReader.Close(); err == nil {
+			err = vErr
+		}
+		s.vectorIndexReader = nil
+	}
+
+	s.invertedIndex = nil
+	s.vectorIndex = nil
+	return err
+}
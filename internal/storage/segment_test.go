@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"log/slog"
+	"math/rand"
+	"testing"
+
+	"github.com/farouqzaib/fast-search/internal/analyzer"
+	"github.com/farouqzaib/fast-search/internal/index"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSegmentFlushAndReopen(t *testing.T) {
+	dir := t.TempDir()
+
+	d, err := Open(dir, analyzer.Default(), nil, DefaultVectorConfig, slog.Default())
+	require.NoError(t, err)
+
+	m := d.memtables.mutable
+	m.inMemoryInvertedIndex.Index(1, "raft keeps the replicated log consistent")
+	m.inMemoryVectorIndex.Create([]index.VectorNode{{ID: 1, Vector: []float64{rand.Float64(), rand.Float64(), rand.Float64()}}})
+
+	require.NoError(t, d.FlushMemtables())
+	require.Len(t, d.segments, 1)
+	require.NoError(t, d.Close())
+
+	d, err = Open(dir, analyzer.Default(), nil, DefaultVectorConfig, slog.Default())
+	require.NoError(t, err)
+	defer d.Close()
+
+	require.Len(t, d.segments, 1)
+
+	got := d.segments[0].invertedIndex.RankProximity("replicated log", 10)
+	require.Len(t, got, 1)
+	require.Equal(t, 1, got[0].Offsets[0].GetDocumentID())
+
+	completions := d.Complete("repl", 5)
+	require.Equal(t, []index.Completion{{Text: "replicated", Frequency: 1}}, completions)
+
+	vectors := d.segments[0].vectorIndex.Search(index.VectorNode{Vector: []float64{1, 1, 1}}, 10, nil)
+	require.Len(t, vectors, 1)
+	require.Equal(t, 1, vectors[0].Offsets[0].GetDocumentID())
+}
@@ -0,0 +1,201 @@
+package storage
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/hashicorp/raft"
+)
+
+// A snapshot is the catalog of collections, as JSON prefixed with its length,
+// followed by the segments of every collection in catalog order: the inverted
+// index then the vector index of each segment, of the sizes the catalog
+// gives. Memtables are encoded as segments of their own, so a snapshot is
+// restored as segments only.
+type snapshotCollection struct {
+	Name     string           `json:"name"`
+	Config   CollectionConfig `json:"config"`
+	Segments [][2]int         `json:"segments"`
+}
+
+type snapshot struct {
+	catalog []snapshotCollection
+	views   []*view
+	// segments holds the inverted and vector index of every segment of the
+	// catalog, in its order.
+	segments [][2][]byte
+}
+
+// Snapshot holds a view of every collection until raft releases it, so the
+// segments it streams stay mapped while they are persisted. Raft does not
+// apply commands meanwhile, so memtables are encoded as they are.
+func (f *fsm) Snapshot() (raft.FSMSnapshot, error) {
+	return f.collections.snapshot(), nil
+}
+
+// Restore replaces every collection with those of a snapshot.
+func (f *fsm) Restore(r io.ReadCloser) error {
+	defer r.Close()
+	return f.collections.restore(r)
+}
+
+func (c *collections) snapshot() *snapshot {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	names := make([]string, 0, len(c.byName))
+	for name := range c.byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	s := &snapshot{}
+	for _, name := range names {
+		col := c.byName[name]
+		v := col.db.acquire()
+		s.views = append(s.views, v)
+
+		entry := snapshotCollection{Name: name, Config: col.config}
+		add := func(invertedIndex, vectorIndex []byte) {
+			s.segments = append(s.segments, [2][]byte{invertedIndex, vectorIndex})
+			entry.Segments = append(entry.Segments, [2]int{len(invertedIndex), len(vectorIndex)})
+		}
+
+		for _, segment := range v.segments {
+			add(segment.invertedIndexReader.Bytes(), segment.vectorIndexReader.Bytes())
+		}
+		for _, m := range v.memtables {
+			if !m.empty() {
+				add(m.inMemoryInvertedIndex.Encode(), m.inMemoryVectorIndex.Encode())
+			}
+		}
+		s.catalog = append(s.catalog, entry)
+	}
+	return s
+}
+
+func (s *snapshot) Persist(sink raft.SnapshotSink) error {
+	if err := s.persist(sink); err != nil {
+		_ = sink.Cancel()
+		return err
+	}
+	return sink.Close()
+}
+
+func (s *snapshot) persist(w io.Writer) error {
+	catalog, err := json.Marshal(s.catalog)
+	if err != nil {
+		return err
+	}
+
+	if err := binary.Write(w, binary.LittleEndian, uint64(len(catalog))); err != nil {
+		return err
+	}
+	if _, err := w.Write(catalog); err != nil {
+		return err
+	}
+
+	for _, segment := range s.segments {
+		for _, b := range segment {
+			if _, err := w.Write(b); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *snapshot) Release() {
+	for _, v := range s.views {
+		v.release()
+	}
+}
+
+// restore replaces the collections with those of a snapshot read from r.
+// Their segments are written out before the collections are reopened; those
+// they replace are removed once the searches reading them are done. The
+// default collection keeps the config it was opened with but for its words.
+func (c *collections) restore(r io.Reader) error {
+	var length uint64
+	if err := binary.Read(r, binary.LittleEndian, &length); err != nil {
+		return fmt.Errorf("storage: reading snapshot: %w", err)
+	}
+
+	b := make([]byte, length)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return fmt.Errorf("storage: reading snapshot: %w", err)
+	}
+
+	var catalog []snapshotCollection
+	if err := json.Unmarshal(b, &catalog); err != nil {
+		return fmt.Errorf("storage: reading snapshot: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	defaultConfig := c.byName[DefaultCollection].config
+	for name, col := range c.byName {
+		if err := c.bury(name, col); err != nil {
+			return err
+		}
+		if name != DefaultCollection {
+			if err := os.RemoveAll(col.dir); err != nil {
+				return err
+			}
+		}
+		delete(c.byName, name)
+	}
+
+	for _, entry := range catalog {
+		dir := c.dataDir
+		config := defaultConfig
+		config.Words = entry.Config.Words
+		saved := CollectionConfig{Words: config.Words}
+		if entry.Name != DefaultCollection {
+			dir = filepath.Join(c.dataDir, collectionsPath, entry.Name)
+			config = entry.Config
+			saved = config
+			if err := os.RemoveAll(dir); err != nil {
+				return err
+			}
+		}
+
+		dataStorage, err := NewProvider(dir)
+		if err != nil {
+			return err
+		}
+
+		for _, sizes := range entry.Segments {
+			meta := dataStorage.PrepareNewFile()
+			for i, indexType := range []string{InvertedIndexSegmentPath, VectorIndexSegmentPath} {
+				b := make([]byte, sizes[i])
+				if _, err := io.ReadFull(r, b); err != nil {
+					return fmt.Errorf("storage: reading snapshot: %w", err)
+				}
+
+				if err := writeSegment(dataStorage, b, meta, indexType); err != nil {
+					return err
+				}
+			}
+		}
+
+		if err := c.open(entry.Name, dir, config); err != nil {
+			return err
+		}
+
+		if err := writeCollectionConfig(dir, saved); err != nil {
+			return err
+		}
+	}
+
+	if _, ok := c.byName[DefaultCollection]; !ok {
+		return c.open(DefaultCollection, c.dataDir, defaultConfig)
+	}
+	return nil
+}
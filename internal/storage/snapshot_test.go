@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/farouqzaib/fast-search/internal/index"
+	"github.com/stretchr/testify/require"
+)
+
+type bufferSink struct {
+	bytes.Buffer
+}
+
+func (s *bufferSink) ID() string    { return "test" }
+func (s *bufferSink) Cancel() error { return nil }
+func (s *bufferSink) Close() error  { return nil }
+
+func TestSnapshotRestore(t *testing.T) {
+	add := func(c *collections, name string, docID int, text string) *IndexStorage {
+		db, err := c.get(name)
+		require.NoError(t, err)
+		require.NoError(t, db.memtables.mutable.inMemoryInvertedIndex.IndexDocument(docID, index.Document{Text: text}))
+		return db
+	}
+
+	count := func(c *collections, name string) int {
+		db, err := c.get(name)
+		require.NoError(t, err)
+
+		n := 0
+		require.NoError(t, db.Scroll(index.Query{}, func(int) error {
+			n++
+			return nil
+		}))
+		return n
+	}
+
+	src, err := openCollections(t.TempDir(), CollectionConfig{}, slog.Default())
+	require.NoError(t, err)
+	require.NoError(t, src.create("articles", CollectionConfig{Analyzer: "standard"}))
+
+	// a segment and a memtable in the default collection, a memtable in the
+	// other
+	db := add(src, DefaultCollection, 1, "raft leader")
+	db.rotateMemtables()
+	require.NoError(t, db.FlushMemtables())
+	add(src, DefaultCollection, 2, "gossip protocol")
+	add(src, "articles", 3, "paxos")
+
+	s, err := (&fsm{collections: src}).Snapshot()
+	require.NoError(t, err)
+	sink := &bufferSink{}
+	require.NoError(t, s.Persist(sink))
+	s.Release()
+
+	// the snapshot replaces whatever the node had
+	dir := t.TempDir()
+	dst, err := openCollections(dir, CollectionConfig{}, slog.Default())
+	require.NoError(t, err)
+	require.NoError(t, dst.create("notes", CollectionConfig{}))
+	add(dst, DefaultCollection, 4, "stale")
+
+	require.NoError(t, (&fsm{collections: dst}).Restore(io.NopCloser(&sink.Buffer)))
+
+	check := func(c *collections) {
+		list := c.list()
+		require.Len(t, list, 2)
+		require.Equal(t, "articles", list[0].Name)
+		require.Equal(t, "standard", list[0].Analyzer)
+
+		require.Equal(t, 2, count(c, DefaultCollection))
+		require.Equal(t, 1, count(c, "articles"))
+		if _, err := c.get("notes"); !errors.Is(err, ErrCollectionNotFound) {
+			t.Fatalf("expected notes to be gone, got %v", err)
+		}
+	}
+	check(dst)
+
+	// the collections restored are reopened on restart
+	require.NoError(t, dst.each(func(name string, db *IndexStorage) error { return db.Close() }))
+	dst, err = openCollections(dir, CollectionConfig{}, slog.Default())
+	require.NoError(t, err)
+	check(dst)
+}
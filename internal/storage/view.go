@@ -0,0 +1,44 @@
+package storage
+
+import "sync/atomic"
+
+// view is the set of memtables and segments a search reads, fixed when it is
+// acquired. Writers never change a view in use: flushes and rotations publish
+// a new one, and a segment is unmapped only once every view holding it has
+// been released.
+type view struct {
+	// memtables are in order of creation, the mutable one last.
+	memtables []*Memtable
+	segments  []*Segment
+	refs      int32
+}
+
+// newView returns a view of memtables and segments, held once by its caller.
+func newView(memtables []*Memtable, segments []*Segment) *view {
+	v := &view{
+		memtables: append([]*Memtable(nil), memtables...),
+		segments:  append([]*Segment(nil), segments...),
+		refs:      1,
+	}
+
+	for _, s := range v.segments {
+		s.ref()
+	}
+	return v
+}
+
+func (v *view) ref() {
+	atomic.AddInt32(&v.refs, 1)
+}
+
+// release drops a hold on the view, and the view's holds on its segments
+// with the last one.
+func (v *view) release() {
+	if atomic.AddInt32(&v.refs, -1) != 0 {
+		return
+	}
+
+	for _, s := range v.segments {
+		s.release()
+	}
+}
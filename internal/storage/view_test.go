@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/farouqzaib/fast-search/internal/analyzer"
+	"github.com/farouqzaib/fast-search/internal/index"
+	"github.com/stretchr/testify/require"
+)
+
+func TestViews(t *testing.T) {
+	d, err := Open(t.TempDir(), analyzer.Default(), nil, DefaultVectorConfig, slog.Default())
+	require.NoError(t, err)
+
+	add := func(docID int, text string) {
+		require.NoError(t, d.memtables.mutable.inMemoryInvertedIndex.IndexDocument(docID, index.Document{Text: text}))
+	}
+
+	add(1, "raft leader")
+	require.NoError(t, d.FlushMemtables())
+	d.rotateMemtables()
+
+	old := d.acquire()
+	require.Len(t, old.segments, 1)
+
+	// a flush publishes a new view, leaving the one in use as it was
+	add(2, "gossip protocol")
+	d.rotateMemtables()
+	require.NoError(t, d.FlushMemtables())
+
+	current := d.acquire()
+	require.Len(t, current.segments, 2)
+	require.Len(t, old.segments, 1)
+	require.Len(t, old.memtables, 1)
+
+	// closing waits for the views still held, whose segments stay readable
+	closed := make(chan error)
+	go func() { closed <- d.Close() }()
+
+	select {
+	case <-closed:
+		t.Fatal("expected Close to wait for the views held")
+	case <-time.After(50 * time.Millisecond):
+	}
+	require.Equal(t, 1, old.segments[0].invertedIndex.DocumentFrequency("raft"))
+
+	old.release()
+	require.Equal(t, 1, current.segments[1].invertedIndex.DocumentFrequency("gossip"))
+	current.release()
+	require.NoError(t, <-closed)
+
+	empty := d.acquire()
+	defer empty.release()
+	require.Empty(t, empty.segments)
+	require.Empty(t, empty.memtables)
+}
+
+// TestViewsConcurrentFlush scrolls while memtables are flushed, which every
+// scroll must see either side of but never halfway through. Run with -race.
+func TestViewsConcurrentFlush(t *testing.T) {
+	d, err := Open(t.TempDir(), analyzer.Default(), nil, DefaultVectorConfig, slog.Default())
+	require.NoError(t, err)
+	defer d.Close()
+
+	const n = 20
+	for i := 1; i <= n; i++ {
+		require.NoError(t, d.memtables.mutable.inMemoryInvertedIndex.IndexDocument(i, index.Document{Text: fmt.Sprintf("document%d", i)}))
+		d.rotateMemtables()
+	}
+
+	var wg sync.WaitGroup
+	for reader := 0; reader < 4; reader++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// require must not stop the test off its own goroutine
+			for i := 0; i < 50; i++ {
+				seen := 0
+				err := d.Scroll(index.Query{}, func(int) error {
+					seen++
+					return nil
+				})
+				if err != nil || seen != n {
+					t.Errorf("expected %d documents, got %d, %v", n, seen, err)
+					return
+				}
+			}
+		}()
+	}
+
+	require.NoError(t, d.FlushMemtables())
+	wg.Wait()
+}
@@ -2,9 +2,7 @@ package storage
 
 import (
 	"bufio"
-	"compress/gzip"
 	"io"
-	"log"
 )
 
 const bufLimit = 100000000
@@ -27,19 +25,11 @@ func NewWriter(file io.Writer) *Writer {
 	return w
 }
 
+// WriteDataBlock writes an encoded index as is. Segments are memory-mapped
+// when they are read back, so the block is deliberately left uncompressed.
 func (w *Writer) WriteDataBlock(inMemoryIndex []byte) error {
-	gz, err := gzip.NewWriterLevel(w.bw, 9)
-	if err != nil {
-		panic(err)
-	}
-	if _, err := gz.Write(inMemoryIndex); err != nil {
-		log.Fatal(err)
-	}
-	if err := gz.Close(); err != nil {
-		log.Fatal(err)
-	}
-
-	return nil
+	_, err := w.bw.Write(inMemoryIndex)
+	return err
 }
 
 func (w *Writer) Close() error {